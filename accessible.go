@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// glyph picks between a decorative symbol and the words screen reader
+// users get instead with ui.accessible.
+func (c *Config) glyph(symbol, label string) string {
+	if c.UI.Accessible {
+		return label
+	}
+	return symbol
+}
+
+func countNoun(n int, noun string) string {
+	if n == 1 {
+		return fmt.Sprintf("1 %s", noun)
+	}
+	return fmt.Sprintf("%d %ss", n, noun)
+}
+
+// formatCount is " (12)" after a list item, or ", 12 tweets" spelled out.
+func (c *Config) formatCount(n int, noun string) string {
+	if c.UI.Accessible {
+		return ", " + countNoun(n, noun)
+	}
+	return fmt.Sprintf(" (%d)", n)
+}
+
+func emojiOnly(line string) bool {
+	found := false
+	for _, r := range line {
+		switch {
+		case isEmoji(r) || isRegionalIndicator(r):
+			found = true
+		case isEmojiModifier(r) || unicode.IsSpace(r):
+		default:
+			return false
+		}
+	}
+	return found
+}
+
+// describeEmojiLines names the emoji on lines that have nothing else, which
+// screen readers otherwise skip or announce as a run of symbols.
+func describeEmojiLines(text string) string {
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		if !emojiOnly(line) {
+			continue
+		}
+		var names []string
+		for _, code := range strings.Fields(strings.Replace(shortcodeEmoji(line), "::", ": :", -1)) {
+			names = append(names, strings.Replace(strings.Trim(code, ":"), "_", " ", -1))
+		}
+		lines[i] = "Emoji: " + strings.Join(names, ", ")
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/dghubble/go-twitter/twitter"
+)
+
+// accountActivity is the part of an Account Activity API event we act on.
+type accountActivity struct {
+	ForUserID         string            `json:"for_user_id"`
+	TweetCreateEvents []json.RawMessage `json:"tweet_create_events"`
+	TweetDeleteEvents []struct {
+		Status struct {
+			ID     string `json:"id"`
+			UserID string `json:"user_id"`
+		} `json:"status"`
+	} `json:"tweet_delete_events"`
+}
+
+// editHistory lists every version of an edited tweet, the newest last.
+type editHistory struct {
+	EditHistory struct {
+		EditTweetIDs []string `json:"edit_tweet_ids"`
+	} `json:"edit_history"`
+}
+
+// crcResponseToken answers Twitter's challenge-response check, which it runs
+// when the webhook is registered and hourly after that.
+func crcResponseToken(consumerSecret, crcToken string) string {
+	mac := hmac.New(sha256.New, []byte(consumerSecret))
+	mac.Write([]byte(crcToken))
+	return "sha256=" + base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// validWebhookSignature checks an X-Twitter-Webhooks-Signature header, a
+// base64 HMAC-SHA256 of the body keyed with the consumer secret.
+func validWebhookSignature(consumerSecret string, body []byte, header string) bool {
+	if !strings.HasPrefix(header, "sha256=") {
+		return false
+	}
+	sig, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(header, "sha256="))
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(sha256.New, []byte(consumerSecret))
+	mac.Write(body)
+	return hmac.Equal(sig, mac.Sum(nil))
+}
+
+func (c *Config) ownTweet(tweet twitter.Tweet) bool {
+	if tweet.User == nil {
+		return false
+	}
+	if c.Twitter.UserID != 0 {
+		return tweet.User.ID == c.Twitter.UserID
+	}
+	return strings.EqualFold(tweet.User.ScreenName, c.Twitter.ScreenName)
+}
+
+// decodeAccountActivity returns the mirrored account's new tweets, and the
+// IDs to drop: deleted tweets and versions superseded by an edit.
+func (c *Config) decodeAccountActivity(body []byte) ([]twitter.Tweet, []int64, error) {
+	var event accountActivity
+	if err := json.Unmarshal(body, &event); err != nil {
+		return nil, nil, err
+	}
+
+	var created []twitter.Tweet
+	var removed []int64
+	for _, raw := range event.TweetCreateEvents {
+		var tweet twitter.Tweet
+		if err := json.Unmarshal(raw, &tweet); err != nil {
+			return nil, nil, err
+		}
+		if !c.ownTweet(tweet) {
+			continue
+		}
+		created = append(created, tweet)
+
+		var edits editHistory
+		if err := json.Unmarshal(raw, &edits); err != nil {
+			return nil, nil, err
+		}
+		for _, s := range edits.EditHistory.EditTweetIDs {
+			if id, err := strconv.ParseInt(s, 10, 64); err == nil && id != tweet.ID {
+				removed = append(removed, id)
+			}
+		}
+	}
+	for _, e := range event.TweetDeleteEvents {
+		if id, err := strconv.ParseInt(e.Status.ID, 10, 64); err == nil {
+			removed = append(removed, id)
+		}
+	}
+
+	created = dropRepliesToOthers(created)
+	sortNewestFirst(created)
+	return created, removed, nil
+}
+
+func (tc *TweetCache) serveAccountActivity(w http.ResponseWriter, r *http.Request) {
+	secret := tc.Config.Twitter.ConsumerSecret
+	switch r.Method {
+	case http.MethodGet:
+		crcToken := r.URL.Query().Get("crc_token")
+		if crcToken == "" {
+			http.Error(w, "missing crc_token", http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{
+			"response_token": crcResponseToken(secret, crcToken),
+		})
+	case http.MethodPost:
+		body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxIngestLength))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if !validWebhookSignature(secret, body, r.Header.Get("X-Twitter-Webhooks-Signature")) {
+			http.Error(w, "bad signature", http.StatusUnauthorized)
+			return
+		}
+		created, removed, err := tc.Config.decodeAccountActivity(body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		changed := len(created) > 0 && tc.merge(created)
+		if len(removed) > 0 && tc.remove(removed) {
+			changed = true
+		}
+		if changed {
+			tc.changed()
+		}
+		w.WriteHeader(http.StatusOK)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/dghubble/go-twitter/twitter"
+)
+
+// Twitter API error codes for accounts that can't be read any more.
+const (
+	userNotFoundErrorCode  = 50
+	userSuspendedErrorCode = 63
+	pageNotFoundErrorCode  = 34
+)
+
+func apiErrorCode(err error) int {
+	var apiErr twitter.APIError
+	if errors.As(err, &apiErr) && len(apiErr.Errors) > 0 {
+		return apiErr.Errors[0].Code
+	}
+	return 0
+}
+
+// accountState explains a failed timeline fetch that is down to the
+// account itself, or returns "" when it's something else. A 401 can mean
+// bad credentials as well as a protected account, so that case asks for
+// the profile to tell them apart.
+func (tc *TweetCache) accountState(resp *http.Response, err error) string {
+	account := tc.liveConfig().account()
+	switch apiErrorCode(err) {
+	case userSuspendedErrorCode:
+		return account + " has been suspended by Twitter."
+	case userNotFoundErrorCode, pageNotFoundErrorCode:
+		return account + " no longer exists on Twitter."
+	}
+	if !isUnauthorized(resp) {
+		return ""
+	}
+	client := twitter.NewClient(tc.httpClient())
+	user, _, err := client.Users.Show(&twitter.UserShowParams{
+		UserID:     tc.Config.Twitter.UserID,
+		ScreenName: tc.Config.Twitter.ScreenName,
+	})
+	if err == nil && user.Protected {
+		return account + " has protected their tweets."
+	}
+	return ""
+}
+
+func (tc *TweetCache) setAccountState(state string) {
+	if state != tc.AccountState {
+		tc.AccountState = state
+		tc.changed()
+	}
+}
+
+// formatAccountState tells readers why the mirror stopped updating.
+func (rh *RequestHandler) formatAccountState() string {
+	state := rh.TweetCache.AccountState
+	if state == "" {
+		return ""
+	}
+	banner := "> " + rh.Config.glyph("⚠ ", "Warning: ") + state + " The mirror keeps serving its archive"
+	if len(rh.TweetCache.Tweets) > 0 {
+		if t, err := rh.TweetCache.Tweets[0].CreatedAtTime(); err == nil {
+			banner += fmt.Sprintf(", last updated %s", t.Format("2006-01-02"))
+		}
+	}
+	return banner + ".\n\n"
+}
@@ -0,0 +1,416 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"html"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dghubble/go-twitter/twitter"
+)
+
+const (
+	activityStreams   = "https://www.w3.org/ns/activitystreams"
+	activityJSON      = "application/activity+json"
+	outboxSize        = 20
+	maxActivityLength = 1 << 20
+)
+
+// ActivityPub publishes the mirror as a read-only actor. Followers are
+// accepted automatically and get a Create for every new tweet.
+type ActivityPub struct {
+	Config
+	tc  *TweetCache
+	key *rsa.PrivateKey
+
+	mu        sync.Mutex
+	followers map[string]string // actor ID -> inbox
+}
+
+func NewActivityPub(c Config, tc *TweetCache) (*ActivityPub, error) {
+	key, err := loadOrCreateKey(c.ActivityPub.KeyFile)
+	if err != nil {
+		return nil, err
+	}
+	ap := &ActivityPub{Config: c, tc: tc, key: key, followers: map[string]string{}}
+	if b, err := ioutil.ReadFile(c.ActivityPub.FollowersFile); err == nil {
+		if err := json.Unmarshal(b, &ap.followers); err != nil {
+			return nil, err
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	tc.events.Subscribe(EventNewTweets, func(e Event) { go ap.Publish(e.Tweets) })
+	return ap, nil
+}
+
+func loadOrCreateKey(path string) (*rsa.PrivateKey, error) {
+	b, err := ioutil.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		key, err := rsa.GenerateKey(rand.Reader, 2048)
+		if err != nil {
+			return nil, err
+		}
+		block := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+		return key, ioutil.WriteFile(path, block, 0600)
+	} else if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(b)
+	if block == nil {
+		return nil, fmt.Errorf("%s: no PEM block", path)
+	}
+	return x509.ParsePKCS1PrivateKey(block.Bytes)
+}
+
+func (ap *ActivityPub) base() string {
+	return strings.TrimSuffix(ap.ActivityPub.BaseURL, "/")
+}
+
+func (ap *ActivityPub) actorID() string {
+	return ap.base() + "/ap/actor"
+}
+
+func (ap *ActivityPub) host() string {
+	u, _ := url.Parse(ap.ActivityPub.BaseURL)
+	return u.Host
+}
+
+func (ap *ActivityPub) register(mux *http.ServeMux) {
+	mux.HandleFunc("/.well-known/webfinger", ap.serveWebfinger)
+	mux.HandleFunc("/ap/actor", ap.serveActor)
+	mux.HandleFunc("/ap/outbox", ap.serveOutbox)
+	mux.HandleFunc("/ap/followers", ap.serveFollowers)
+	mux.HandleFunc("/ap/inbox", ap.serveInbox)
+	mux.HandleFunc("/ap/notes/", ap.serveNote)
+}
+
+func writeJSON(w http.ResponseWriter, contentType string, v interface{}) {
+	w.Header().Set("Content-Type", contentType)
+	json.NewEncoder(w).Encode(v)
+}
+
+func (ap *ActivityPub) serveWebfinger(w http.ResponseWriter, r *http.Request) {
+	acct := fmt.Sprintf("acct:%s@%s", ap.ActivityPub.Username, ap.host())
+	if r.URL.Query().Get("resource") != acct {
+		http.NotFound(w, r)
+		return
+	}
+	writeJSON(w, "application/jrd+json", map[string]interface{}{
+		"subject": acct,
+		"links": []map[string]string{
+			{"rel": "self", "type": activityJSON, "href": ap.actorID()},
+		},
+	})
+}
+
+func (ap *ActivityPub) serveActor(w http.ResponseWriter, r *http.Request) {
+	pub, err := x509.MarshalPKIXPublicKey(&ap.key.PublicKey)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	name, summary := ap.ActivityPub.Username, ""
+	if len(ap.tc.Tweets) > 0 && ap.tc.Tweets[0].User != nil {
+		name, summary = ap.tc.Tweets[0].User.Name, html.EscapeString(ap.tc.Tweets[0].User.Description)
+	}
+	writeJSON(w, activityJSON, map[string]interface{}{
+		"@context":          []string{activityStreams, "https://w3id.org/security/v1"},
+		"id":                ap.actorID(),
+		"type":              "Service",
+		"preferredUsername": ap.ActivityPub.Username,
+		"name":              name,
+		"summary":           summary,
+		"url":               ap.Addr.URL,
+		"inbox":             ap.base() + "/ap/inbox",
+		"outbox":            ap.base() + "/ap/outbox",
+		"followers":         ap.base() + "/ap/followers",
+		"publicKey": map[string]string{
+			"id":           ap.actorID() + "#main-key",
+			"owner":        ap.actorID(),
+			"publicKeyPem": string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pub})),
+		},
+	})
+}
+
+func (ap *ActivityPub) note(tweet twitter.Tweet) map[string]interface{} {
+	link := strings.TrimSuffix(ap.Addr.URL, "/") + permalink(tweet)
+	content := strings.Replace(html.EscapeString(expandLinks(tweet)), "\n", "<br>", -1)
+	content = fmt.Sprintf(`<p>%s</p><p><a href="%s">%s</a></p>`, content, html.EscapeString(link), html.EscapeString(link))
+	published := time.Now()
+	if t, err := tweet.CreatedAtTime(); err == nil {
+		published = t
+	}
+	return map[string]interface{}{
+		"id":           fmt.Sprintf("%s/ap/notes/%d", ap.base(), tweet.ID),
+		"type":         "Note",
+		"attributedTo": ap.actorID(),
+		"content":      content,
+		"url":          link,
+		"published":    published.UTC().Format(time.RFC3339),
+		"to":           []string{activityStreams + "#Public"},
+		"cc":           []string{ap.base() + "/ap/followers"},
+	}
+}
+
+func (ap *ActivityPub) create(tweet twitter.Tweet) map[string]interface{} {
+	note := ap.note(tweet)
+	return map[string]interface{}{
+		"id":        note["id"].(string) + "/activity",
+		"type":      "Create",
+		"actor":     ap.actorID(),
+		"published": note["published"],
+		"to":        note["to"],
+		"cc":        note["cc"],
+		"object":    note,
+	}
+}
+
+func (ap *ActivityPub) serveOutbox(w http.ResponseWriter, r *http.Request) {
+	var items []interface{}
+	for i, tweet := range ap.tc.Tweets {
+		if i == outboxSize {
+			break
+		}
+		items = append(items, ap.create(tweet))
+	}
+	writeJSON(w, activityJSON, map[string]interface{}{
+		"@context":     activityStreams,
+		"id":           ap.base() + "/ap/outbox",
+		"type":         "OrderedCollection",
+		"totalItems":   len(ap.tc.Tweets),
+		"orderedItems": items,
+	})
+}
+
+func (ap *ActivityPub) serveFollowers(w http.ResponseWriter, r *http.Request) {
+	ap.mu.Lock()
+	n := len(ap.followers)
+	ap.mu.Unlock()
+	writeJSON(w, activityJSON, map[string]interface{}{
+		"@context":   activityStreams,
+		"id":         ap.base() + "/ap/followers",
+		"type":       "OrderedCollection",
+		"totalItems": n,
+	})
+}
+
+func (ap *ActivityPub) serveNote(w http.ResponseWriter, r *http.Request) {
+	var id int64
+	if _, err := fmt.Sscanf(strings.TrimPrefix(r.URL.Path, "/ap/notes/"), "%d", &id); err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	pos, err := ap.tc.FindByID(id)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	note := ap.note(ap.tc.Tweets[pos])
+	note["@context"] = activityStreams
+	writeJSON(w, activityJSON, note)
+}
+
+// remoteActor is the part of another server's actor we need.
+type remoteActor struct {
+	ID        string `json:"id"`
+	Inbox     string `json:"inbox"`
+	PublicKey struct {
+		ID           string `json:"id"`
+		Owner        string `json:"owner"`
+		PublicKeyPem string `json:"publicKeyPem"`
+	} `json:"publicKey"`
+}
+
+// keyFor returns the actor's public key if it is keyID and the actor is
+// actorID, so one can't sign for another.
+func (actor *remoteActor) keyFor(keyID, actorID string) (*rsa.PublicKey, error) {
+	if actor.ID != actorID {
+		return nil, errors.New("key doesn't belong to the activity's actor")
+	}
+	if actor.PublicKey.ID != keyID || actor.PublicKey.Owner != actor.ID {
+		return nil, fmt.Errorf("%s isn't a key of %s", keyID, actor.ID)
+	}
+	return parsePublicKey(actor.PublicKey.PublicKeyPem)
+}
+
+func (ap *ActivityPub) client() *http.Client {
+	return &http.Client{Timeout: 30 * time.Second, Transport: ap.Config.outbound(nil)}
+}
+
+func (ap *ActivityPub) fetchActor(id string) (*remoteActor, error) {
+	req, err := http.NewRequest("GET", id, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", activityJSON)
+	if err := signRequest(req, ap.actorID()+"#main-key", ap.key, nil); err != nil {
+		return nil, err
+	}
+	resp, err := ap.client().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetching %s: %s", id, resp.Status)
+	}
+	var actor remoteActor
+	if err := json.NewDecoder(resp.Body).Decode(&actor); err != nil {
+		return nil, err
+	}
+	return &actor, nil
+}
+
+func (ap *ActivityPub) serveInbox(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxActivityLength))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var activity struct {
+		ID     string          `json:"id"`
+		Type   string          `json:"type"`
+		Actor  string          `json:"actor"`
+		Object json.RawMessage `json:"object"`
+	}
+	if err := json.Unmarshal(body, &activity); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var actor *remoteActor
+	_, err = verifyRequest(r, body, func(keyID string) (*rsa.PublicKey, error) {
+		owner := strings.SplitN(keyID, "#", 2)[0]
+		if owner != activity.Actor {
+			return nil, errors.New("key doesn't belong to the activity's actor")
+		}
+		if actor, err = ap.fetchActor(owner); err != nil {
+			return nil, err
+		}
+		return actor.keyFor(keyID, activity.Actor)
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	switch activity.Type {
+	case "Follow":
+		ap.addFollower(actor.ID, actor.Inbox)
+		go ap.accept(actor.Inbox, body)
+	case "Undo":
+		var inner struct {
+			Type string `json:"type"`
+		}
+		if json.Unmarshal(activity.Object, &inner) == nil && inner.Type == "Follow" {
+			ap.removeFollower(actor.ID)
+		}
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (ap *ActivityPub) saveFollowers() {
+	b, err := json.Marshal(ap.followers)
+	if err == nil {
+		err = writeFileAtomic(ap.ActivityPub.FollowersFile, b)
+	}
+	if err != nil {
+		fmt.Println(err)
+	}
+}
+
+func (ap *ActivityPub) addFollower(id, inbox string) {
+	ap.mu.Lock()
+	defer ap.mu.Unlock()
+	ap.followers[id] = inbox
+	ap.saveFollowers()
+}
+
+func (ap *ActivityPub) removeFollower(id string) {
+	ap.mu.Lock()
+	defer ap.mu.Unlock()
+	delete(ap.followers, id)
+	ap.saveFollowers()
+}
+
+func randomID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func (ap *ActivityPub) accept(inbox string, follow json.RawMessage) {
+	err := ap.deliver(inbox, map[string]interface{}{
+		"@context": activityStreams,
+		"id":       ap.base() + "/ap/accepts/" + randomID(),
+		"type":     "Accept",
+		"actor":    ap.actorID(),
+		"object":   follow,
+	})
+	if err != nil {
+		fmt.Println(err)
+	}
+}
+
+func (ap *ActivityPub) deliver(inbox string, activity map[string]interface{}) error {
+	body, err := json.Marshal(activity)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest("POST", inbox, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", activityJSON)
+	if err := signRequest(req, ap.actorID()+"#main-key", ap.key, body); err != nil {
+		return err
+	}
+	resp, err := ap.client().Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("delivering to %s: %s", inbox, resp.Status)
+	}
+	return nil
+}
+
+// Publish sends a Create for each new tweet, oldest first, to every
+// follower.
+func (ap *ActivityPub) Publish(tweets []twitter.Tweet) {
+	ap.mu.Lock()
+	inboxes := map[string]bool{}
+	for _, inbox := range ap.followers {
+		inboxes[inbox] = true
+	}
+	ap.mu.Unlock()
+
+	for _, tweet := range tweets {
+		activity := ap.create(tweet)
+		activity["@context"] = activityStreams
+		for inbox := range inboxes {
+			if err := ap.deliver(inbox, activity); err != nil {
+				fmt.Println(err)
+			}
+		}
+	}
+}
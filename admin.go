@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"time"
+
+	"github.com/makeworld-the-better-one/go-gemini"
+)
+
+func (rh *RequestHandler) formatAdmin() string {
+	tc := rh.TweetCache
+	page := "# Admin\n\n"
+	if tc.inMaintenance() {
+		page += fmt.Sprintf("%sPaused for maintenance: %s\n=> /admin/maintenance Resume the mirror\n\n", rh.Config.glyph("⚠ ", "Warning: "), rh.Config.maintenanceMessage())
+	}
+	page += "## Twitter\n\n"
+	if tc.CredentialsProblem != "" {
+		page += fmt.Sprintf("%s%s\n", rh.Config.glyph("⚠ ", "Warning: "), tc.CredentialsProblem)
+	} else {
+		page += "Credentials OK\n"
+	}
+	if tc.AccountState != "" {
+		page += fmt.Sprintf("%s%s\n", rh.Config.glyph("⚠ ", "Warning: "), tc.AccountState)
+	}
+	if apps := len(tc.Config.twitterApps()); apps > 1 {
+		page += fmt.Sprintf("Using app %d of %d\n", tc.app+1, apps)
+	}
+	if tc.Config.isQuiet(time.Now()) {
+		page += "Polling paused for quiet hours\n"
+	}
+	if tc.redis != nil {
+		page += fmt.Sprintf("Fetches and quota shared through Redis at %s\n", tc.Config.Redis.Addr)
+	}
+	quota := tc.Config.Twitter.Quota
+	page += tc.quota.format(quota.PerWindow, quota.PerDay)
+	if wait := tc.quotaWait(); wait > 0 {
+		page += fmt.Sprintf("Polling paused for %s, the API quota is used up\n", wait.Truncate(time.Second))
+	}
+	if tc.skippedFetches > 0 {
+		page += fmt.Sprintf("Timeline fetches skipped, nothing new: %d\n", tc.skippedFetches)
+	}
+	if tc.LastError != "" {
+		page += fmt.Sprintf("Last fetch error: %s\n", tc.LastError)
+	}
+	if tc.LastRefresh.IsZero() {
+		page += "Last refresh: never\n"
+	} else {
+		page += fmt.Sprintf("Last refresh: %s (%s ago)\n", tc.LastRefresh.Format(time.RFC3339), time.Since(tc.LastRefresh).Truncate(time.Second))
+	}
+
+	page += "\n## Cache\n\n"
+	page += fmt.Sprintf("Tweets in memory: %d\n", len(tc.Tweets))
+	if tc.archive != nil {
+		page += fmt.Sprintf("Tweets archived: %d\n", len(tc.archive.Tweets))
+	}
+	page += tc.fetchErrors.format()
+	if check := tc.archiveCheck; check != nil {
+		page += "\n## Archive check\n\n" + check.format()
+	}
+	page += rh.metrics.format()
+	page += "\n=> /admin/refresh Refresh now\n=> /admin/changes Changes in the last refresh\n=> /admin/sources Mirrored accounts\n=> /admin/announcement Announcement\n"
+	if !tc.inMaintenance() {
+		page += "=> /admin/maintenance Pause the mirror for maintenance\n"
+	}
+	if rh.Config.Comments.Enabled {
+		if rh.Config.Comments.Moderate {
+			page += fmt.Sprintf("=> /admin/comments Comments (%d waiting for approval)\n", len(rh.TweetCache.comments.Pending()))
+		} else {
+			page += "=> /admin/comments Comments\n"
+		}
+	}
+	return page
+}
+
+func (rh *RequestHandler) showAdmin() *gemini.Response {
+	body := ioutil.NopCloser(bytes.NewBufferString(rh.wrapBody(rh.formatAdmin())))
+	return Success(rh.meta(), body)
+}
+
+func (rh *RequestHandler) refreshNow() *gemini.Response {
+	if err := rh.TweetCache.Refresh(); err != nil {
+		return TempFailure("Refresh failed: " + err.Error())
+	}
+	return Redirect("/admin")
+}
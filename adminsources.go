@@ -0,0 +1,285 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/makeworld-the-better-one/go-gemini"
+	"gopkg.in/yaml.v2"
+)
+
+// replaceSources swaps the sources entry of a YAML config for sources,
+// leaving every other line, comments included, as it was.
+func replaceSources(config []byte, sources []SourceConfig) ([]byte, error) {
+	entry, err := yaml.Marshal(map[string][]SourceConfig{"sources": sources})
+	if err != nil {
+		return nil, err
+	}
+	lines := strings.SplitAfter(string(config), "\n")
+	var out []string
+	replaced := false
+	for i := 0; i < len(lines); i += 1 {
+		if replaced || !strings.HasPrefix(lines[i], "sources:") {
+			out = append(out, lines[i])
+			continue
+		}
+		out = append(out, string(entry))
+		replaced = true
+		// The old entries are indented or list items; keep the comments
+		// among them.
+		for i+1 < len(lines) {
+			next := lines[i+1]
+			trimmed := strings.TrimSpace(next)
+			if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+				out = append(out, next)
+			} else if !strings.HasPrefix(next, " ") && !strings.HasPrefix(next, "\t") && !strings.HasPrefix(next, "-") {
+				break
+			}
+			i += 1
+		}
+	}
+	if !replaced {
+		if len(out) > 0 && !strings.HasSuffix(out[len(out)-1], "\n") {
+			out = append(out, "\n")
+		}
+		out = append(out, string(entry))
+	}
+	return []byte(strings.Join(out, "")), nil
+}
+
+func saveSources(path string, sources []SourceConfig) error {
+	config, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	config, err = replaceSources(config, sources)
+	if err != nil {
+		return err
+	}
+	// The config holds API secrets: keep it as private as it was.
+	mode := os.FileMode(0600)
+	if info, err := os.Stat(path); err == nil {
+		mode = info.Mode().Perm()
+	}
+	if err := writeFileAtomic(path, config); err != nil {
+		return err
+	}
+	return os.Chmod(path, mode)
+}
+
+var errNoSuchSource = errors.New("No such source")
+
+// updateSources switches the aggregated sources at runtime to what change
+// makes of the current ones, and writes them back to the config file.
+// Sources that stay keep their fetch schedule.
+func (tc *TweetCache) updateSources(change func(current []aggregatedSource) ([]SourceConfig, error)) error {
+	// Changes are made one at a time, each to the sources the last left.
+	tc.mergeMu.Lock()
+	defer tc.mergeMu.Unlock()
+
+	old, ok := tc.aggregate()
+	if !ok {
+		return errors.New("sources can only be changed with source: aggregate")
+	}
+	sources, err := change(old.sources)
+	if err != nil {
+		return err
+	}
+	if len(sources) == 0 {
+		return errors.New("at least one source is needed")
+	}
+	for _, sc := range sources {
+		if err := sc.config(tc.Config).validateSource(); err != nil {
+			return err
+		}
+	}
+	source, err := tc.newAggregateSource(sources)
+	if err != nil {
+		return err
+	}
+	source.fetched = old.fetched
+	if tc.Config.path != "" {
+		if err := saveSources(tc.Config.path, sources); err != nil {
+			return err
+		}
+	}
+	var next Source = source
+	if tc.redis != nil {
+		next = tc.shareSource(source)
+	}
+	tc.sourceMu.Lock()
+	tc.source = next
+	tc.sourceMu.Unlock()
+	tc.changed()
+	return nil
+}
+
+// accountSource makes a source of an account as people write them:
+// user@instance or @user@instance on Mastodon, a t.me link to a Telegram
+// channel, or the URL of a feed.
+func accountSource(account string) (SourceConfig, bool) {
+	if u, err := url.Parse(account); err == nil && (u.Scheme == "https" || u.Scheme == "http") && u.Host != "" {
+		if u.Host == "t.me" {
+			channel := strings.TrimPrefix(strings.Trim(u.Path, "/"), "s/")
+			return SourceConfig{Type: "telegram", Channel: channel}, channel != "" && !strings.Contains(channel, "/")
+		}
+		return SourceConfig{Type: "feed", URL: account}, true
+	}
+	if _, _, ok := splitAccount(strings.TrimPrefix(account, "@")); ok {
+		return SourceConfig{Type: "mastodon", Account: strings.TrimPrefix(account, "@")}, true
+	}
+	return SourceConfig{}, false
+}
+
+// parseSourceInput reads an account, or "type [channel, URL or account]",
+// as typed into the add source prompt.
+func parseSourceInput(input string) (SourceConfig, error) {
+	fields := strings.Fields(input)
+	if len(fields) == 0 || len(fields) > 2 {
+		return SourceConfig{}, errors.New("expected an account, or a type and a channel, URL or account")
+	}
+	if len(fields) == 1 {
+		if sc, ok := accountSource(fields[0]); ok {
+			return sc, nil
+		}
+	}
+	sc := SourceConfig{Type: strings.ToLower(fields[0])}
+	target := ""
+	if len(fields) == 2 {
+		target = fields[1]
+	}
+	switch sc.Type {
+	case "telegram":
+		sc.Channel = target
+	case "feed":
+		sc.URL = target
+	case "mastodon":
+		sc.Account = target
+	}
+	return sc, nil
+}
+
+// account is who a source mirrors, as shown on /admin/sources.
+func (sc SourceConfig) account(c Config) string {
+	switch sc.Type {
+	case "twitter":
+		if c.Twitter.ScreenName != "" {
+			return "@" + c.Twitter.ScreenName
+		}
+	case "telegram":
+		return "https://t.me/" + sc.Channel
+	case "feed":
+		return sc.URL
+	case "mastodon":
+		return "@" + sc.Account
+	}
+	return ""
+}
+
+func (rh *RequestHandler) formatSources() string {
+	page := "# Mirrored accounts\n"
+	if rh.TweetCache.Config.Source != "aggregate" {
+		return page + fmt.Sprintf("\nMirroring a single %s source. Set source to aggregate in the config to manage accounts here.\n\n=> /admin Back to admin\n", rh.TweetCache.Config.Source)
+	}
+	s, _ := rh.TweetCache.aggregate()
+	for _, source := range s.sources {
+		sc, name := source.config, source.name
+		page += fmt.Sprintf("\n## %s\n\n%s %s\n", name, sc.Type, sc.account(rh.TweetCache.Config))
+		if sc.Paused {
+			page += "Paused\n"
+		} else {
+			page += "Mirroring\n"
+		}
+		escaped := url.PathEscape(name)
+		if sc.Paused {
+			page += fmt.Sprintf("=> /admin/sources/resume/%s Resume\n", escaped)
+		} else {
+			page += fmt.Sprintf("=> /admin/sources/pause/%s Pause\n", escaped)
+		}
+		page += fmt.Sprintf("=> /admin/sources/remove/%s Remove\n", escaped)
+	}
+	page += "\n=> /admin/sources/add Mirror another account\n"
+	return page + "\nPaused accounts keep their posts on the mirror, but aren't fetched from.\n\n=> /admin Back to admin\n"
+}
+
+func (rh *RequestHandler) showSources() *gemini.Response {
+	body := ioutil.NopCloser(bytes.NewBufferString(rh.wrapBody(rh.formatSources())))
+	return Success(rh.meta(), body)
+}
+
+func (rh *RequestHandler) addSource(input string) *gemini.Response {
+	if rh.TweetCache.Config.Source != "aggregate" {
+		return BadRequest("Sources can only be added to source: aggregate")
+	}
+	sc, err := parseSourceInput(input)
+	if err == nil {
+		err = sc.config(rh.TweetCache.Config).validateSource()
+	}
+	if err != nil {
+		return Input(err.Error())
+	}
+	err = rh.TweetCache.updateSources(func(current []aggregatedSource) ([]SourceConfig, error) {
+		// Sources are told apart by name, and unnamed ones of a type
+		// share the type's; another account of a type goes by its own.
+		sources := configsOf(current)
+		for _, other := range sources {
+			if other.Type == sc.Type && sc.Name == "" {
+				sc.Name = sc.account(rh.TweetCache.Config)
+			}
+		}
+		return append(sources, sc), nil
+	})
+	var clash duplicateSourceError
+	if errors.As(err, &clash) {
+		return Input(fmt.Sprintf("An account called %q is mirrored already, enter another", clash.name))
+	} else if err != nil {
+		return TempFailure(err.Error())
+	}
+	return Redirect("/admin/sources")
+}
+
+// pauseSource stops or starts fetching from a source.
+func (rh *RequestHandler) pauseSource(name string, paused bool) *gemini.Response {
+	err := rh.TweetCache.updateSources(func(current []aggregatedSource) ([]SourceConfig, error) {
+		sources := configsOf(current)
+		for i, source := range current {
+			if source.name == name {
+				sources[i].Paused = paused
+				return sources, nil
+			}
+		}
+		return nil, errNoSuchSource
+	})
+	if err == errNoSuchSource {
+		return NotFound(err.Error())
+	} else if err != nil {
+		return TempFailure(err.Error())
+	}
+	return Redirect("/admin/sources")
+}
+
+func (rh *RequestHandler) removeSource(name string) *gemini.Response {
+	err := rh.TweetCache.updateSources(func(current []aggregatedSource) ([]SourceConfig, error) {
+		var sources []SourceConfig
+		for _, source := range current {
+			if source.name != name {
+				sources = append(sources, source.config)
+			}
+		}
+		if len(sources) == len(current) {
+			return nil, errNoSuchSource
+		}
+		return sources, nil
+	})
+	if err == errNoSuchSource {
+		return NotFound(err.Error())
+	} else if err != nil {
+		return BadRequest(err.Error())
+	}
+	return Redirect("/admin/sources")
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dghubble/go-twitter/twitter"
+)
+
+func TestAccountSource(t *testing.T) {
+	tests := []struct {
+		input string
+		want  SourceConfig
+	}{
+		{"someone@example.social", SourceConfig{Type: "mastodon", Account: "someone@example.social"}},
+		{"@someone@example.social", SourceConfig{Type: "mastodon", Account: "someone@example.social"}},
+		{"https://t.me/news", SourceConfig{Type: "telegram", Channel: "news"}},
+		{"https://t.me/s/news/", SourceConfig{Type: "telegram", Channel: "news"}},
+		{"https://example.org/feed.xml", SourceConfig{Type: "feed", URL: "https://example.org/feed.xml"}},
+		{"feed https://example.org/feed.xml", SourceConfig{Type: "feed", URL: "https://example.org/feed.xml"}},
+	}
+	for _, tt := range tests {
+		got, err := parseSourceInput(tt.input)
+		if err != nil || got.Type != tt.want.Type || got.Account != tt.want.Account || got.Channel != tt.want.Channel || got.URL != tt.want.URL {
+			t.Errorf("%q: %+v, %v, want %+v", tt.input, got, err, tt.want)
+		}
+	}
+}
+
+// countingSource counts its fetches.
+type countingSource struct {
+	fixtureSource
+	fetches *int
+}
+
+func (s countingSource) Fetch() ([]twitter.Tweet, error) {
+	*s.fetches += 1
+	return s.fixtureSource.Fetch()
+}
+
+func TestManageAccounts(t *testing.T) {
+	admin, err := throwawayCert()
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(tempDir(t), "config.yml")
+	config := "source: aggregate\nsources:\n  - type: feed\n    name: Blog\n    url: https://example.org/feed.xml\n"
+	if err := ioutil.WriteFile(path, []byte(config), 0600); err != nil {
+		t.Fatal(err)
+	}
+	var c Config
+	if err := c.Parse(path); err != nil {
+		t.Fatal(err)
+	}
+	c.Auth.Fingerprints = []string{certFingerprint(admin.Leaf)}
+	h, err := NewHandler(c, Deps{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if status, meta, _ := do(t, h, "/admin/sources/add?someone%40example.social", admin.Leaf); status != 30 {
+		t.Fatalf("adding an account: %d %q", status, meta)
+	}
+	if status, meta, _ := do(t, h, "/admin/sources/add?other%40example.social", admin.Leaf); status != 30 {
+		t.Fatalf("adding a second account: %d %q", status, meta)
+	}
+	if status, meta, _ := do(t, h, "/admin/sources/add?other%40example.social", admin.Leaf); status != 10 || !strings.Contains(meta, "@other@example.social") {
+		t.Errorf("adding an account twice: %d %q", status, meta)
+	}
+	if status, meta, _ := do(t, h, "/admin/sources/pause/Blog", admin.Leaf); status != 30 {
+		t.Fatalf("pausing: %d %q", status, meta)
+	}
+	_, _, body := do(t, h, "/admin/sources", admin.Leaf)
+	for _, want := range []string{"## Blog\n\nfeed https://example.org/feed.xml\nPaused\n=> /admin/sources/resume/Blog Resume\n", "## Mastodon\n\nmastodon @someone@example.social\nMirroring\n", "## @other@example.social\n\nmastodon @other@example.social\n"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("/admin/sources misses %q:\n%s", want, body)
+		}
+	}
+
+	var saved Config
+	if err := saved.Parse(path); err != nil {
+		t.Fatal(err)
+	}
+	if len(saved.Sources) != 3 || !saved.Sources[0].Paused || saved.Sources[1].Account != "someone@example.social" {
+		t.Errorf("saved sources: %+v", saved.Sources)
+	}
+	if info, err := os.Stat(path); err != nil {
+		t.Error(err)
+	} else if info.Mode().Perm() != 0600 {
+		t.Errorf("saving made the config %v", info.Mode())
+	}
+
+	// A paused source isn't fetched from.
+	fetches := 0
+	s, _ := h.TweetCache.aggregate()
+	s.sources[0].Source = countingSource{fixtureSource{fixtureTweets(1)}, &fetches}
+	s.sources = s.sources[:1]
+	s.fetched["Blog"] = time.Time{}
+	if _, err := s.Fetch(); err != nil || fetches != 0 {
+		t.Errorf("fetching a paused source: %d fetches, %v", fetches, err)
+	}
+
+	if status, meta, _ := do(t, h, "/admin/sources/remove/Mastodon", admin.Leaf); status != 30 {
+		t.Fatalf("removing: %d %q", status, meta)
+	}
+	if _, _, body := do(t, h, "/admin/sources", admin.Leaf); strings.Contains(body, "someone@example.social") {
+		t.Errorf("removed account still listed:\n%s", body)
+	} else if !strings.Contains(body, "@other@example.social") {
+		t.Errorf("the other account went too:\n%s", body)
+	}
+}
+
+func TestChangeAccountsWhileServing(t *testing.T) {
+	admin, err := throwawayCert()
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := Config{Source: "aggregate", Sources: []SourceConfig{
+		{Type: "feed", Name: "Blog", URL: "https://example.org/feed.xml"},
+		{Type: "feed", Name: "News", URL: "https://example.org/news.xml"},
+	}}
+	c.Auth.Fingerprints = []string{certFingerprint(admin.Leaf)}
+	h, err := NewHandler(c, Deps{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 50; i += 1 {
+			r, err := NewRequest("/admin/sources", admin.Leaf)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			ReadBody(h.Handle(r))
+			h.TweetCache.liveConfig().refreshInterval()
+		}
+	}()
+	for i := 0; i < 20; i += 1 {
+		do(t, h, "/admin/sources/add?someone%40example.social", admin.Leaf)
+		do(t, h, "/admin/sources/pause/Blog", admin.Leaf)
+		do(t, h, "/admin/sources/remove/Mastodon", admin.Leaf)
+		do(t, h, "/admin/sources/resume/Blog", admin.Leaf)
+	}
+	<-done
+}
+
+func TestRemovedSourceHasNoOrigin(t *testing.T) {
+	c := Config{Source: "aggregate", Sources: []SourceConfig{{Type: "feed", Name: "Blog", URL: "https://example.org/feed.xml"}}}
+	h, err := NewHandler(c, Deps{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tc := h.TweetCache
+	kept := twitter.Tweet{ID: 1, IDStr: "https://example.org/1", Source: "Blog"}
+	if source, kind := tc.origin(kept); source == nil || kind != "feed" || tc.sourceName(kept) != "Blog" {
+		t.Errorf("post of a source: %v %q %q", source, kind, tc.sourceName(kept))
+	}
+	for _, tweet := range []twitter.Tweet{
+		{ID: 2, IDStr: "https://news.example.org/2", Source: "News"},
+		{ID: 3, IDStr: "3", Source: "<a>Twitter Web App</a>"},
+	} {
+		if source, kind := tc.origin(tweet); source != nil || kind != "" {
+			t.Errorf("post %d credited to %v (%s)", tweet.ID, source, kind)
+		}
+		if badge, name := tc.sourceBadge(tweet), tc.sourceName(tweet); badge != "" || name != "" {
+			t.Errorf("post %d labelled %q, via %q", tweet.ID, badge, name)
+		}
+	}
+}
@@ -0,0 +1,369 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dghubble/go-twitter/twitter"
+)
+
+// Posts taken from all aggregated sources per fetch, shared out by weight.
+const aggregateFetchLimit = 100
+
+// twitterEpoch is the zero point of tweet IDs, in Unix milliseconds.
+const twitterEpoch = 1288834974657
+
+type SourceConfig struct {
+	// twitter, telegram, feed or mastodon
+	Type string `yaml:"type"`
+	// Shown on each post, defaults to the source's own name
+	Name string `yaml:"name,omitempty"`
+	// Put in front of each post, defaults to an emoji for the type
+	Badge string `yaml:"badge,omitempty"`
+	// Relative share of each fetch, defaults to 1
+	Weight  int    `yaml:"weight,omitempty"`
+	Channel string `yaml:"channel,omitempty"`
+	URL     string `yaml:"url,omitempty"`
+	Account string `yaml:"account,omitempty"`
+
+	// Overrides refresh.intervalMinutes for this source
+	RefreshMinutes int `yaml:"refreshMinutes,omitempty"`
+	// Only keep posts in these languages
+	Languages Languages `yaml:"languages,omitempty"`
+	// Drop posts containing any of these words, ignoring case
+	Exclude []string `yaml:"exclude,omitempty"`
+	// Override ui.theme on the pages of this source's posts
+	Theme string `yaml:"theme,omitempty"`
+	// Heading on the pages of this source's posts, in place of the logo
+	Title string `yaml:"title,omitempty"`
+	// Stop fetching from this source, keeping its posts
+	Paused bool `yaml:"paused,omitempty"`
+}
+
+func (sc SourceConfig) interval(c Config) time.Duration {
+	if sc.RefreshMinutes > 0 {
+		return time.Duration(sc.RefreshMinutes) * time.Minute
+	}
+	return time.Duration(c.Refresh.IntervalMinutes) * time.Minute
+}
+
+// keep applies the source's filters to a post.
+func (sc SourceConfig) keep(tweet twitter.Tweet) bool {
+	if !sc.Languages.Contains(tweet) {
+		return false
+	}
+	text := strings.ToLower(expandLinks(tweet))
+	for _, word := range sc.Exclude {
+		if strings.Contains(text, strings.ToLower(word)) {
+			return false
+		}
+	}
+	return true
+}
+
+// refreshInterval is how often the refresher wakes up: the shortest
+// interval of any source.
+func (c Config) refreshInterval() time.Duration {
+	interval := time.Duration(c.Refresh.IntervalMinutes) * time.Minute
+	if c.Source != "aggregate" {
+		return interval
+	}
+	for _, sc := range c.Sources {
+		if i := sc.interval(c); i < interval && !sc.Paused {
+			interval = i
+		}
+	}
+	return interval
+}
+
+// config returns c set up to run this source on its own.
+func (sc SourceConfig) config(c Config) Config {
+	c.Source = sc.Type
+	switch sc.Type {
+	case "telegram":
+		c.Telegram.Channel = sc.Channel
+	case "feed":
+		c.Feed.URL = sc.URL
+	case "mastodon":
+		c.Mastodon.Account = sc.Account
+	}
+	return c
+}
+
+var defaultBadges = map[string]string{
+	"twitter":  "🐦",
+	"telegram": "✈️",
+	"feed":     "📰",
+	"mastodon": "🐘",
+}
+
+type aggregatedSource struct {
+	Source
+	kind     string
+	name     string
+	badge    string
+	weight   int
+	interval time.Duration
+	config   SourceConfig
+}
+
+// aggregateSource merges several sources into one timeline. Posts are
+// tagged with their source's name in Tweet.Source, and posts not from
+// Twitter get tweet-like IDs so the timeline sorts by date across sources.
+type aggregateSource struct {
+	sources []aggregatedSource
+	// When each source was last fetched from, by name
+	fetched map[string]time.Time
+}
+
+func (tc *TweetCache) newAggregateSource(sources []SourceConfig) (aggregateSource, error) {
+	s := aggregateSource{fetched: map[string]time.Time{}}
+	names := map[string]bool{}
+	for _, sc := range sources {
+		source, err := tc.sourceOfType(sc.Type, sc.config(tc.Config))
+		if err != nil {
+			return s, err
+		}
+		name := sc.Name
+		if name == "" {
+			name = source.Name()
+		}
+		if names[name] {
+			return s, duplicateSourceError{name}
+		}
+		names[name] = true
+		weight := sc.Weight
+		if weight <= 0 {
+			weight = 1
+		}
+		badge := sc.Badge
+		if badge == "" {
+			badge = defaultBadges[sc.Type]
+		}
+		s.sources = append(s.sources, aggregatedSource{source, sc.Type, name, badge, weight, sc.interval(tc.Config), sc})
+	}
+	return s, nil
+}
+
+// duplicateSourceError is returned for a second source of the same name.
+type duplicateSourceError struct {
+	name string
+}
+
+func (e duplicateSourceError) Error() string {
+	return fmt.Sprintf("two sources are called %q, give them distinct names", e.name)
+}
+
+// configsOf returns the configs the sources were made from.
+func configsOf(sources []aggregatedSource) []SourceConfig {
+	configs := make([]SourceConfig, len(sources))
+	for i, source := range sources {
+		configs[i] = source.config
+	}
+	return configs
+}
+
+func (s aggregateSource) Name() string {
+	var names []string
+	for _, source := range s.sources {
+		names = append(names, source.name)
+	}
+	return strings.Join(names, ", ")
+}
+
+// origin finds the source of an aggregated post. Posts without a tag came
+// in through the webhook or ingest endpoints and are credited to Twitter
+// if it's one of the sources. Posts of a source that has since been
+// removed have none.
+func (s aggregateSource) origin(tweet twitter.Tweet) (aggregatedSource, bool) {
+	for _, source := range s.sources {
+		if source.name == tweet.Source {
+			return source, true
+		}
+	}
+	// Posts from other sources were renumbered, keeping their own ID in
+	// IDStr.
+	if tweet.IDStr != "" && tweet.IDStr != strconv.FormatInt(tweet.ID, 10) {
+		return aggregatedSource{}, false
+	}
+	for _, source := range s.sources {
+		if source.kind == "twitter" {
+			return source, true
+		}
+	}
+	return aggregatedSource{}, false
+}
+
+func (s aggregateSource) URL(tweet twitter.Tweet) string {
+	if source, ok := s.origin(tweet); ok {
+		return source.URL(tweet)
+	}
+	return ""
+}
+
+func (s aggregateSource) Fetch() ([]twitter.Tweet, error) {
+	total := 0
+	for _, source := range s.sources {
+		total += source.weight
+	}
+
+	var tweets []twitter.Tweet
+	var failures []string
+	due := 0
+	for _, source := range s.sources {
+		if source.config.Paused || time.Since(s.fetched[source.name]) < source.interval {
+			continue
+		}
+		due += 1
+		fetched, err := source.Fetch()
+		if err != nil {
+			fmt.Printf("fetching from %s failed: %v\n", source.name, err)
+			failures = append(failures, fmt.Sprintf("%s: %v", source.name, err))
+			continue
+		}
+		s.fetched[source.name] = time.Now()
+		kept := fetched[:0]
+		for _, tweet := range fetched {
+			if source.config.keep(tweet) {
+				kept = append(kept, tweet)
+			}
+		}
+		fetched = kept
+		limit := aggregateFetchLimit * source.weight / total
+		if limit < 1 {
+			limit = 1
+		}
+		if len(fetched) > limit {
+			fetched = fetched[:limit]
+		}
+		if source.kind != "twitter" {
+			fetched = tweetIDs(source.name, fetched)
+		}
+		for i := range fetched {
+			fetched[i].Source = source.name
+		}
+		tweets = append(tweets, fetched...)
+	}
+	if due > 0 && len(failures) == due {
+		return nil, errors.New(strings.Join(failures, "; "))
+	}
+	sortNewestFirst(tweets)
+	return tweets, nil
+}
+
+// tweetIDs renumbers posts with IDs built the way tweet IDs are, from the
+// post's time and a hash of its source and original ID. The original ID
+// is kept in IDStr for linking back to the post.
+func tweetIDs(name string, tweets []twitter.Tweet) []twitter.Tweet {
+	renumbered := map[int64]int64{}
+	for i, tweet := range tweets {
+		created, err := tweet.CreatedAtTime()
+		if err != nil {
+			created = time.Now()
+		}
+		if tweet.IDStr == "" {
+			tweet.IDStr = strconv.FormatInt(tweet.ID, 10)
+		}
+		h := fnv.New32a()
+		h.Write([]byte(name + "/" + tweet.IDStr))
+		id := (created.UnixNano()/int64(time.Millisecond)-twitterEpoch)<<22 | int64(h.Sum32()&(1<<22-1))
+		renumbered[tweet.ID] = id
+		tweet.ID = id
+		tweets[i] = tweet
+	}
+	for i, tweet := range tweets {
+		if id, ok := renumbered[tweet.InReplyToStatusID]; ok {
+			tweets[i].InReplyToStatusID = id
+		}
+	}
+	return tweets
+}
+
+// currentSource returns the source posts are fetched from, which
+// /admin/sources can swap for another at any time.
+func (tc *TweetCache) currentSource() Source {
+	tc.sourceMu.RLock()
+	defer tc.sourceMu.RUnlock()
+	return tc.source
+}
+
+// liveConfig is tc.Config with the sources as they are now, after any
+// changes made at /admin/sources.
+func (tc *TweetCache) liveConfig() Config {
+	c := tc.Config
+	if s, ok := tc.aggregate(); ok {
+		c.Sources = configsOf(s.sources)
+	}
+	return c
+}
+
+// aggregate returns the aggregate source behind tc.source, which may be
+// shared through Redis.
+func (tc *TweetCache) aggregate() (aggregateSource, bool) {
+	source := tc.currentSource()
+	if shared, ok := source.(sharedSource); ok {
+		source = shared.Source
+	}
+	s, ok := source.(aggregateSource)
+	return s, ok
+}
+
+// origin returns the source a post came from, and its kind, or nil when
+// the source is gone.
+func (tc *TweetCache) origin(tweet twitter.Tweet) (Source, string) {
+	if s, ok := tc.aggregate(); ok {
+		source, ok := s.origin(tweet)
+		if !ok {
+			return nil, ""
+		}
+		return source, source.kind
+	}
+	return tc.currentSource(), tc.Config.Source
+}
+
+// sourceBadge and sourceVia label posts with their source when
+// aggregating.
+func (tc *TweetCache) sourceBadge(tweet twitter.Tweet) string {
+	s, ok := tc.aggregate()
+	if !ok || tc.Config.UI.Accessible {
+		return ""
+	}
+	source, ok := s.origin(tweet)
+	if !ok {
+		return ""
+	}
+	return source.badge + " "
+}
+
+func (tc *TweetCache) sourceVia(tweet twitter.Tweet) string {
+	if name := tc.sourceName(tweet); name != "" {
+		return "\nvia " + name
+	}
+	return ""
+}
+
+func (tc *TweetCache) sourceName(tweet twitter.Tweet) string {
+	s, ok := tc.aggregate()
+	if !ok {
+		return ""
+	}
+	source, _ := s.origin(tweet)
+	return source.name
+}
+
+// usesTwitter reports whether any configured source reads from Twitter.
+func (c Config) usesTwitter() bool {
+	if c.Source == "aggregate" {
+		for _, sc := range c.Sources {
+			if sc.Type == "twitter" {
+				return true
+			}
+		}
+		return false
+	}
+	return c.Source == "twitter"
+}
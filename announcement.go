@@ -0,0 +1,129 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/makeworld-the-better-one/go-gemini"
+)
+
+// Announcement is the operator's note at the top of every page, from the
+// config or set on /admin/announcement until the next restart.
+type Announcement struct {
+	mu    sync.Mutex
+	text  string
+	until time.Time
+}
+
+func (a *Announcement) Set(text string, until time.Time) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.text = strings.Join(strings.Fields(text), " ")
+	a.until = until
+}
+
+// Get returns the announcement and when it ends, zero for never. Past
+// then, there is none.
+func (a *Announcement) Get(now time.Time) (string, time.Time) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if !a.until.IsZero() && !now.Before(a.until) {
+		return "", time.Time{}
+	}
+	return a.text, a.until
+}
+
+func (c Config) announcementUntil() (time.Time, error) {
+	if c.Announcement.Until == "" {
+		return time.Time{}, nil
+	}
+	return time.Parse(time.RFC3339, c.Announcement.Until)
+}
+
+// expireAnnouncement renders pages again once an announcement ends, as
+// cached ones still show it.
+func (tc *TweetCache) expireAnnouncement(until time.Time) {
+	if wait := time.Until(until); !until.IsZero() && wait > 0 {
+		time.AfterFunc(wait, tc.changed)
+	}
+}
+
+func (tc *TweetCache) announce(text string, until time.Time) {
+	tc.announcement.Set(text, until)
+	tc.changed()
+	tc.expireAnnouncement(until)
+}
+
+func (rh *RequestHandler) formatAnnouncement() string {
+	text, _ := rh.TweetCache.announcement.Get(time.Now())
+	if text == "" {
+		return ""
+	}
+	return fmt.Sprintf("> %s%s\n\n", rh.Config.glyph("📢 ", "Announcement: "), text)
+}
+
+const announcementPrompt = "Announcement, optionally followed by | and when it ends, e.g. Moving on June 1 | 2024-06-01T00:00:00Z"
+
+// parseAnnouncement reads "text [| RFC 3339 end time]", as typed into the
+// announcement prompt. Only a time after the last " | " ends it, so the
+// text can have bars of its own.
+func parseAnnouncement(input string) (string, time.Time, error) {
+	var until time.Time
+	text := input
+	if i := strings.LastIndex(input, " | "); i >= 0 {
+		if t, err := time.Parse(time.RFC3339, strings.TrimSpace(input[i+3:])); err == nil {
+			if !t.After(time.Now()) {
+				return "", time.Time{}, errors.New("the end must be in the future")
+			}
+			text, until = input[:i], t
+		}
+	}
+	if strings.TrimSpace(text) == "" {
+		return "", time.Time{}, errors.New("the announcement is empty")
+	}
+	return text, until, nil
+}
+
+func (rh *RequestHandler) setAnnouncement(input string) *gemini.Response {
+	text, until, err := parseAnnouncement(input)
+	if err != nil {
+		return Input(fmt.Sprintf("%s. %s", err, announcementPrompt))
+	}
+	rh.TweetCache.announce(text, until)
+	return Redirect("/admin/announcement")
+}
+
+func (rh *RequestHandler) clearAnnouncement() *gemini.Response {
+	rh.TweetCache.announce("", time.Time{})
+	return Redirect("/admin/announcement")
+}
+
+func (rh *RequestHandler) formatAdminAnnouncement() string {
+	page := "# Announcement\n\n"
+	text, until := rh.TweetCache.announcement.Get(time.Now())
+	if text == "" {
+		page += "No announcement.\n"
+	} else {
+		page += fmt.Sprintf("> %s\n\n", text)
+		if until.IsZero() {
+			page += "Shown until cleared.\n"
+		} else {
+			page += fmt.Sprintf("Shown until %s.\n", until.UTC().Format("2006-01-02 15:04 MST"))
+		}
+	}
+	page += "\n=> /admin/announcement/set Set the announcement\n"
+	if text != "" {
+		page += "=> /admin/announcement/clear Clear it\n"
+	}
+	return page + "\nChanges here last until the next restart; set announcement in the config to keep one.\n\n=> /admin Back to admin\n"
+}
+
+func (rh *RequestHandler) showAdminAnnouncement() *gemini.Response {
+	body := ioutil.NopCloser(bytes.NewBufferString(rh.wrapBody(rh.formatAdminAnnouncement())))
+	return Success(rh.meta(), body)
+}
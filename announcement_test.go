@@ -0,0 +1,34 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseAnnouncement(t *testing.T) {
+	end := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+	tests := []struct {
+		input string
+		text  string
+		until time.Time
+		err   bool
+	}{
+		{"Moving soon", "Moving soon", time.Time{}, false},
+		{"Moving soon | " + end.Format(time.RFC3339), "Moving soon", end, false},
+		{"Pipes | and bars", "Pipes | and bars", time.Time{}, false},
+		{"a|b | c | " + end.Format(time.RFC3339), "a|b | c", end, false},
+		{"Ends |" + end.Format(time.RFC3339), "Ends |" + end.Format(time.RFC3339), time.Time{}, false},
+		{"Over | 2020-06-01T00:00:00Z", "", time.Time{}, true},
+		{" | " + end.Format(time.RFC3339), "", time.Time{}, true},
+	}
+	for _, tt := range tests {
+		text, until, err := parseAnnouncement(tt.input)
+		if (err != nil) != tt.err {
+			t.Errorf("%q: error %v, want error %v", tt.input, err, tt.err)
+			continue
+		}
+		if text != tt.text || !until.Equal(tt.until) {
+			t.Errorf("%q: got %q until %v, want %q until %v", tt.input, text, until, tt.text, tt.until)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+)
+
+const (
+	ansiReset     = "\x1b[0m"
+	ansiBold      = "\x1b[1m"
+	ansiDim       = "\x1b[2m"
+	ansiItalic    = "\x1b[3m"
+	ansiUnderline = "\x1b[4m"
+	ansiCyan      = "\x1b[36m"
+	ansiYellow    = "\x1b[33m"
+	ansiGreen     = "\x1b[32m"
+)
+
+func init() {
+	RegisterRenderer("ansi", Renderer{
+		MediaType: "text/plain",
+		Render: func(rh *RequestHandler, lines []Line) string {
+			return renderANSI(lines, rh.TweetCache.authorNames())
+		},
+		Enabled: func(c Config) bool { return c.UI.ANSI },
+	})
+}
+
+var timestampLine = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}\b`)
+
+func ansi(style, text string) string {
+	return style + text + ansiReset
+}
+
+// renderANSI is renderText with colors for terminals. Lines naming one of
+// authors are the bylines under tweets.
+func renderANSI(lines []Line, authors map[string]bool) string {
+	var b strings.Builder
+	for _, line := range lines {
+		line.Text, line.URL = printable(line.Text), printable(line.URL)
+		switch line.Type {
+		case LinkLine:
+			if line.Text == "" {
+				b.WriteString(ansi(ansiUnderline+ansiCyan, line.URL))
+			} else {
+				b.WriteString(ansi(ansiCyan, line.Text) + " " + ansi(ansiDim, "<"+line.URL+">"))
+			}
+		case HeadingLine:
+			style := ansiBold
+			if line.Level == 1 {
+				style += ansiUnderline
+			}
+			b.WriteString(ansi(style, line.Text))
+		case ListLine:
+			b.WriteString("• " + line.Text)
+		case QuoteLine:
+			b.WriteString(ansi(ansiItalic, "> "+line.Text))
+		case PreformatToggleLine:
+			continue
+		case PreformattedLine:
+			b.WriteString(line.Text)
+		default:
+			switch {
+			case authors[line.Text]:
+				b.WriteString(ansi(ansiGreen, line.Text))
+			case timestampLine.MatchString(line.Text):
+				b.WriteString(ansi(ansiYellow, line.Text))
+			default:
+				b.WriteString(line.Text)
+			}
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+func (tc *TweetCache) authorNames() map[string]bool {
+	names := map[string]bool{}
+	for _, tweet := range tc.Tweets {
+		if tweet.User != nil {
+			names[tweet.User.Name] = true
+		}
+	}
+	return names
+}
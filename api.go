@@ -0,0 +1,98 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strconv"
+	"time"
+
+	"github.com/dghubble/go-twitter/twitter"
+	"github.com/makeworld-the-better-one/go-gemini"
+)
+
+const apiJSON = "application/json"
+
+// The API's own shape for tweets, so consumers don't depend on Twitter's.
+type apiTweet struct {
+	ID        string    `json:"id"`
+	Permalink string    `json:"permalink"`
+	URL       string    `json:"url,omitempty"`
+	CreatedAt string    `json:"created_at,omitempty"`
+	Author    apiAuthor `json:"author"`
+	Text      string    `json:"text"`
+	Lang      string    `json:"lang,omitempty"`
+	Source    string    `json:"source,omitempty"`
+	InReplyTo string    `json:"in_reply_to,omitempty"`
+	Retweet   string    `json:"retweet_of,omitempty"`
+}
+
+type apiAuthor struct {
+	Name       string `json:"name"`
+	ScreenName string `json:"screen_name,omitempty"`
+}
+
+type apiTimeline struct {
+	Account string     `json:"account"`
+	Updated string     `json:"updated,omitempty"`
+	Tweets  []apiTweet `json:"tweets"`
+}
+
+func (rh *RequestHandler) apiTweet(tweet twitter.Tweet) apiTweet {
+	t := apiTweet{
+		ID:        strconv.FormatInt(tweet.ID, 10),
+		Permalink: rh.Config.absoluteLink(permalink(tweet)),
+		Text:      expandLinks(tweet),
+		Lang:      tweet.Lang,
+		Source:    rh.TweetCache.sourceName(tweet),
+	}
+	if source, _ := rh.TweetCache.origin(tweet); source != nil {
+		t.URL = source.URL(tweet)
+	}
+	if created, err := tweet.CreatedAtTime(); err == nil {
+		t.CreatedAt = created.UTC().Format(time.RFC3339)
+	}
+	if tweet.User != nil {
+		t.Author = apiAuthor{tweet.User.Name, tweet.User.ScreenName}
+	}
+	if tweet.InReplyToStatusID != 0 {
+		t.InReplyTo = strconv.FormatInt(tweet.InReplyToStatusID, 10)
+	}
+	if tweet.RetweetedStatus != nil {
+		t.Retweet = strconv.FormatInt(tweet.RetweetedStatus.ID, 10)
+	}
+	return t
+}
+
+func jsonResponse(v interface{}) *gemini.Response {
+	b, err := json.Marshal(v)
+	if err != nil {
+		fmt.Println(err)
+		return TempFailure("Failed to encode JSON")
+	}
+	return Success(apiJSON, ioutil.NopCloser(bytes.NewReader(b)))
+}
+
+// showAPITimeline lists the cached tweets newest first, within the same
+// ?from=&to= range /timeline takes.
+func (rh *RequestHandler) showAPITimeline(dr DateRange) *gemini.Response {
+	timeline := apiTimeline{Account: rh.TweetCache.liveConfig().account(), Tweets: []apiTweet{}}
+	if !rh.TweetCache.LastRefresh.IsZero() {
+		timeline.Updated = rh.TweetCache.LastRefresh.UTC().Format(time.RFC3339)
+	}
+	for _, tweet := range rh.TweetCache.Tweets {
+		if dr.Contains(tweet) {
+			timeline.Tweets = append(timeline.Tweets, rh.apiTweet(tweet))
+		}
+	}
+	return jsonResponse(timeline)
+}
+
+func (rh *RequestHandler) showAPITweet(id int64) *gemini.Response {
+	pos, err := rh.TweetCache.FindByID(id)
+	if err != nil {
+		return NotFound("Tweet not found")
+	}
+	return jsonResponse(rh.apiTweet(rh.TweetCache.Tweets[pos]))
+}
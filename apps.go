@@ -0,0 +1,64 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/dghubble/go-twitter/twitter"
+)
+
+// Twitter's "Rate limit exceeded" error code.
+const rateLimitErrorCode = 88
+
+type TwitterApp struct {
+	ConsumerKey    string `yaml:"consumerKey"`
+	ConsumerSecret string `yaml:"consumerSecret"`
+	AccessToken    string `yaml:"accessToken"`
+	AccessSecret   string `yaml:"accessSecret"`
+}
+
+// twitterApps lists the configured key sets, the top-level one first.
+func (c Config) twitterApps() []TwitterApp {
+	apps := []TwitterApp{{
+		ConsumerKey:    c.Twitter.ConsumerKey,
+		ConsumerSecret: c.Twitter.ConsumerSecret,
+		AccessToken:    c.Twitter.AccessToken,
+		AccessSecret:   c.Twitter.AccessSecret,
+	}}
+	return append(apps, c.Twitter.Apps...)
+}
+
+func (tc *TweetCache) currentApp() TwitterApp {
+	apps := tc.Config.twitterApps()
+	return apps[tc.app%len(apps)]
+}
+
+func isRateLimited(resp *http.Response, err error) bool {
+	if resp != nil && resp.StatusCode == http.StatusTooManyRequests {
+		return true
+	}
+	var apiErr twitter.APIError
+	if errors.As(err, &apiErr) {
+		for _, e := range apiErr.Errors {
+			if e.Code == rateLimitErrorCode {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// fetchTweets gets the timeline, moving on to the next app whenever the
+// current one is rate limited.
+func (tc *TweetCache) fetchTweets() ([]twitter.Tweet, *http.Response, error) {
+	apps := len(tc.Config.twitterApps())
+	for i := 0; ; i++ {
+		tweets, resp, err := tc.getTweets()
+		if !isRateLimited(resp, err) || i == apps-1 {
+			return tweets, resp, err
+		}
+		tc.app = (tc.app + 1) % apps
+		fmt.Printf("rate limited, switching to Twitter app %d of %d\n", tc.app+1, apps)
+	}
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/dghubble/go-twitter/twitter"
+)
+
+// Archive is everything the mirror has ever fetched, as persisted to
+// cache.archiveFile.
+type Archive struct {
+	Tweets []twitter.Tweet `json:"tweets"`
+	// Retweets lists the IDs of every retweet seen, keyed by the original
+	// status ID. Only the newest retweet of a status is kept in Tweets.
+	Retweets map[int64][]int64 `json:"retweets"`
+}
+
+func LoadArchive(path string) (*Archive, error) {
+	a := &Archive{Retweets: map[int64][]int64{}}
+	if path == "" {
+		return a, nil
+	}
+	b, err := ioutil.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return a, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, a); err != nil {
+		return nil, err
+	}
+	if a.Retweets == nil {
+		a.Retweets = map[int64][]int64{}
+	}
+	return a, nil
+}
+
+// Save writes the archive to a temporary file first so a crash never leaves
+// a truncated archive behind.
+func (a *Archive) Save(path string) error {
+	if path == "" {
+		return nil
+	}
+	b, err := json.Marshal(a)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path, b)
+}
+
+func writeFileAtomic(path string, b []byte) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path)+"-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// Merge adds fetched tweets to the archive. Tweets already archived are
+// replaced by their fresh copy, and repeated retweets of one status collapse
+// into the newest retweet. It reports whether anything shown on the pages
+// changed, as opposed to only counters like likes and retweets.
+//
+// Merge never modifies the previous Tweets slice or Retweets map, so readers
+// holding on to them are unaffected.
+func (a *Archive) Merge(fetched []twitter.Tweet) bool {
+	tweets := append([]twitter.Tweet(nil), a.Tweets...)
+	retweets := map[int64][]int64{}
+	for id, ids := range a.Retweets {
+		retweets[id] = ids
+	}
+
+	byID := map[int64]int{}
+	retweetOf := map[int64]int{}
+	for i, tweet := range tweets {
+		byID[tweet.ID] = i
+		if tweet.RetweetedStatus != nil {
+			retweetOf[tweet.RetweetedStatus.ID] = i
+		}
+	}
+
+	changed := false
+	dropped := map[int]bool{}
+	for _, tweet := range fetched {
+		if i, ok := byID[tweet.ID]; ok {
+			if expandLinks(tweets[i]) != expandLinks(tweet) || tweets[i].Lang != tweet.Lang {
+				changed = true
+			}
+			tweets[i] = tweet
+			continue
+		}
+		changed = true
+		if tweet.RetweetedStatus != nil {
+			original := tweet.RetweetedStatus.ID
+			if !containsID(retweets[original], tweet.ID) {
+				retweets[original] = append(retweets[original], tweet.ID)
+			}
+			if i, ok := retweetOf[original]; ok {
+				if tweets[i].ID > tweet.ID {
+					continue
+				}
+				dropped[i] = true
+			}
+			retweetOf[original] = len(tweets)
+		}
+		byID[tweet.ID] = len(tweets)
+		tweets = append(tweets, tweet)
+	}
+
+	if len(dropped) > 0 {
+		kept := tweets[:0]
+		for i, tweet := range tweets {
+			if !dropped[i] {
+				kept = append(kept, tweet)
+			}
+		}
+		tweets = kept
+	}
+	sortNewestFirst(tweets)
+
+	a.Tweets = tweets
+	a.Retweets = retweets
+	return changed
+}
+
+// Remove drops the given tweets from the archive, returning whether any were
+// archived. Like Merge, it leaves the previous Tweets slice untouched.
+func (a *Archive) Remove(ids []int64) bool {
+	var tweets []twitter.Tweet
+	for _, tweet := range a.Tweets {
+		if !containsID(ids, tweet.ID) {
+			tweets = append(tweets, tweet)
+		}
+	}
+	if len(tweets) == len(a.Tweets) {
+		return false
+	}
+	a.Tweets = tweets
+	return true
+}
+
+func sortNewestFirst(tweets []twitter.Tweet) {
+	sort.SliceStable(tweets, func(i, j int) bool { return tweets[i].ID > tweets[j].ID })
+}
+
+func containsID(ids []int64, id int64) bool {
+	for _, i := range ids {
+		if i == id {
+			return true
+		}
+	}
+	return false
+}
+
+// archived is every tweet kept, newest first: the archive, or the cache
+// when there is none.
+func (tc *TweetCache) archived() []twitter.Tweet {
+	if tc.archive != nil {
+		return tc.archive.Tweets
+	}
+	return tc.Tweets
+}
+
+// inMemory returns the newest archived tweets that fit within cache.maxTweets
+// and cache.maxAgeDays; older ones stay on disk only.
+func (c *Config) inMemory(tweets []twitter.Tweet) []twitter.Tweet {
+	if c.Cache.MaxTweets > 0 && len(tweets) > c.Cache.MaxTweets {
+		tweets = tweets[:c.Cache.MaxTweets]
+	}
+	if c.Cache.MaxAgeDays > 0 {
+		cutoff := time.Now().AddDate(0, 0, -c.Cache.MaxAgeDays)
+		for i, tweet := range tweets {
+			if t, err := tweet.CreatedAtTime(); err == nil && t.Before(cutoff) {
+				return tweets[:i]
+			}
+		}
+	}
+	return tweets
+}
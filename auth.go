@@ -0,0 +1,141 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/dghubble/go-twitter/twitter"
+	"github.com/dghubble/oauth1"
+	twauth "github.com/dghubble/oauth1/twitter"
+)
+
+// runAuth walks through Twitter's PIN-based OAuth flow and stores the
+// resulting access token in the config file.
+func runAuth(args []string) error {
+	fs := flag.NewFlagSet("auth", flag.ExitOnError)
+	path := fs.String("config", "config.yml", "Location of config file")
+	fs.Parse(args)
+
+	c := Config{}
+	if err := c.Parse(*path); err != nil {
+		return err
+	}
+	if c.Twitter.ConsumerKey == "" || c.Twitter.ConsumerSecret == "" {
+		return errors.New("auth: set twitter.consumerKey and twitter.consumerSecret first")
+	}
+
+	config := oauth1.Config{
+		ConsumerKey:    c.Twitter.ConsumerKey,
+		ConsumerSecret: c.Twitter.ConsumerSecret,
+		CallbackURL:    "oob",
+		Endpoint:       twauth.AuthorizeEndpoint,
+	}
+	requestToken, requestSecret, err := config.RequestToken()
+	if err != nil {
+		return fmt.Errorf("auth: requesting token: %v", err)
+	}
+	authURL, err := config.AuthorizationURL(requestToken)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Open this URL, authorize the app and paste the PIN below:\n\n%s\n\nPIN: ", authURL)
+	pin, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil {
+		return err
+	}
+	accessToken, accessSecret, err := config.AccessToken(requestToken, requestSecret, strings.TrimSpace(pin))
+	if err != nil {
+		return fmt.Errorf("auth: exchanging PIN: %v", err)
+	}
+
+	values := map[string]string{
+		"accessToken":  strconv.Quote(accessToken),
+		"accessSecret": strconv.Quote(accessSecret),
+	}
+	client := twitter.NewClient(config.Client(oauth1.NoContext, oauth1.NewToken(accessToken, accessSecret)))
+	user, _, err := client.Accounts.VerifyCredentials(&twitter.AccountVerifyParams{
+		SkipStatus: twitter.Bool(true),
+	})
+	if err != nil {
+		fmt.Println("could not look up the authorized account:", err)
+	} else if c.Twitter.UserID == 0 && c.Twitter.ScreenName == "" {
+		values["userID"] = strconv.FormatInt(user.ID, 10)
+		values["screenName"] = strconv.Quote(user.ScreenName)
+	}
+
+	data, err := ioutil.ReadFile(*path)
+	if err != nil {
+		return err
+	}
+	data = setYAMLValues(data, "twitter", values)
+	if err := ioutil.WriteFile(*path, data, 0600); err != nil {
+		return err
+	}
+	if user != nil {
+		fmt.Printf("authorized as @%s, ", user.ScreenName)
+	}
+	fmt.Printf("tokens written to %s\n", *path)
+	return nil
+}
+
+// setYAMLValues sets keys of a top-level section line by line, so the
+// comments and layout of a hand-written config survive. Keys nested
+// deeper in the section, under apps for example, are left alone.
+func setYAMLValues(data []byte, section string, values map[string]string) []byte {
+	lines := strings.Split(string(data), "\n")
+	start := -1
+	for i, line := range lines {
+		if strings.TrimRight(line, " \r") == section+":" {
+			start = i
+			break
+		}
+	}
+	if start == -1 {
+		lines = append(lines, section+":")
+		start = len(lines) - 1
+	}
+
+	// The section's keys are as indented as its first.
+	indent := ""
+	end := start + 1
+	for ; end < len(lines); end++ {
+		line := lines[end]
+		if line != "" && line[0] != ' ' && line[0] != '#' {
+			break
+		}
+		key := strings.TrimSpace(line)
+		if key == "" || key[0] == '#' {
+			continue
+		}
+		lineIndent := line[:len(line)-len(strings.TrimLeft(line, " "))]
+		if indent == "" {
+			indent = lineIndent
+		}
+		if i := strings.Index(key, ":"); i > 0 && lineIndent == indent {
+			key = key[:i]
+			if value, ok := values[key]; ok {
+				lines[end] = indent + key + ": " + value
+				delete(values, key)
+			}
+		}
+	}
+
+	if indent == "" {
+		indent = "  "
+	}
+	var missing []string
+	for key, value := range values {
+		missing = append(missing, indent+key+": "+value)
+	}
+	sort.Strings(missing)
+	lines = append(lines[:start+1], append(missing, lines[start+1:]...)...)
+	return []byte(strings.Join(lines, "\n"))
+}
@@ -0,0 +1,12 @@
+package main
+
+import "testing"
+
+func TestSetYAMLValuesLeavesNestedKeys(t *testing.T) {
+	config := "twitter:\n    # The app reading the timeline\n    apps:\n      - name: backup\n        accessToken: keep\n    accessToken: old\nui:\n  theme: dark\n"
+	got := string(setYAMLValues([]byte(config), "twitter", map[string]string{"accessToken": "new", "userID": "5"}))
+	want := "twitter:\n    userID: 5\n    # The app reading the timeline\n    apps:\n      - name: backup\n        accessToken: keep\n    accessToken: new\nui:\n  theme: dark\n"
+	if got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const backupArchiveName = "archive.json"
+
+// backupFile is a state file as named in a backup, and where the config
+// keeps it.
+type backupFile struct {
+	name string
+	path string
+	mode os.FileMode
+}
+
+// backupFiles are the state files the config has paths for.
+func (c Config) backupFiles() []backupFile {
+	var files []backupFile
+	for _, f := range []backupFile{
+		{backupArchiveName, c.Cache.ArchiveFile, 0644},
+		{"snapshots.json", c.Snapshots.File, 0644},
+		{"readstate.json", c.ReadState.File, 0644},
+		{"readerbookmarks.json", c.ReaderBookmarks.File, 0644},
+		{"comments.json", c.Comments.File, 0644},
+		{"activitypub-key.pem", c.ActivityPub.KeyFile, 0600},
+		{"activitypub-followers.json", c.ActivityPub.FollowersFile, 0644},
+	} {
+		if f.path != "" {
+			files = append(files, f)
+		}
+	}
+	return files
+}
+
+// check makes sure data is what f holds, so a corrupt backup can't
+// replace good state.
+func (f backupFile) check(data []byte) error {
+	switch {
+	case f.name == backupArchiveName:
+		var archive Archive
+		return json.Unmarshal(data, &archive)
+	case filepath.Ext(f.name) == ".pem":
+		if block, _ := pem.Decode(data); block == nil {
+			return errors.New("no PEM block")
+		}
+		return nil
+	}
+	if !json.Valid(data) {
+		return errors.New("not JSON")
+	}
+	return nil
+}
+
+// writeBackup writes the state files that exist to w as a gzipped tar,
+// returning their names.
+func writeBackup(c Config, w io.Writer) ([]string, error) {
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	var names []string
+	for _, f := range c.backupFiles() {
+		data, err := ioutil.ReadFile(f.path)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		err = tw.WriteHeader(&tar.Header{
+			Name:    f.name,
+			Mode:    int64(f.mode),
+			Size:    int64(len(data)),
+			ModTime: time.Now(),
+		})
+		if err != nil {
+			return nil, err
+		}
+		if _, err := tw.Write(data); err != nil {
+			return nil, err
+		}
+		names = append(names, f.name)
+	}
+	if len(names) == 0 {
+		return nil, errors.New("backup: none of the state files in the config exist")
+	}
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+	return names, gz.Close()
+}
+
+// restoreBackup puts the state files in a backup where the config keeps
+// them, returning their paths. Unless force is set, it refuses to
+// overwrite any.
+func restoreBackup(c Config, r io.Reader, force bool) ([]string, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	tr := tar.NewReader(gz)
+
+	// Read and check everything before writing anything, so a restore
+	// doesn't stop halfway.
+	contents := map[string][]byte{}
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		var data bytes.Buffer
+		if _, err := io.Copy(&data, tr); err != nil {
+			return nil, err
+		}
+		contents[hdr.Name] = data.Bytes()
+	}
+	var restore []backupFile
+	for _, f := range c.backupFiles() {
+		data, ok := contents[f.name]
+		if !ok {
+			continue
+		}
+		if err := f.check(data); err != nil {
+			return nil, fmt.Errorf("restore: %s in the backup is corrupt: %v", f.name, err)
+		}
+		if _, err := os.Stat(f.path); err == nil && !force {
+			return nil, fmt.Errorf("restore: %s already exists, use -force to overwrite it", f.path)
+		}
+		restore = append(restore, f)
+	}
+	if len(restore) == 0 {
+		return nil, errors.New("restore: backup contains none of the state files in the config")
+	}
+
+	var paths []string
+	for _, f := range restore {
+		if err := writeFileAtomic(f.path, contents[f.name]); err != nil {
+			return paths, err
+		}
+		if err := os.Chmod(f.path, f.mode); err != nil {
+			return paths, err
+		}
+		paths = append(paths, f.path)
+	}
+	return paths, nil
+}
+
+func runBackup(args []string) error {
+	fs := flag.NewFlagSet("backup", flag.ExitOnError)
+	path := fs.String("config", "config.yml", "Location of config file")
+	out := fs.String("o", "backup.tar.gz", "Where to write the backup")
+	fs.Parse(args)
+
+	c := Config{}
+	if err := c.Parse(*path); err != nil {
+		return err
+	}
+
+	f, err := os.Create(*out)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	names, err := writeBackup(c, f)
+	if err != nil {
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	fmt.Printf("wrote %s with %d files\n", *out, len(names))
+	return nil
+}
+
+func runRestore(args []string) error {
+	fs := flag.NewFlagSet("restore", flag.ExitOnError)
+	path := fs.String("config", "config.yml", "Location of config file")
+	in := fs.String("i", "backup.tar.gz", "Backup to restore")
+	force := fs.Bool("force", false, "Overwrite existing state files")
+	fs.Parse(args)
+
+	c := Config{}
+	if err := c.Parse(*path); err != nil {
+		return err
+	}
+
+	f, err := os.Open(*in)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	paths, err := restoreBackup(c, f, *force)
+	for _, p := range paths {
+		fmt.Printf("restored %s\n", p)
+	}
+	return err
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"runtime"
+	"testing"
+)
+
+// renderBench renders a page over a large cache. Rendering may allocate
+// at most bytesPerByte for every byte it outputs, which catches pages
+// built by appending to a string in a loop: those allocate the page
+// again with every tweet. 0 leaves it unchecked, for pages mostly
+// allocating to find their tweets rather than to render them.
+type renderBench struct {
+	name         string
+	bytesPerByte float64
+	render       func(rh *RequestHandler) string
+}
+
+var renderBenches = []renderBench{
+	{"Format", 4, func(rh *RequestHandler) string {
+		return rh.TweetCache.Format(rh.TweetCache.Tweets[0])
+	}},
+	{"formatTimeline", 16, func(rh *RequestHandler) string {
+		return rh.formatTimeline(DateRange{}, nil, "desc", 0)
+	}},
+	{"formatIndexEntries", 32, func(rh *RequestHandler) string {
+		return rh.formatIndexEntries(rh.TweetCache.Tweets)
+	}},
+	{"formatThread", 32, func(rh *RequestHandler) string {
+		thread := make([]int, len(rh.TweetCache.Tweets))
+		for i := range thread {
+			thread[i] = i
+		}
+		return rh.formatThread(thread, "asc")
+	}},
+	{"formatDigestIndex", 32, func(rh *RequestHandler) string {
+		return rh.formatDigestIndex()
+	}},
+	{"formatSearch", 0, func(rh *RequestHandler) string {
+		return rh.formatSearch("topic1", DateRange{})
+	}},
+	{"formatSavedSearch", 0, func(rh *RequestHandler) string {
+		return rh.formatSavedSearch("topic1", DateRange{})
+	}},
+	{"formatTags", 32, func(rh *RequestHandler) string {
+		return rh.formatTags()
+	}},
+}
+
+// BenchmarkRender renders each page over 2000 tweets, failing those over
+// their allocation budget. Run with go test -bench Render.
+func BenchmarkRender(b *testing.B) {
+	tweets := fixtureTweets(2000)
+	rh, err := NewHandler(Config{}, Deps{Source: fixtureSource{tweets}, Tweets: tweets})
+	if err != nil {
+		b.Fatal(err)
+	}
+	for _, bench := range renderBenches {
+		bench := bench
+		b.Run(bench.name, func(b *testing.B) {
+			size := len(bench.render(rh))
+			b.ReportAllocs()
+			var before, after runtime.MemStats
+			runtime.ReadMemStats(&before)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				bench.render(rh)
+			}
+			b.StopTimer()
+			runtime.ReadMemStats(&after)
+			ratio := float64(after.TotalAlloc-before.TotalAlloc) / float64(b.N) / float64(size)
+			b.ReportMetric(ratio, "B/B")
+			if bench.bytesPerByte > 0 && ratio > bench.bytesPerByte {
+				b.Errorf("allocates %.1f B for every byte out, over the budget of %.0f", ratio, bench.bytesPerByte)
+			}
+		})
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"strings"
+	"unicode"
+)
+
+const (
+	rightToLeftIsolate    = '\u2067'
+	popDirectionalIsolate = '\u2069'
+)
+
+func isRTL(r rune) bool {
+	return (r >= 0x0590 && r <= 0x08FF) ||
+		(r >= 0xFB1D && r <= 0xFDFF) ||
+		(r >= 0xFE70 && r <= 0xFEFF) ||
+		(r >= 0x10800 && r <= 0x10FFF) ||
+		(r >= 0x1E800 && r <= 0x1EFFF)
+}
+
+func isStrongLTR(r rune) bool {
+	return unicode.IsLetter(r) && !isRTL(r)
+}
+
+// isolateRTL wraps every right-to-left run of a line in RLI … PDI so that
+// clients don't reorder the surrounding left-to-right text. A run spans from
+// its first to its last RTL character, taking in any neutral characters
+// (spaces, digits, punctuation) in between.
+func isolateRTL(line string) string {
+	runes := []rune(line)
+	var b strings.Builder
+	for i := 0; i < len(runes); {
+		if !isRTL(runes[i]) {
+			b.WriteRune(runes[i])
+			i += 1
+			continue
+		}
+		end := i
+		for j := i; j < len(runes) && !isStrongLTR(runes[j]); j += 1 {
+			if isRTL(runes[j]) {
+				end = j
+			}
+		}
+		b.WriteRune(rightToLeftIsolate)
+		b.WriteString(string(runes[i : end+1]))
+		b.WriteRune(popDirectionalIsolate)
+		i = end + 1
+	}
+	return b.String()
+}
+
+func (c *Config) isolateBidi(text string) string {
+	if !c.UI.BidiIsolate {
+		return text
+	}
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		lines[i] = isolateRTL(line)
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/makeworld-the-better-one/go-gemini"
+)
+
+// getV2 calls a v2 endpoint with the OAuth 2.0 user token, which is the
+// only way to read bookmarks.
+func (tc *TweetCache) getV2(endpoint string, params url.Values) (*http.Response, error) {
+	req, err := http.NewRequest("GET", twitterAPIv2+endpoint+"?"+params.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+tc.Config.Bookmarks.BearerToken)
+	client := http.Client{Transport: tc.twitterTransport(nil)}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("twitter: %s returned %s", endpoint, resp.Status)
+	}
+	return resp, nil
+}
+
+func (tc *TweetCache) bookmarksUserID() (string, error) {
+	if tc.Config.Twitter.UserID != 0 {
+		return strconv.FormatInt(tc.Config.Twitter.UserID, 10), nil
+	}
+	resp, err := tc.getV2("users/me", url.Values{})
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	var me struct {
+		Data struct {
+			ID string `json:"id"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&me); err != nil {
+		return "", err
+	}
+	return me.Data.ID, nil
+}
+
+func (tc *TweetCache) fetchBookmarks() ([]v2Tweet, error) {
+	id, err := tc.bookmarksUserID()
+	if err != nil {
+		return nil, err
+	}
+	params := url.Values{}
+	params.Set("max_results", "100")
+	resp, err := tc.getV2("users/"+id+"/bookmarks", v2TweetFields(params))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	return decodeV2Tweets(resp.Body)
+}
+
+func (rh *RequestHandler) formatBookmarks() string {
+	page := "# Bookmarks\n"
+	if len(rh.TweetCache.Bookmarks) == 0 {
+		return page + "\nNo bookmarks."
+	}
+	for _, t := range rh.TweetCache.Bookmarks {
+		page += rh.formatV2Tweet(t)
+	}
+	return page
+}
+
+func (rh *RequestHandler) showBookmarks() *gemini.Response {
+	body := ioutil.NopCloser(bytes.NewBufferString(rh.wrapBody(rh.formatBookmarks())))
+	return Success(rh.meta(), body)
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dghubble/go-twitter/twitter"
+	"github.com/makeworld-the-better-one/go-gemini"
+)
+
+// Downloads of the whole archive, with their media types.
+var bundles = map[string]string{
+	"/archive.gmi":    "text/gemini; charset=utf-8",
+	"/archive.gmi.gz": "application/gzip",
+	"/archive.gpub":   "application/gpub+zip",
+}
+
+type builtBundle struct {
+	generation uint64
+	body       []byte
+}
+
+// BundleCache keeps each download, compressed where it applies, until the
+// tweet cache moves to a new generation, so repeated downloads cost no
+// rendering or compression.
+type BundleCache struct {
+	mu      sync.Mutex
+	bundles map[string]builtBundle
+}
+
+func (bc *BundleCache) Get(path string, generation uint64, build func() ([]byte, error)) ([]byte, error) {
+	bc.mu.Lock()
+	defer bc.mu.Unlock()
+	if b, ok := bc.bundles[path]; ok && b.generation == generation {
+		return b.body, nil
+	}
+	body, err := build()
+	if err != nil {
+		return nil, err
+	}
+	if bc.bundles == nil {
+		bc.bundles = map[string]builtBundle{}
+	}
+	bc.bundles[path] = builtBundle{generation, body}
+	return body, nil
+}
+
+// archivedTweets is everything archived, or the cache when there is no
+// archive.
+func (tc *TweetCache) archivedTweets() []twitter.Tweet {
+	if tc.archive != nil && len(tc.archive.Tweets) > 0 {
+		return tc.archive.Tweets
+	}
+	return tc.Tweets
+}
+
+// absoluteLink makes links inside downloads work offline, when the
+// capsule's public URL is known.
+func (c *Config) absoluteLink(path string) string {
+	if c.Addr.URL == "" {
+		return path
+	}
+	return strings.TrimSuffix(c.Addr.URL, "/") + path
+}
+
+func (rh *RequestHandler) formatBundleTweet(tweet twitter.Tweet) string {
+	heading := ""
+	if t, err := tweet.CreatedAtTime(); err == nil {
+		heading = t.Format("2006-01-02 15:04")
+	}
+	return fmt.Sprintf("### %s\n\n%s\n=> %s Permalink\n\n", heading, rh.TweetCache.Format(tweet), rh.Config.absoluteLink(permalink(tweet)))
+}
+
+// bundleMonths groups tweets by month, oldest first, with tweets in each
+// month oldest first too.
+func bundleMonths(tweets []twitter.Tweet) ([]string, map[string][]twitter.Tweet) {
+	var months []string
+	byMonth := map[string][]twitter.Tweet{}
+	for i := len(tweets) - 1; i >= 0; i -= 1 {
+		t, err := tweets[i].CreatedAtTime()
+		if err != nil {
+			continue
+		}
+		month := t.Format("2006-01")
+		if _, ok := byMonth[month]; !ok {
+			months = append(months, month)
+		}
+		byMonth[month] = append(byMonth[month], tweets[i])
+	}
+	return months, byMonth
+}
+
+func (rh *RequestHandler) bundleTitle() string {
+	return "Tweets of " + rh.TweetCache.liveConfig().account()
+}
+
+func (rh *RequestHandler) buildArchiveGemtext() []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %s\n\n", rh.bundleTitle())
+	months, byMonth := bundleMonths(rh.TweetCache.archivedTweets())
+	for _, month := range months {
+		fmt.Fprintf(&b, "## %s\n\n", month)
+		for _, tweet := range byMonth[month] {
+			b.WriteString(rh.formatBundleTweet(tweet))
+		}
+	}
+	return []byte(b.String())
+}
+
+func gzipBytes(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	gz, err := gzip.NewWriterLevel(&buf, gzip.BestCompression)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := gz.Write(data); err != nil {
+		return nil, err
+	}
+	if err := gz.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// buildGempub packs the archive as a Gempub book with a chapter per month.
+func (rh *RequestHandler) buildGempub() ([]byte, error) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	add := func(name, content string) error {
+		w, err := zw.Create(name)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write([]byte(content))
+		return err
+	}
+
+	months, byMonth := bundleMonths(rh.TweetCache.archivedTweets())
+	metadata := fmt.Sprintf("title: %s\ngpubVersion: 1.0.0\nindex: index.gmi\nauthor: %s\npublishDate: %s\n",
+		rh.bundleTitle(), rh.TweetCache.liveConfig().account(), time.Now().UTC().Format(dateLayout))
+	if err := add("metadata.txt", metadata); err != nil {
+		return nil, err
+	}
+	var index strings.Builder
+	fmt.Fprintf(&index, "# %s\n\n", rh.bundleTitle())
+	for _, month := range months {
+		fmt.Fprintf(&index, "=> %s.gmi %s (%s)\n", month, month, countNoun(len(byMonth[month]), "tweet"))
+	}
+	if err := add("index.gmi", index.String()); err != nil {
+		return nil, err
+	}
+	for _, month := range months {
+		var chapter strings.Builder
+		fmt.Fprintf(&chapter, "# %s\n\n", month)
+		for _, tweet := range byMonth[month] {
+			chapter.WriteString(rh.formatBundleTweet(tweet))
+		}
+		if err := add(month+".gmi", chapter.String()); err != nil {
+			return nil, err
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (rh *RequestHandler) buildBundle(path string) ([]byte, error) {
+	switch path {
+	case "/archive.gmi":
+		return rh.buildArchiveGemtext(), nil
+	case "/archive.gmi.gz":
+		return gzipBytes(rh.buildArchiveGemtext())
+	case "/archive.gpub":
+		return rh.buildGempub()
+	}
+	return nil, fmt.Errorf("unknown bundle %s", path)
+}
+
+func (rh *RequestHandler) handleBundle(r Request, p Params) *gemini.Response {
+	path := r.URL.Path
+	body, err := rh.bundles.Get(path, rh.TweetCache.Generation(), func() ([]byte, error) {
+		return rh.buildBundle(path)
+	})
+	if err != nil {
+		fmt.Println(err)
+		return TempFailure("Failed to build download")
+	}
+	return Success(bundles[path], ioutil.NopCloser(bytes.NewReader(body)))
+}
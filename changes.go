@@ -0,0 +1,113 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"time"
+
+	"github.com/dghubble/go-twitter/twitter"
+	"github.com/makeworld-the-better-one/go-gemini"
+)
+
+type tweetEdit struct {
+	Before, After twitter.Tweet
+}
+
+// RefreshChanges is what the last refresh did to the archive.
+type RefreshChanges struct {
+	At      time.Time
+	Fetched int
+	New     []twitter.Tweet
+	Edited  []tweetEdit
+	// In the previous fetch from a source and within the range of this
+	// one, yet missing from it: most likely deleted. The archive keeps
+	// them.
+	Vanished []twitter.Tweet
+}
+
+// diffFetch compares a fetch with the archive and with the previous fetch,
+// source by source as sourceOf names them: aggregated sources that weren't
+// due bring nothing, which doesn't mean their posts are gone. It also
+// returns the latest fetch of every source, to compare the next one with.
+func diffFetch(archived, previous, fetched []twitter.Tweet, sourceOf func(twitter.Tweet) string) (RefreshChanges, []twitter.Tweet) {
+	changes := RefreshChanges{At: time.Now(), Fetched: len(fetched)}
+	known := map[int64]twitter.Tweet{}
+	for _, tweet := range archived {
+		known[tweet.ID] = tweet
+	}
+	inFetch := map[int64]bool{}
+	// The oldest post fetched from each source
+	oldest := map[string]int64{}
+	for _, tweet := range fetched {
+		inFetch[tweet.ID] = true
+		source := sourceOf(tweet)
+		if id, ok := oldest[source]; !ok || tweet.ID < id {
+			oldest[source] = tweet.ID
+		}
+		before, ok := known[tweet.ID]
+		if !ok {
+			changes.New = append(changes.New, tweet)
+		} else if expandLinks(before) != expandLinks(tweet) || before.Lang != tweet.Lang {
+			changes.Edited = append(changes.Edited, tweetEdit{before, tweet})
+		}
+	}
+	latest := append([]twitter.Tweet(nil), fetched...)
+	for _, tweet := range previous {
+		id, ok := oldest[sourceOf(tweet)]
+		if !ok {
+			latest = append(latest, tweet)
+		} else if tweet.ID >= id && !inFetch[tweet.ID] {
+			changes.Vanished = append(changes.Vanished, tweet)
+		}
+	}
+	return changes, latest
+}
+
+// preformatted puts text in a preformatted block, indented so none of its
+// lines can end the block early.
+func preformatted(alt, text string) string {
+	return fmt.Sprintf("```%s\n %s\n```\n", alt, strings.Replace(text, "\n", "\n ", -1))
+}
+
+func (rh *RequestHandler) formatChangedTweet(tweet twitter.Tweet) string {
+	date := ""
+	if t, err := tweet.CreatedAtTime(); err == nil {
+		date = t.Format("2006-01-02 15:04") + " "
+	}
+	return fmt.Sprintf("=> %s %s%s\n", permalink(tweet), date, entryTitle(tweet))
+}
+
+func (rh *RequestHandler) formatChanges() string {
+	page := "# Changes in the last refresh\n\n"
+	changes := rh.TweetCache.changes
+	if changes == nil {
+		return page + "No refresh since startup.\n\n=> /admin Back to admin\n"
+	}
+	page += fmt.Sprintf("Refreshed %s, fetched %d\n", changes.At.Format(time.RFC3339), changes.Fetched)
+
+	page += fmt.Sprintf("\n## New (%d)\n\n", len(changes.New))
+	for _, tweet := range changes.New {
+		page += rh.formatChangedTweet(tweet)
+	}
+	page += fmt.Sprintf("\n## Edited (%d)\n", len(changes.Edited))
+	for _, edit := range changes.Edited {
+		page += "\n" + rh.formatChangedTweet(edit.After)
+		if edit.Before.Lang != edit.After.Lang {
+			page += fmt.Sprintf("Language: %s → %s\n", edit.Before.Lang, edit.After.Lang)
+		}
+		// Raw text, so whitespace and link expansion differences show.
+		page += preformatted("before", expandLinks(edit.Before)) + preformatted("after", expandLinks(edit.After))
+	}
+	page += fmt.Sprintf("\n## Vanished (%d)\n\n", len(changes.Vanished))
+	for _, tweet := range changes.Vanished {
+		page += rh.formatChangedTweet(tweet)
+	}
+	return page + "\n=> /admin Back to admin\n"
+}
+
+func (rh *RequestHandler) showChanges() *gemini.Response {
+	body := ioutil.NopCloser(bytes.NewBufferString(rh.wrapBody(rh.formatChanges())))
+	return Success(rh.meta(), body)
+}
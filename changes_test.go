@@ -0,0 +1,64 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/dghubble/go-twitter/twitter"
+)
+
+func TestDiffFetchBySource(t *testing.T) {
+	post := func(id int64, source string) twitter.Tweet {
+		return twitter.Tweet{ID: id, Source: source, Text: "post"}
+	}
+	sourceOf := func(tweet twitter.Tweet) string { return tweet.Source }
+	previous := []twitter.Tweet{post(40, "a"), post(30, "b"), post(20, "a"), post(10, "b")}
+
+	// Only a was due: b's posts aren't vanished, and stay to compare its
+	// next fetch with.
+	changes, latest := diffFetch(previous, previous, []twitter.Tweet{post(50, "a"), post(20, "a")}, sourceOf)
+	if len(changes.New) != 1 || changes.New[0].ID != 50 {
+		t.Errorf("new: %v", changes.New)
+	}
+	if len(changes.Vanished) != 1 || changes.Vanished[0].ID != 40 {
+		t.Errorf("vanished: %v", changes.Vanished)
+	}
+	var ids []int64
+	for _, tweet := range latest {
+		ids = append(ids, tweet.ID)
+	}
+	if want := []int64{50, 20, 30, 10}; len(ids) != len(want) || ids[0] != 50 || ids[1] != 20 || ids[2] != 30 || ids[3] != 10 {
+		t.Errorf("latest: %v, want %v", ids, want)
+	}
+
+	changes, _ = diffFetch(previous, latest, []twitter.Tweet{post(10, "b")}, sourceOf)
+	if len(changes.Vanished) != 1 || changes.Vanished[0].ID != 30 {
+		t.Errorf("vanished from b: %v", changes.Vanished)
+	}
+}
+
+func TestChangesPreformatted(t *testing.T) {
+	admin, err := throwawayCert()
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := Config{}
+	c.Auth.Fingerprints = []string{certFingerprint(admin.Leaf)}
+	tweets := fixtureTweets(3)
+	h, err := NewHandler(c, Deps{Source: fixtureSource{tweets}, Tweets: tweets})
+	if err != nil {
+		t.Fatal(err)
+	}
+	edited := append([]twitter.Tweet(nil), tweets...)
+	edited[0].Text = "code:\n```\n=> gemini://example.org/ not a link"
+	h.TweetCache.source = fixtureSource{edited}
+	if err := h.TweetCache.refresh(); err != nil {
+		t.Fatal(err)
+	}
+
+	_, _, body := do(t, h, "/admin/changes", admin.Leaf)
+	want := "```after\n code:\n ```\n => gemini://example.org/ not a link\n```\n"
+	if !strings.Contains(body, want) {
+		t.Errorf("/admin/changes misses %q:\n%s", want, body)
+	}
+}
@@ -0,0 +1,357 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/makeworld-the-better-one/go-gemini"
+)
+
+const (
+	maxCommenterName = 40
+	// Comments listed for moderation on /admin/comments
+	recentComments = 50
+)
+
+// Comment is left on a tweet by a reader, known by their client
+// certificate.
+type Comment struct {
+	ID          int64     `json:"id"`
+	Tweet       int64     `json:"tweet"`
+	Fingerprint string    `json:"fingerprint"`
+	Name        string    `json:"name"`
+	Text        string    `json:"text"`
+	Time        time.Time `json:"time"`
+	// Waiting for approval, with comments.moderate
+	Pending bool `json:"pending,omitempty"`
+}
+
+// Comments are kept oldest first. Banned certificates can't comment, and
+// are listed by the name they last commented under.
+type Comments struct {
+	mu       sync.Mutex
+	LastID   int64             `json:"lastID"`
+	Comments []Comment         `json:"comments"`
+	Banned   map[string]string `json:"banned"`
+}
+
+func LoadComments(path string) (*Comments, error) {
+	cs := &Comments{Banned: map[string]string{}}
+	if path == "" {
+		return cs, nil
+	}
+	b, err := ioutil.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return cs, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, cs); err != nil {
+		return nil, err
+	}
+	if cs.Banned == nil {
+		cs.Banned = map[string]string{}
+	}
+	return cs, nil
+}
+
+func (cs *Comments) Save(path string) error {
+	if path == "" {
+		return nil
+	}
+	cs.mu.Lock()
+	b, err := json.Marshal(cs)
+	cs.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path, b)
+}
+
+// On returns the approved comments on a tweet, oldest first.
+func (cs *Comments) On(tweet int64) []Comment {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	var comments []Comment
+	for _, c := range cs.Comments {
+		if c.Tweet == tweet && !c.Pending {
+			comments = append(comments, c)
+		}
+	}
+	return comments
+}
+
+// Recent returns the newest n approved comments on any tweet, newest
+// first.
+func (cs *Comments) Recent(n int) []Comment {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	var comments []Comment
+	for i := len(cs.Comments) - 1; i >= 0 && len(comments) < n; i-- {
+		if !cs.Comments[i].Pending {
+			comments = append(comments, cs.Comments[i])
+		}
+	}
+	return comments
+}
+
+// Pending returns the comments waiting for approval, oldest first.
+func (cs *Comments) Pending() []Comment {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	var comments []Comment
+	for _, c := range cs.Comments {
+		if c.Pending {
+			comments = append(comments, c)
+		}
+	}
+	return comments
+}
+
+func (cs *Comments) Add(c Comment) Comment {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	cs.LastID += 1
+	c.ID = cs.LastID
+	cs.Comments = append(cs.Comments, c)
+	return c
+}
+
+// Approve reports whether comment id was waiting for approval.
+func (cs *Comments) Approve(id int64) bool {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	for i, c := range cs.Comments {
+		if c.ID == id && c.Pending {
+			cs.Comments[i].Pending = false
+			return true
+		}
+	}
+	return false
+}
+
+// Remove reports whether there was a comment id.
+func (cs *Comments) Remove(id int64) bool {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	for i, c := range cs.Comments {
+		if c.ID == id {
+			cs.Comments = append(cs.Comments[:i], cs.Comments[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// Ban stops the author of comment id from commenting and removes all
+// their comments, reporting whether there was such a comment.
+func (cs *Comments) Ban(id int64) bool {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	fingerprint := ""
+	for _, c := range cs.Comments {
+		if c.ID == id {
+			fingerprint = c.Fingerprint
+			cs.Banned[fingerprint] = c.Name
+		}
+	}
+	if fingerprint == "" {
+		return false
+	}
+	var comments []Comment
+	for _, c := range cs.Comments {
+		if c.Fingerprint != fingerprint {
+			comments = append(comments, c)
+		}
+	}
+	cs.Comments = comments
+	return true
+}
+
+// Unban reports whether fingerprint was banned.
+func (cs *Comments) Unban(fingerprint string) bool {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	_, ok := cs.Banned[fingerprint]
+	delete(cs.Banned, fingerprint)
+	return ok
+}
+
+func (cs *Comments) IsBanned(fingerprint string) bool {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	_, ok := cs.Banned[fingerprint]
+	return ok
+}
+
+// oneLine folds text onto a single line, so it can't break out of the
+// quote it's shown in, and cuts it to max runes.
+func oneLine(text string, max int) string {
+	text = strings.Join(strings.Fields(text), " ")
+	if runes := []rune(text); len(runes) > max {
+		text = string(runes[:max])
+	}
+	return text
+}
+
+// commenter is the name a reader's comments go by: the common name of
+// their certificate or, without one, the start of its fingerprint.
+func commenter(r Request) string {
+	if len(r.Certificates) > 0 {
+		if name := oneLine(r.Certificates[0].Subject.CommonName, maxCommenterName); name != "" {
+			return name
+		}
+	}
+	return r.Fingerprint()[:8]
+}
+
+func (rh *RequestHandler) saveComments() {
+	if err := rh.TweetCache.comments.Save(rh.Config.Comments.File); err != nil {
+		fmt.Println(err)
+	}
+	// Tweet pages show how many comments there are.
+	rh.TweetCache.changed()
+}
+
+const commentPrompt = "Your comment"
+
+func (rh *RequestHandler) addComment(r Request, id int64, input string) *gemini.Response {
+	if _, ok := rh.TweetCache.lookup(id); !ok {
+		return NotFound("Tweet not found")
+	}
+	text := strings.Join(strings.Fields(input), " ")
+	if text == "" {
+		return Input(commentPrompt)
+	}
+	if n := len([]rune(text)); n > rh.Config.Comments.MaxLength {
+		return Input(fmt.Sprintf("Comments are up to %d characters, that was %d", rh.Config.Comments.MaxLength, n))
+	}
+	if !rh.commentLimit.Allow(r.Fingerprint()) {
+		return rh.commentLimit.slowDown()
+	}
+	rh.TweetCache.comments.Add(Comment{
+		Tweet:       id,
+		Fingerprint: r.Fingerprint(),
+		Name:        commenter(r),
+		Text:        text,
+		Time:        time.Now().UTC(),
+		Pending:     rh.Config.Comments.Moderate,
+	})
+	rh.saveComments()
+	return Redirect(fmt.Sprintf("/tweet/%d/comments", id))
+}
+
+func (rh *RequestHandler) removeComment(id int64) *gemini.Response {
+	if !rh.TweetCache.comments.Remove(id) {
+		return NotFound("No such comment")
+	}
+	rh.saveComments()
+	return Redirect("/admin/comments")
+}
+
+func (rh *RequestHandler) approveComment(id int64) *gemini.Response {
+	if !rh.TweetCache.comments.Approve(id) {
+		return NotFound("No such comment waiting for approval")
+	}
+	rh.saveComments()
+	return Redirect("/admin/comments")
+}
+
+func (rh *RequestHandler) banCommenter(id int64) *gemini.Response {
+	if !rh.TweetCache.comments.Ban(id) {
+		return NotFound("No such comment")
+	}
+	rh.saveComments()
+	return Redirect("/admin/comments")
+}
+
+func (rh *RequestHandler) unbanCommenter(fingerprint string) *gemini.Response {
+	if !rh.TweetCache.comments.Unban(fingerprint) {
+		return NotFound("No such ban")
+	}
+	rh.saveComments()
+	return Redirect("/admin/comments")
+}
+
+func formatComment(c Comment) string {
+	return fmt.Sprintf("\n\n### %s · %s\n\n> %s", c.Name, c.Time.Format("2006-01-02 15:04"), c.Text)
+}
+
+func (rh *RequestHandler) formatComments(id int64) (string, error) {
+	tweet, ok := rh.TweetCache.lookup(id)
+	if !ok {
+		return "", errors.New("tweet not found")
+	}
+	var page strings.Builder
+	page.WriteString("# Comments")
+	page.WriteString(rh.formatEntry(tweet, rh.TweetCache.Format(tweet)))
+	comments := rh.TweetCache.comments.On(id)
+	if len(comments) == 0 {
+		page.WriteString("\n\nNo comments yet.")
+	}
+	for _, c := range comments {
+		page.WriteString(formatComment(c))
+	}
+	fmt.Fprintf(&page, "\n\n=> /tweet/%d/comments/add Leave a comment (needs a client certificate)", id)
+	if rh.Config.Comments.Moderate {
+		page.WriteString("\n\nNew comments show here once approved.")
+	}
+	return page.String(), nil
+}
+
+func (rh *RequestHandler) showComments(id int64) *gemini.Response {
+	page, err := rh.formatComments(id)
+	if err != nil {
+		return NotFound("Tweet not found")
+	}
+	body := ioutil.NopCloser(bytes.NewBufferString(rh.wrapBody(page)))
+	return Success(rh.meta(), body)
+}
+
+func (rh *RequestHandler) formatAdminComments() string {
+	cs := rh.TweetCache.comments
+	page := "# Comments\n"
+	if pending := cs.Pending(); len(pending) > 0 {
+		page += "\n## Waiting for approval\n"
+		for _, c := range pending {
+			page += formatComment(c)
+			page += fmt.Sprintf("\n=> /tweet/%d/comments On tweet %d\n=> /admin/comments/approve/%d Approve\n=> /admin/comments/remove/%d Remove\n=> /admin/comments/ban/%d Ban %s and remove all their comments\n", c.Tweet, c.Tweet, c.ID, c.ID, c.ID, c.Name)
+		}
+	}
+	page += "\n## Recent\n"
+	comments := cs.Recent(recentComments)
+	if len(comments) == 0 {
+		page += "\nNo comments yet.\n"
+	}
+	for _, c := range comments {
+		page += formatComment(c)
+		page += fmt.Sprintf("\n=> /tweet/%d/comments On tweet %d\n=> /admin/comments/remove/%d Remove\n=> /admin/comments/ban/%d Ban %s and remove all their comments\n", c.Tweet, c.Tweet, c.ID, c.ID, c.Name)
+	}
+	cs.mu.Lock()
+	if len(cs.Banned) > 0 {
+		page += "\n## Banned\n\n"
+		var fingerprints []string
+		for fp := range cs.Banned {
+			fingerprints = append(fingerprints, fp)
+		}
+		sort.Strings(fingerprints)
+		for _, fp := range fingerprints {
+			page += fmt.Sprintf("=> /admin/comments/unban/%s Unban %s (%s)\n", fp, cs.Banned[fp], fp[:8])
+		}
+	}
+	cs.mu.Unlock()
+	return page + "\n=> /admin Back to admin\n"
+}
+
+func (rh *RequestHandler) showAdminComments() *gemini.Response {
+	body := ioutil.NopCloser(bytes.NewBufferString(rh.wrapBody(rh.formatAdminComments())))
+	return Success(rh.meta(), body)
+}
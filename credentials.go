@@ -0,0 +1,55 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/dghubble/go-twitter/twitter"
+)
+
+// Verify credentials once this many fetches in a row were refused.
+const unauthorizedThreshold = 3
+
+func isUnauthorized(resp *http.Response) bool {
+	return resp != nil && resp.StatusCode == http.StatusUnauthorized
+}
+
+// checkCredentials asks Twitter whether our tokens are still good and
+// records the outcome for the admin page.
+func (tc *TweetCache) checkCredentials() {
+	client := twitter.NewClient(tc.httpClient())
+	_, resp, err := client.Accounts.VerifyCredentials(&twitter.AccountVerifyParams{
+		SkipStatus: twitter.Bool(true),
+	})
+	if isUnauthorized(resp) {
+		tc.CredentialsProblem = "Twitter credentials are invalid or expired: " + err.Error()
+		fmt.Println(tc.CredentialsProblem)
+	} else if err != nil {
+		fmt.Println("could not verify Twitter credentials:", err)
+	} else {
+		tc.CredentialsProblem = ""
+	}
+}
+
+// noteFetchResult tracks consecutive 401s and re-verifies the credentials
+// when they pile up.
+func (tc *TweetCache) noteFetchResult(resp *http.Response, err error) {
+	if err == nil {
+		tc.unauthorized = 0
+		tc.CredentialsProblem = ""
+		tc.setAccountState("")
+		return
+	}
+
+	if state := tc.accountState(resp, err); state != "" {
+		tc.setAccountState(state)
+		return
+	}
+	if !isUnauthorized(resp) {
+		return
+	}
+	tc.unauthorized += 1
+	if tc.unauthorized == unauthorizedThreshold {
+		tc.checkCredentials()
+	}
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/dghubble/go-twitter/twitter"
+)
+
+const dateLayout = "2006-01-02"
+
+// DateRange selects tweets created on or after From and before To. Zero
+// bounds are open.
+type DateRange struct {
+	From time.Time
+	To   time.Time
+}
+
+func (dr DateRange) IsZero() bool {
+	return dr.From.IsZero() && dr.To.IsZero()
+}
+
+func (dr DateRange) Contains(tweet twitter.Tweet) bool {
+	if dr.IsZero() {
+		return true
+	}
+	t, err := tweet.CreatedAtTime()
+	if err != nil {
+		return false
+	}
+	if !dr.From.IsZero() && t.Before(dr.From) {
+		return false
+	}
+	if !dr.To.IsZero() && !t.Before(dr.To) {
+		return false
+	}
+	return true
+}
+
+func (dr DateRange) String() string {
+	s := ""
+	if !dr.From.IsZero() {
+		s += "from " + dr.From.Format(dateLayout)
+	}
+	if !dr.To.IsZero() {
+		if s != "" {
+			s += " "
+		}
+		s += "to " + dr.To.AddDate(0, 0, -1).Format(dateLayout)
+	}
+	return s
+}
+
+func (dr *DateRange) setFrom(s string) error {
+	if s == "" {
+		return nil
+	}
+	t, err := time.Parse(dateLayout, s)
+	if err != nil {
+		return err
+	}
+	dr.From = t
+	return nil
+}
+
+// setTo makes the range include the whole day s.
+func (dr *DateRange) setTo(s string) error {
+	if s == "" {
+		return nil
+	}
+	t, err := time.Parse(dateLayout, s)
+	if err != nil {
+		return err
+	}
+	dr.To = t.AddDate(0, 0, 1)
+	return nil
+}
+
+func (dr DateRange) validate() error {
+	if !dr.From.IsZero() && !dr.To.IsZero() && !dr.From.Before(dr.To) {
+		return errors.New("date range ends before it starts")
+	}
+	return nil
+}
+
+// parseTimelineRange reads ?from=&to= parameters, or a raw
+// "2023-01-01..2023-06-30" answer to the date range prompt at
+// /timeline/dates.
+func parseTimelineRange(u url.URL) (DateRange, error) {
+	var dr DateRange
+	if u.RawQuery == "" {
+		return dr, nil
+	}
+
+	params := u.Query()
+	from, to := params.Get("from"), params.Get("to")
+	if from == "" && to == "" && !strings.Contains(u.RawQuery, "=") {
+		raw, err := readInput(u)
+		if err != nil {
+			return dr, err
+		}
+		parts := strings.SplitN(strings.TrimSpace(raw), "..", 2)
+		if len(parts) != 2 {
+			return dr, errors.New("expected FROM..TO")
+		}
+		from, to = parts[0], parts[1]
+	}
+
+	if err := dr.setFrom(from); err != nil {
+		return dr, err
+	}
+	if err := dr.setTo(to); err != nil {
+		return dr, err
+	}
+	return dr, dr.validate()
+}
+
+// parseSearchQuery splits from:YYYY-MM-DD and to:YYYY-MM-DD operators out of
+// a search query.
+func parseSearchQuery(query string) (string, DateRange, error) {
+	var dr DateRange
+	var words []string
+	for _, word := range strings.Fields(query) {
+		var err error
+		if strings.HasPrefix(word, "from:") {
+			err = dr.setFrom(strings.TrimPrefix(word, "from:"))
+		} else if strings.HasPrefix(word, "to:") {
+			err = dr.setTo(strings.TrimPrefix(word, "to:"))
+		} else {
+			words = append(words, word)
+		}
+		if err != nil {
+			return "", dr, err
+		}
+	}
+	return strings.Join(words, " "), dr, dr.validate()
+}
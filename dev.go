@@ -0,0 +1,26 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/makeworld-the-better-one/go-gemini"
+)
+
+// devHandler serves each request with the config as it is on disk then,
+// so changes to the theme, its templates and strings, and the logo show
+// on the next page view without restarting. Tweets keep coming from the
+// config the server started with, and nothing is cached or rate limited.
+type devHandler struct {
+	path string
+	tc   *TweetCache
+}
+
+func (d devHandler) Handle(r Request) *gemini.Response {
+	c := Config{}
+	if err := c.Parse(d.path); err != nil {
+		fmt.Println(err)
+		return TempFailure(strings.Join(strings.Fields(err.Error()), " "))
+	}
+	return NewRequestHandler(d.tc, c).Handle(r)
+}
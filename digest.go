@@ -0,0 +1,99 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/makeworld-the-better-one/go-gemini"
+)
+
+type digestDay struct {
+	Date  string
+	Count int
+}
+
+// digestDays lists the days that have tweets, newest first.
+func (tc *TweetCache) digestDays() []digestDay {
+	counts := map[string]int{}
+	for _, tweet := range tc.Tweets {
+		t, err := tweet.CreatedAtTime()
+		if err != nil {
+			continue
+		}
+		counts[t.UTC().Format(dateLayout)] += 1
+	}
+	var days []digestDay
+	for date, count := range counts {
+		days = append(days, digestDay{date, count})
+	}
+	sort.Slice(days, func(i, j int) bool { return days[i].Date > days[j].Date })
+	return days
+}
+
+func (rh *RequestHandler) timelineLink() string {
+	if rh.Config.UI.Mode == "digest" {
+		return "=> /digest " + rh.Config.label("digests")
+	}
+	return "=> /timeline " + rh.Config.label("timeline")
+}
+
+func (rh *RequestHandler) formatDigestEntries() string {
+	days := rh.TweetCache.digestDays()
+	if len(days) == 0 {
+		return "No tweets cached yet.\n"
+	}
+	var entries strings.Builder
+	for _, day := range days {
+		plural := "s"
+		if day.Count == 1 {
+			plural = ""
+		}
+		fmt.Fprintf(&entries, "=> /digest/%s %s - %d tweet%s\n", day.Date, day.Date, day.Count, plural)
+	}
+	return entries.String()
+}
+
+// formatDigestIndex renders a gmisub compatible feed with one entry per day.
+func (rh *RequestHandler) formatDigestIndex() string {
+	title := "Daily digests"
+	if len(rh.TweetCache.Tweets) > 0 && rh.TweetCache.Tweets[0].User != nil {
+		title = rh.TweetCache.Tweets[0].User.Name + ": daily digests"
+	}
+	return fmt.Sprintf("# %s\n\n%s", title, rh.formatDigestEntries())
+}
+
+func (rh *RequestHandler) formatDigestDay(day time.Time) string {
+	dr := DateRange{From: day, To: day.AddDate(0, 0, 1)}
+	var page strings.Builder
+	fmt.Fprintf(&page, "# %s", day.Format(dateLayout))
+	shown := 0
+	for i := len(rh.TweetCache.Tweets) - 1; i >= 0; i -= 1 {
+		tweet := rh.TweetCache.Tweets[i]
+		if !dr.Contains(tweet) {
+			continue
+		}
+		shown += 1
+		page.WriteString(rh.formatLongEntry(tweet, rh.TweetCache.Format(tweet), shown, "/digest/"+day.Format(dateLayout)))
+	}
+	if shown == 0 {
+		page.WriteString("\n\nNo tweets on this day.")
+	}
+	prev, next := day.AddDate(0, 0, -1), day.AddDate(0, 0, 1)
+	fmt.Fprintf(&page, "\n\n=> /digest/%s Previous day\n=> /digest/%s Next day\n=> /digest All digests",
+		prev.Format(dateLayout), next.Format(dateLayout))
+	return page.String()
+}
+
+func (rh *RequestHandler) showDigestIndex() *gemini.Response {
+	body := ioutil.NopCloser(bytes.NewBufferString(rh.wrapBody(rh.formatDigestIndex())))
+	return Success(rh.meta(), body)
+}
+
+func (rh *RequestHandler) showDigestDay(day time.Time) *gemini.Response {
+	body := ioutil.NopCloser(bytes.NewBufferString(rh.wrapBody(rh.formatDigestDay(day))))
+	return Success(rh.meta(), body)
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+var emojiShortcodes = map[rune]string{
+	'☀': "sunny", '☁': "cloud", '☕': "coffee", '☺': "relaxed", '♥': "hearts",
+	'⚠': "warning", '⚡': "zap", '⚽': "soccer", '⛔': "no_entry", '✅': "white_check_mark",
+	'✈': "airplane", '✉': "envelope", '✊': "fist", '✋': "raised_hand", '✌': "v",
+	'✍': "writing_hand", '✏': "pencil2", '✔': "heavy_check_mark", '✨': "sparkles", '❌': "x",
+	'❓': "question", '❗': "exclamation", '❤': "heart", '➡': "arrow_right", '⬅': "arrow_left",
+	'⬆': "arrow_up", '⬇': "arrow_down", '⭐': "star", '⏰': "alarm_clock", '⌛': "hourglass",
+	'🌍': "earth_africa", '🌎': "earth_americas", '🌏': "earth_asia", '🌈': "rainbow", '🌙': "crescent_moon",
+	'🌞': "sun_with_face", '🌟': "star2", '🌱': "seedling", '🌲': "evergreen_tree", '🌹': "rose",
+	'🌻': "sunflower", '🍀': "four_leaf_clover", '🍕': "pizza", '🍺': "beer", '🍻': "beers",
+	'🍷': "wine_glass", '🎁': "gift", '🎂': "birthday", '🎄': "christmas_tree", '🎉': "tada",
+	'🎊': "confetti_ball", '🎤': "microphone", '🎧': "headphones", '🎮': "video_game", '🎵': "musical_note",
+	'🎶': "notes", '🏆': "trophy", '🏠': "house", '🏃': "runner", '🐦': "bird",
+	'🐘': "elephant", '🐱': "cat", '🐶': "dog", '🐍': "snake", '🐛': "bug",
+	'👀': "eyes", '👦': "boy", '👧': "girl", '👨': "man", '👩': "woman", '👇': "point_down", '👈': "point_left", '👉': "point_right", '👆': "point_up_2",
+	'👋': "wave", '👌': "ok_hand", '👍': "thumbsup", '👎': "thumbsdown", '👏': "clap",
+	'👑': "crown", '👻': "ghost", '👽': "alien", '💀': "skull", '💡': "bulb",
+	'💣': "bomb", '💥': "boom", '💪': "muscle", '💬': "speech_balloon", '💯': "100",
+	'💰': "moneybag", '💸': "money_with_wings", '💻': "computer", '📅': "date", '📈': "chart_with_upwards_trend",
+	'📉': "chart_with_downwards_trend", '📌': "pushpin", '📍': "round_pushpin", '📎': "paperclip", '📚': "books",
+	'📝': "memo", '📢': "loudspeaker", '📣': "mega", '📦': "package", '📰': "newspaper",
+	'📱': "iphone", '📷': "camera", '📸': "camera_flash", '📺': "tv", '🔍': "mag",
+	'🔒': "lock", '🔓': "unlock", '🔔': "bell", '🔗': "link", '🔥': "fire",
+	'🔴': "red_circle", '🔵': "large_blue_circle", '🗓': "spiral_calendar", '🗳': "ballot_box", '😀': "grinning",
+	'😁': "grin", '😂': "joy", '😃': "smiley", '😄': "smile", '😅': "sweat_smile",
+	'😆': "laughing", '😉': "wink", '😊': "blush", '😋': "yum", '😍': "heart_eyes",
+	'😎': "sunglasses", '😏': "smirk", '😐': "neutral_face", '😑': "expressionless", '😒': "unamused",
+	'😓': "sweat", '😔': "pensive", '😕': "confused", '😘': "kissing_heart", '😜': "stuck_out_tongue_winking_eye",
+	'😞': "disappointed", '😠': "angry", '😡': "rage", '😢': "cry", '😤': "triumph",
+	'😩': "weary", '😬': "grimacing", '😭': "sob", '😮': "open_mouth", '😱': "scream",
+	'😳': "flushed", '😴': "sleeping", '😷': "mask", '🙂': "slightly_smiling_face", '🙃': "upside_down_face",
+	'🙄': "roll_eyes", '🙈': "see_no_evil", '🙌': "raised_hands", '🙏': "pray", '🚀': "rocket",
+	'🚨': "rotating_light", '🚫': "no_entry_sign", '🤔': "thinking", '🤖': "robot", '🤝': "handshake",
+	'🤣': "rofl", '🤦': "facepalm", '🤩': "star_struck", '🤯': "exploding_head", '🤷': "shrug",
+	'🥂': "clinking_glasses", '🥰': "smiling_face_with_three_hearts", '🥳': "partying_face", '🥺': "pleading_face", '🧵': "thread",
+}
+
+func isEmoji(r rune) bool {
+	return (r >= 0x1F000 && r <= 0x1FAFF) ||
+		(r >= 0x2600 && r <= 0x27BF) ||
+		(r >= 0x2B00 && r <= 0x2BFF) ||
+		(r >= 0x2300 && r <= 0x23FF) ||
+		r == 0x2194 || r == 0x2195 || r == 0x3030 || r == 0x303D
+}
+
+// isEmojiModifier matches the invisible parts of emoji sequences: joiners,
+// variation selectors, keycaps, skin tones and tag characters.
+func isEmojiModifier(r rune) bool {
+	return r == 0x200D || r == 0xFE0E || r == 0xFE0F || r == 0x20E3 ||
+		(r >= 0x1F3FB && r <= 0x1F3FF) ||
+		(r >= 0xE0020 && r <= 0xE007F)
+}
+
+func isRegionalIndicator(r rune) bool {
+	return r >= 0x1F1E6 && r <= 0x1F1FF
+}
+
+func stripEmoji(text string) string {
+	return strings.Map(func(r rune) rune {
+		if isEmoji(r) || isEmojiModifier(r) {
+			return -1
+		}
+		return r
+	}, text)
+}
+
+func shortcodeEmoji(text string) string {
+	var b strings.Builder
+	runes := []rune(text)
+	for i := 0; i < len(runes); i += 1 {
+		r := runes[i]
+		switch {
+		case isRegionalIndicator(r) && i+1 < len(runes) && isRegionalIndicator(runes[i+1]):
+			fmt.Fprintf(&b, ":flag_%c%c:", 'a'+(r-0x1F1E6), 'a'+(runes[i+1]-0x1F1E6))
+			i += 1
+		case isEmojiModifier(r):
+		case isEmoji(r):
+			name, ok := emojiShortcodes[r]
+			if !ok {
+				name = "emoji"
+			}
+			b.WriteString(":" + name + ":")
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+func (c *Config) convertEmoji(text string) string {
+	switch c.UI.Emoji {
+	case "strip":
+		return stripEmoji(text)
+	case "shortcode":
+		return shortcodeEmoji(text)
+	}
+	if c.UI.Accessible {
+		return describeEmojiLines(text)
+	}
+	return text
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"github.com/dghubble/go-twitter/twitter"
+)
+
+// What happens to a TweetCache, for the parts of the mirror that act on
+// it to subscribe to instead of being called from where it happens.
+const (
+	// Tweets holds the tweets that weren't in the archive yet.
+	EventNewTweets = "new tweets"
+	// Changed reports whether the refresh changed what pages show.
+	EventRefreshed = "refreshed"
+	// Err is why the fetch failed.
+	EventFetchFailed = "fetch failed"
+	// Anything else changed what pages show: pushed tweets, account
+	// state, snapshots, sources.
+	EventChanged = "changed"
+)
+
+type Event struct {
+	Kind    string
+	Tweets  []twitter.Tweet
+	Changed bool
+	Err     error
+}
+
+// EventBus hands each published event to the handlers subscribed to its
+// kind, in the order they subscribed. Handlers run on the publisher's
+// goroutine, so anything slow starts its own.
+type EventBus struct {
+	mu       sync.RWMutex
+	handlers map[string][]func(Event)
+	counts   map[string]int
+}
+
+func (b *EventBus) Subscribe(kind string, handler func(Event)) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.handlers == nil {
+		b.handlers = map[string][]func(Event){}
+	}
+	b.handlers[kind] = append(b.handlers[kind], handler)
+}
+
+func (b *EventBus) Publish(e Event) {
+	b.mu.Lock()
+	if b.counts == nil {
+		b.counts = map[string]int{}
+	}
+	b.counts[e.Kind] += 1
+	handlers := b.handlers[e.Kind]
+	b.mu.Unlock()
+	for _, handler := range handlers {
+		handler(e)
+	}
+}
+
+// Count is how many events of a kind have been published, for metrics.
+func (b *EventBus) Count(kind string) int {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.counts[kind]
+}
+
+// subscribe wires up what every TweetCache does about its events.
+func (tc *TweetCache) subscribe() {
+	invalidate := func(e Event) {
+		if e.Kind == EventChanged || e.Changed {
+			atomic.AddUint64(&tc.generation, 1)
+		}
+	}
+	tc.events.Subscribe(EventRefreshed, invalidate)
+	tc.events.Subscribe(EventChanged, invalidate)
+
+	tc.events.Subscribe(EventRefreshed, func(Event) { tc.recordFetch(nil) })
+	tc.events.Subscribe(EventFetchFailed, func(e Event) { tc.recordFetch(e.Err) })
+
+	tc.events.Subscribe(EventNewTweets, func(e Event) {
+		go tc.Config.runHooks(e.Tweets)
+		go tc.Config.sendMisfinNotifications(e.Tweets)
+	})
+}
+
+// changed tells subscribers that pages need rendering again.
+func (tc *TweetCache) changed() {
+	tc.events.Publish(Event{Kind: EventChanged})
+}
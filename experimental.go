@@ -0,0 +1,22 @@
+package main
+
+import "github.com/makeworld-the-better-one/go-gemini"
+
+// previewExperimental hands requests with ?experimental=1 to the handler
+// rendering with ui.experimental, so a new look can be tried out on single
+// pages before it becomes ui.theme. Without one they get the usual pages.
+func (rh *RequestHandler) previewExperimental(next Handler) Handler {
+	return Func(func(r Request) *gemini.Response {
+		flag, found := rh.takeQueryParam(&r, "experimental")
+		if !found {
+			return next.Handle(r)
+		}
+		if flag != "0" && flag != "1" {
+			return BadRequest("experimental must be 0 or 1")
+		}
+		if flag == "1" && rh.experimental != nil {
+			return rh.experimental.Handle(r)
+		}
+		return next.Handle(r)
+	})
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/dghubble/go-twitter/twitter"
+)
+
+// Date formats seen in the wild, RSS ones first.
+var feedDateLayouts = []string{
+	time.RFC1123Z,
+	time.RFC1123,
+	"Mon, 2 Jan 2006 15:04:05 -0700",
+	"Mon, 2 Jan 2006 15:04:05 MST",
+	"2 Jan 2006 15:04:05 -0700",
+	time.RFC3339,
+	time.RFC3339Nano,
+}
+
+type feedDocument struct {
+	XMLName xml.Name
+	// RSS
+	Channel struct {
+		Title string `xml:"title"`
+		Items []struct {
+			Title       string `xml:"title"`
+			Link        string `xml:"link"`
+			GUID        string `xml:"guid"`
+			PubDate     string `xml:"pubDate"`
+			Description string `xml:"description"`
+			Content     string `xml:"http://purl.org/rss/1.0/modules/content/ encoded"`
+		} `xml:"item"`
+	} `xml:"channel"`
+	// Atom
+	Title   string `xml:"title"`
+	Entries []struct {
+		Title string `xml:"title"`
+		ID    string `xml:"id"`
+		Links []struct {
+			Href string `xml:"href,attr"`
+			Rel  string `xml:"rel,attr"`
+		} `xml:"link"`
+		Published string `xml:"published"`
+		Updated   string `xml:"updated"`
+		Summary   string `xml:"summary"`
+		Content   string `xml:"content"`
+	} `xml:"entry"`
+}
+
+type feedItem struct {
+	id, title, link, date, body string
+}
+
+// feedSource presents the items of any RSS or Atom feed as tweets.
+type feedSource struct {
+	Config
+}
+
+func (s feedSource) Name() string {
+	if u, err := url.Parse(s.Feed.URL); err == nil && u.Host != "" {
+		return u.Host
+	}
+	return "the web"
+}
+
+func (s feedSource) URL(tweet twitter.Tweet) string {
+	if tweet.Entities != nil && len(tweet.Entities.Urls) > 0 {
+		return tweet.Entities.Urls[0].ExpandedURL
+	}
+	return s.Feed.URL
+}
+
+func (s feedSource) Fetch() ([]twitter.Tweet, error) {
+	client := http.Client{Timeout: time.Minute, Transport: s.Config.outbound(nil)}
+	resp, err := client.Get(s.Feed.URL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("feed: %s returned %s", s.Feed.URL, resp.Status)
+	}
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	return parseFeed(b, s.Feed.URL)
+}
+
+func parseFeedDate(s string) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	for _, layout := range feedDateLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unknown date format %q", s)
+}
+
+// feedItemID makes a tweet ID that sorts by date like real tweet IDs do,
+// with a hash of the item's ID in the low bits to tell apart items
+// published in the same second.
+func feedItemID(published time.Time, id string) int64 {
+	h := fnv.New32a()
+	h.Write([]byte(id))
+	return published.Unix()<<22 | int64(h.Sum32()&(1<<22-1))
+}
+
+func parseFeed(b []byte, feedURL string) ([]twitter.Tweet, error) {
+	var doc feedDocument
+	if err := xml.Unmarshal(b, &doc); err != nil {
+		return nil, fmt.Errorf("feed: %v", err)
+	}
+
+	var title string
+	var items []feedItem
+	switch doc.XMLName.Local {
+	case "rss":
+		title = doc.Channel.Title
+		for _, it := range doc.Channel.Items {
+			body := it.Content
+			if body == "" {
+				body = it.Description
+			}
+			id := it.GUID
+			if id == "" {
+				id = it.Link
+			}
+			items = append(items, feedItem{id, it.Title, it.Link, it.PubDate, body})
+		}
+	case "feed":
+		title = doc.Title
+		for _, e := range doc.Entries {
+			link := ""
+			for _, l := range e.Links {
+				if l.Rel == "" || l.Rel == "alternate" {
+					link = l.Href
+					break
+				}
+			}
+			body := e.Content
+			if body == "" {
+				body = e.Summary
+			}
+			date := e.Published
+			if date == "" {
+				date = e.Updated
+			}
+			items = append(items, feedItem{e.ID, e.Title, link, date, body})
+		}
+	default:
+		return nil, fmt.Errorf("feed: unsupported document <%s>", doc.XMLName.Local)
+	}
+
+	user := &twitter.User{Name: title, ScreenName: feedURL}
+	if u, err := url.Parse(feedURL); err == nil && u.Host != "" {
+		user.ScreenName = u.Host
+	}
+	if user.Name == "" {
+		user.Name = user.ScreenName
+	}
+
+	var tweets []twitter.Tweet
+	for _, it := range items {
+		published, err := parseFeedDate(it.date)
+		if err != nil {
+			continue
+		}
+		text := htmlToText(it.body)
+		itemTitle := strings.TrimSpace(htmlToText(it.title))
+		if itemTitle != "" && !strings.HasPrefix(text, itemTitle) {
+			text = strings.TrimSpace(itemTitle + "\n\n" + text)
+		}
+		tweet := twitter.Tweet{
+			ID:        feedItemID(published, it.id),
+			CreatedAt: published.Format(time.RubyDate),
+			User:      user,
+			Entities:  &twitter.Entities{},
+		}
+		if it.link != "" {
+			text += "\n\n" + it.link
+			tweet.Entities.Urls = append(tweet.Entities.Urls, twitter.URLEntity{URL: it.link, ExpandedURL: it.link})
+		}
+		tweet.Text, tweet.FullText = text, text
+		for _, tag := range hashtagPattern.FindAllStringSubmatch(text, -1) {
+			tweet.Entities.Hashtags = append(tweet.Entities.Hashtags, twitter.HashtagEntity{Text: tag[1]})
+		}
+		tweets = append(tweets, tweet)
+	}
+	if len(tweets) == 0 {
+		return nil, errors.New("feed: no items with a usable date")
+	}
+	sortNewestFirst(tweets)
+	return tweets, nil
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+type fetchError struct {
+	At    time.Time `json:"at"`
+	Error string    `json:"error"`
+}
+
+// FetchErrors keeps the latest fetch errors, and alerts when fetches keep
+// failing.
+type FetchErrors struct {
+	mu     sync.Mutex
+	recent []fetchError
+	// Failed fetches since the last one that worked
+	failing int
+	alerted bool
+}
+
+// Record notes the outcome of a fetch, err nil if it worked, and returns
+// the alert to send, if any: when failures reach threshold in a row, and
+// when fetching works again after that.
+func (fe *FetchErrors) Record(err error, history, threshold int) *fetchAlert {
+	fe.mu.Lock()
+	defer fe.mu.Unlock()
+	if err == nil {
+		failed := fe.failing
+		fe.failing = 0
+		if fe.alerted {
+			fe.alerted = false
+			return &fetchAlert{Event: "recovered", Failures: failed}
+		}
+		return nil
+	}
+	fe.recent = append(fe.recent, fetchError{time.Now(), err.Error()})
+	if len(fe.recent) > history {
+		fe.recent = fe.recent[len(fe.recent)-history:]
+	}
+	fe.failing += 1
+	if threshold > 0 && fe.failing == threshold {
+		fe.alerted = true
+		alert := &fetchAlert{Event: "failing", Failures: fe.failing}
+		for i := len(fe.recent) - 1; i >= 0 && len(alert.Errors) < threshold; i -= 1 {
+			alert.Errors = append(alert.Errors, fe.recent[i])
+		}
+		return alert
+	}
+	return nil
+}
+
+func (fe *FetchErrors) format() string {
+	fe.mu.Lock()
+	defer fe.mu.Unlock()
+	if len(fe.recent) == 0 {
+		return ""
+	}
+	var page strings.Builder
+	page.WriteString("\n## Fetch errors\n\n")
+	if fe.failing > 0 {
+		fmt.Fprintf(&page, "%s in a row\n\n", pluralize(fe.failing, "failed fetch", "failed fetches"))
+	}
+	for i := len(fe.recent) - 1; i >= 0; i -= 1 {
+		fmt.Fprintf(&page, "* %s %s\n", fe.recent[i].At.UTC().Format("2006-01-02 15:04:05"), fe.recent[i].Error)
+	}
+	return page.String()
+}
+
+// fetchAlert is sent as JSON to alerts.command and alerts.webhookURL.
+type fetchAlert struct {
+	// failing or recovered
+	Event    string `json:"event"`
+	Account  string `json:"account"`
+	Failures int    `json:"failures"`
+	// The latest errors, newest first
+	Errors []fetchError `json:"errors,omitempty"`
+}
+
+func (c *Config) sendAlert(alert fetchAlert) {
+	if len(c.Alerts.Command) == 0 && c.Alerts.WebhookURL == "" {
+		return
+	}
+	alert.Account = c.account()
+	payload, err := json.Marshal(alert)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	if len(c.Alerts.Command) > 0 {
+		if err := runCommand(c.Alerts.Command, payload, "ALERT_EVENT="+alert.Event); err != nil {
+			fmt.Println(err)
+		}
+	}
+	if c.Alerts.WebhookURL != "" {
+		if err := c.postJSON(c.Alerts.WebhookURL, payload); err != nil {
+			fmt.Println(err)
+		}
+	}
+}
+
+func (tc *TweetCache) recordFetch(err error) {
+	if alert := tc.fetchErrors.Record(err, tc.Config.Alerts.History, tc.Config.Alerts.FetchFailures); alert != nil {
+		c := tc.liveConfig()
+		go c.sendAlert(*alert)
+	}
+}
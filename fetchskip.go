@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/dghubble/go-twitter/twitter"
+)
+
+// accountMark is what the account's profile said at the last timeline
+// fetch, and what that fetch brought.
+type accountMark struct {
+	statuses int
+	latest   int64
+	tweets   []twitter.Tweet
+}
+
+func (m accountMark) same(other accountMark) bool {
+	return m.statuses == other.statuses && m.latest == other.latest
+}
+
+// lookupAccount reads the account's tweet count and latest tweet from its
+// profile, a call with a rate limit of its own that is far cheaper than
+// pulling the timeline.
+func (tc *TweetCache) lookupAccount() (accountMark, *http.Response, error) {
+	client := twitter.NewClient(tc.httpClient())
+	user, resp, err := client.Users.Show(&twitter.UserShowParams{
+		UserID:     tc.Config.Twitter.UserID,
+		ScreenName: tc.Config.Twitter.ScreenName,
+	})
+	if err != nil {
+		return accountMark{}, resp, err
+	}
+	mark := accountMark{statuses: user.StatusesCount}
+	if user.Status != nil {
+		mark.latest = user.Status.ID
+	}
+	return mark, resp, nil
+}
+
+// fetchIfChanged is fetchTweets, except with refresh.skipUnchanged it
+// hands back the last fetch when the account hasn't tweeted or deleted a
+// tweet since. A failed lookup fetches anyway, so errors are still seen
+// where they always were.
+func (tc *TweetCache) fetchIfChanged() ([]twitter.Tweet, *http.Response, error) {
+	if !tc.Config.Refresh.SkipUnchanged {
+		return tc.fetchTweets()
+	}
+	mark, resp, err := tc.lookupAccount()
+	if err != nil {
+		fmt.Println("looking up account:", err)
+		return tc.fetchTweets()
+	}
+	if tc.mark.tweets != nil && mark.same(tc.mark) {
+		tc.skippedFetches++
+		return tc.mark.tweets, resp, nil
+	}
+	tweets, resp, err := tc.fetchTweets()
+	if err == nil {
+		mark.tweets = tweets
+		tc.mark = mark
+	}
+	return tweets, resp, err
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+
+	"github.com/makeworld-the-better-one/go-gemini"
+)
+
+// Renderer turns a gemtext page into another output format.
+type Renderer struct {
+	MediaType string
+	Render    func(rh *RequestHandler, lines []Line) string
+	// Enabled reports whether the config allows the format; nil means
+	// always.
+	Enabled func(c Config) bool
+}
+
+var renderers = map[string]Renderer{}
+
+// RegisterRenderer makes a format available as ?fmt=name and ui.format.
+func RegisterRenderer(name string, r Renderer) {
+	renderers[name] = r
+}
+
+func (c Config) renderer(name string) (Renderer, bool) {
+	r, ok := renderers[name]
+	if !ok || (r.Enabled != nil && !r.Enabled(c)) {
+		return Renderer{}, false
+	}
+	return r, true
+}
+
+func (c Config) formatNames() []string {
+	var names []string
+	for name := range renderers {
+		if _, ok := c.renderer(name); ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// takeParam removes name=... from a raw query, matching whole keys, and
+// leaves anything else as it was.
+func takeParam(rawQuery, name string) (value string, found bool, rest string) {
+	var kept []string
+	for _, part := range strings.Split(rawQuery, "&") {
+		if i := strings.Index(part, "="); i >= 0 && part[:i] == name {
+			value, found = part[i+1:], true
+			continue
+		}
+		if part != "" {
+			kept = append(kept, part)
+		}
+	}
+	return value, found, strings.Join(kept, "&")
+}
+
+// takeQueryParam takes name=... out of r's query for the middleware
+// reading it. Routes taking input read the whole query as the answer, so
+// theirs is left alone.
+func (rh *RequestHandler) takeQueryParam(r *Request, name string) (string, bool) {
+	if rh.router.TakesInput(r.URL.Path) {
+		return "", false
+	}
+	value, found, rest := takeParam(r.URL.RawQuery, name)
+	if found {
+		if r.SentQuery == "" {
+			r.SentQuery = r.URL.RawQuery
+		}
+		u := *r.URL
+		u.RawQuery = rest
+		r.URL = &u
+	}
+	return value, found
+}
+
+// textMeta swaps the gemtext media type for another, keeping parameters
+// such as lang.
+func textMeta(meta, mediaType string) string {
+	params := ""
+	if i := strings.Index(meta, ";"); i >= 0 {
+		params = meta[i:]
+	}
+	if strings.HasPrefix(mediaType, "text/") && !strings.Contains(params, "charset=") {
+		params += "; charset=utf-8"
+	}
+	return mediaType + params
+}
+
+// renderFormats renders pages in the format picked by ?fmt=, falling back
+// to ui.format.
+func (rh *RequestHandler) renderFormats(next Handler) Handler {
+	return Func(func(r Request) *gemini.Response {
+		format, found := rh.takeQueryParam(&r, "fmt")
+		if !found {
+			format = rh.Config.UI.Format
+		}
+		renderer, ok := rh.Config.renderer(format)
+		if !ok {
+			return BadRequest(fmt.Sprintf("fmt must be one of %s", strings.Join(rh.Config.formatNames(), ", ")))
+		}
+
+		resp := next.Handle(r)
+		if format == "gmi" || resp.Status != 20 || !strings.HasPrefix(resp.Meta, "text/gemini") {
+			return resp
+		}
+		doc, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return TempFailure("Failed to render page")
+		}
+		body := renderer.Render(rh, parseGemtext(string(doc)))
+		return Success(textMeta(resp.Meta, renderer.MediaType), ioutil.NopCloser(bytes.NewBufferString(body)))
+	})
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/dghubble/go-twitter/twitter"
+)
+
+func (c *Config) formatProfile(user *twitter.User) string {
+	if user == nil {
+		return ""
+	}
+	profile := fmt.Sprintf("# %s (@%s)\n\n", user.Name, user.ScreenName)
+	if user.Description != "" {
+		profile += user.Description + "\n\n"
+	}
+	if user.Location != "" {
+		profile += fmt.Sprintf("Location: %s\n", user.Location)
+	}
+	if c.UI.Accessible {
+		profile += fmt.Sprintf("%s, following %s, %s.\n", countNoun(user.StatusesCount, "tweet"),
+			countNoun(user.FriendsCount, "account"), countNoun(user.FollowersCount, "follower"))
+	} else {
+		profile += fmt.Sprintf("Tweets: %d · Following: %d · Followers: %d\n", user.StatusesCount, user.FriendsCount, user.FollowersCount)
+	}
+	return profile
+}
+
+func (rh *RequestHandler) formatFrontPage() string {
+	if len(rh.TweetCache.Tweets) == 0 {
+		return "\n\nNo tweets cached yet."
+	}
+
+	page := rh.Config.convertEmoji(rh.Config.formatProfile(rh.TweetCache.Tweets[0].User))
+	page += rh.formatAccountState()
+	if rh.Config.Snapshots.Enabled {
+		page += "=> /following Following\n=> /followers Followers\n"
+	}
+	if pinned := rh.formatPinned(); pinned != "" {
+		page += "\n## Pinned tweet" + pinned + "\n"
+	}
+	if rh.Config.UI.Mode == "digest" {
+		return page + "\n## Daily digests\n\n" + rh.formatDigestEntries()
+	}
+	page += "\n## Latest tweets"
+	for i := 0; i < rh.Config.UI.FrontPageCount; i += 1 {
+		tw, err := rh.TweetCache.GetOnPosition(i)
+		if err != nil {
+			break
+		}
+		page += rh.formatEntry(rh.TweetCache.Tweets[i], tw)
+	}
+	page += "\n\n=> /timeline Full timeline"
+	return page
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/dghubble/go-twitter/twitter"
+)
+
+const (
+	// Gaps listed per check, the rest are counted.
+	maxGapsReported = 10
+	// A quiet stretch counts as a gap when it's this many times the usual
+	// time between tweets, and at least a day.
+	gapFactor = 20
+)
+
+// ArchiveCheck is the outcome of checking the archive file.
+type ArchiveCheck struct {
+	At       time.Time
+	Tweets   int
+	Problems []string
+}
+
+func (ac *ArchiveCheck) problem(format string, args ...interface{}) {
+	ac.Problems = append(ac.Problems, fmt.Sprintf(format, args...))
+}
+
+// checkArchive reads the archive as stored, entry by entry, so a single
+// broken entry is reported instead of failing the whole load.
+func checkArchive(path string) ArchiveCheck {
+	ac := ArchiveCheck{At: time.Now()}
+	b, err := ioutil.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return ac
+	} else if err != nil {
+		ac.problem("reading the archive: %v", err)
+		return ac
+	}
+	var raw struct {
+		Tweets   []json.RawMessage
+		Retweets map[int64][]int64
+	}
+	if err := json.Unmarshal(b, &raw); err != nil {
+		ac.problem("the archive isn't valid JSON: %v", err)
+		return ac
+	}
+	ac.Tweets = len(raw.Tweets)
+
+	var tweets []twitter.Tweet
+	for i, entry := range raw.Tweets {
+		var tweet twitter.Tweet
+		if err := json.Unmarshal(entry, &tweet); err != nil {
+			ac.problem("entry %d is corrupted: %v", i, err)
+			continue
+		}
+		if tweet.ID == 0 {
+			ac.problem("entry %d has no ID", i)
+			continue
+		}
+		if _, err := tweet.CreatedAtTime(); err != nil {
+			ac.problem("tweet %d has an unreadable date %q", tweet.ID, tweet.CreatedAt)
+		}
+		if tweet.User == nil {
+			ac.problem("tweet %d has no author", tweet.ID)
+		}
+		tweets = append(tweets, tweet)
+	}
+
+	seen := map[int64]int{}
+	for _, tweet := range tweets {
+		seen[tweet.ID] += 1
+	}
+	for i, tweet := range tweets {
+		if n := seen[tweet.ID]; n > 1 {
+			ac.problem("tweet %d is stored %d times", tweet.ID, n)
+			seen[tweet.ID] = 1
+		}
+		if i > 0 && tweets[i-1].ID < tweet.ID {
+			ac.problem("tweet %d is out of order, the archive should be newest first", tweet.ID)
+		}
+	}
+
+	// Older retweets of the same tweet are dropped on purpose, but the
+	// newest one should be there.
+	orphaned := 0
+	for _, ids := range raw.Retweets {
+		kept := false
+		for _, id := range ids {
+			kept = kept || seen[id] > 0
+		}
+		if !kept {
+			orphaned += 1
+		}
+	}
+	if orphaned > 0 {
+		ac.problem("the retweet index lists %d retweeted tweets none of whose retweets are stored", orphaned)
+	}
+
+	for _, gap := range findGaps(tweets) {
+		ac.Problems = append(ac.Problems, gap)
+	}
+	return ac
+}
+
+// findGaps looks for stretches without tweets much longer than usual,
+// which are more likely missed fetches than silence.
+func findGaps(tweets []twitter.Tweet) []string {
+	var times []time.Time
+	for _, tweet := range tweets {
+		if t, err := tweet.CreatedAtTime(); err == nil {
+			times = append(times, t)
+		}
+	}
+	if len(times) < 3 {
+		return nil
+	}
+	sort.Slice(times, func(i, j int) bool { return times[i].Before(times[j]) })
+	intervals := make([]time.Duration, len(times)-1)
+	for i := 1; i < len(times); i += 1 {
+		intervals[i-1] = times[i].Sub(times[i-1])
+	}
+	sorted := append([]time.Duration(nil), intervals...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	limit := sorted[len(sorted)/2] * gapFactor
+	if limit < 24*time.Hour {
+		limit = 24 * time.Hour
+	}
+
+	var gaps []string
+	extra := 0
+	for i, interval := range intervals {
+		if interval <= limit {
+			continue
+		}
+		if len(gaps) == maxGapsReported {
+			extra += 1
+			continue
+		}
+		gaps = append(gaps, fmt.Sprintf("no tweets from %s to %s", times[i].Format("2006-01-02 15:04"), times[i+1].Format("2006-01-02 15:04")))
+	}
+	if extra > 0 {
+		gaps = append(gaps, fmt.Sprintf("and %d more gaps", extra))
+	}
+	return gaps
+}
+
+// ArchiveChecker checks the archive once a day and keeps the report for
+// the admin page.
+func (tc *TweetCache) ArchiveChecker() {
+	for {
+		check := checkArchive(tc.Config.Cache.ArchiveFile)
+		for _, problem := range check.Problems {
+			fmt.Println("archive check:", problem)
+		}
+		tc.archiveCheck = &check
+		time.Sleep(24 * time.Hour)
+	}
+}
+
+func (c *ArchiveCheck) format() string {
+	page := fmt.Sprintf("Checked %s, %d tweets\n", c.At.Format(time.RFC3339), c.Tweets)
+	if len(c.Problems) == 0 {
+		return page + "No problems found\n"
+	}
+	for _, problem := range c.Problems {
+		page += "* " + problem + "\n"
+	}
+	return page
+}
+
+func runFsck(args []string) error {
+	fs := flag.NewFlagSet("fsck", flag.ExitOnError)
+	path := fs.String("config", "config.yml", "Location of config file")
+	fs.Parse(args)
+
+	c := Config{}
+	if err := c.Parse(*path); err != nil {
+		return err
+	}
+	if c.Cache.ArchiveFile == "" {
+		return errors.New("fsck: cache.archiveFile is not configured")
+	}
+	check := checkArchive(c.Cache.ArchiveFile)
+	fmt.Print(check.format())
+	if len(check.Problems) > 0 {
+		return fmt.Errorf("fsck: %d problems in %s", len(check.Problems), c.Cache.ArchiveFile)
+	}
+	return nil
+}
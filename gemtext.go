@@ -0,0 +1,142 @@
+package main
+
+import "strings"
+
+func init() {
+	RegisterRenderer("gmi", Renderer{
+		MediaType: "text/gemini",
+		Render:    func(rh *RequestHandler, lines []Line) string { return renderGemtext(lines) },
+	})
+	RegisterRenderer("txt", Renderer{
+		MediaType: "text/plain",
+		Render:    func(rh *RequestHandler, lines []Line) string { return renderText(lines) },
+	})
+}
+
+type LineType int
+
+const (
+	TextLine LineType = iota
+	LinkLine
+	HeadingLine
+	ListLine
+	QuoteLine
+	PreformatToggleLine
+	PreformattedLine
+)
+
+// Line is one line of a gemtext document. Level is the heading depth,
+// Text the link label or alt text where there is one.
+type Line struct {
+	Type  LineType
+	Level int
+	URL   string
+	Text  string
+}
+
+func parseGemtext(doc string) []Line {
+	var lines []Line
+	preformatted := false
+	for _, raw := range strings.Split(strings.TrimRight(doc, "\n"), "\n") {
+		raw = strings.TrimRight(raw, "\r")
+		if strings.HasPrefix(raw, "```") {
+			preformatted = !preformatted
+			lines = append(lines, Line{Type: PreformatToggleLine, Text: strings.TrimSpace(raw[3:])})
+			continue
+		}
+		if preformatted {
+			lines = append(lines, Line{Type: PreformattedLine, Text: raw})
+			continue
+		}
+
+		switch {
+		case strings.HasPrefix(raw, "=>"):
+			fields := strings.Fields(raw[2:])
+			line := Line{Type: LinkLine}
+			if len(fields) > 0 {
+				line.URL = fields[0]
+				line.Text = strings.TrimSpace(strings.TrimSpace(raw[2:])[len(fields[0]):])
+			}
+			lines = append(lines, line)
+		case strings.HasPrefix(raw, "#"):
+			level := len(raw) - len(strings.TrimLeft(raw, "#"))
+			if level > 3 {
+				level = 3
+			}
+			lines = append(lines, Line{Type: HeadingLine, Level: level, Text: strings.TrimSpace(raw[level:])})
+		case strings.HasPrefix(raw, "* "):
+			lines = append(lines, Line{Type: ListLine, Text: raw[2:]})
+		case strings.HasPrefix(raw, ">"):
+			lines = append(lines, Line{Type: QuoteLine, Text: strings.TrimSpace(raw[1:])})
+		default:
+			lines = append(lines, Line{Type: TextLine, Text: raw})
+		}
+	}
+	return lines
+}
+
+func renderGemtext(lines []Line) string {
+	var b strings.Builder
+	for _, line := range lines {
+		switch line.Type {
+		case LinkLine:
+			b.WriteString(strings.TrimSpace("=> " + line.URL + " " + line.Text))
+		case HeadingLine:
+			b.WriteString(strings.Repeat("#", line.Level) + " " + line.Text)
+		case ListLine:
+			b.WriteString("* " + line.Text)
+		case QuoteLine:
+			b.WriteString("> " + line.Text)
+		case PreformatToggleLine:
+			b.WriteString("```" + line.Text)
+		default:
+			b.WriteString(line.Text)
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// printable drops the control characters from text a terminal might act
+// on, such as escape sequences in a post, keeping tabs.
+func printable(text string) string {
+	return strings.Map(func(r rune) rune {
+		if (r < 0x20 && r != '\t') || (r >= 0x7f && r < 0xa0) {
+			return -1
+		}
+		return r
+	}, text)
+}
+
+// renderText turns gemtext into plain text, keeping link targets visible.
+func renderText(lines []Line) string {
+	var b strings.Builder
+	for _, line := range lines {
+		line.Text, line.URL = printable(line.Text), printable(line.URL)
+		switch line.Type {
+		case LinkLine:
+			if line.Text == "" {
+				b.WriteString(line.URL)
+			} else {
+				b.WriteString(line.Text + " <" + line.URL + ">")
+			}
+		case HeadingLine:
+			b.WriteString(line.Text)
+			if line.Level == 1 {
+				b.WriteString("\n" + strings.Repeat("=", stringWidth(line.Text)))
+			} else if line.Level == 2 {
+				b.WriteString("\n" + strings.Repeat("-", stringWidth(line.Text)))
+			}
+		case ListLine:
+			b.WriteString("- " + line.Text)
+		case QuoteLine:
+			b.WriteString("> " + line.Text)
+		case PreformatToggleLine:
+			continue
+		default:
+			b.WriteString(line.Text)
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
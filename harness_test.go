@@ -0,0 +1,69 @@
+package main
+
+import (
+	"crypto/x509"
+	"io/ioutil"
+	"net"
+	"strings"
+
+	"github.com/dghubble/go-twitter/twitter"
+	"github.com/makeworld-the-better-one/go-gemini"
+)
+
+// Deps replace what a handler otherwise gets from the network and disk,
+// for running routes without either.
+type Deps struct {
+	// Fetches on refresh; nil keeps the source the config names
+	Source Source
+	// The archive to start with
+	Tweets []twitter.Tweet
+}
+
+// NewHandler builds a handler as the server does, from a config that
+// wasn't read from a file. Paths in it are relative to the working
+// directory; leave cache.archiveFile empty to keep the archive in memory.
+func NewHandler(c Config, deps Deps) (*RequestHandler, error) {
+	if err := c.complete("."); err != nil {
+		return nil, err
+	}
+	if err := c.validate(); err != nil {
+		return nil, err
+	}
+	tc, err := NewTweetCache(c)
+	if err != nil {
+		return nil, err
+	}
+	if deps.Source != nil {
+		tc.source = deps.Source
+	}
+	tc.archive.Merge(deps.Tweets)
+	tc.Tweets = c.inMemory(tc.archive.Tweets)
+	return NewRequestHandler(tc, c), nil
+}
+
+// fakeClient is where requests made with NewRequest come from.
+var fakeClient = &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 1965}
+
+// NewRequest makes the request a client would send for rawURL, parsed as
+// the server does, from localhost and with the given client certificates.
+// A bare path is taken to be on gemini://localhost.
+func NewRequest(rawURL string, certs ...*x509.Certificate) (Request, error) {
+	if strings.HasPrefix(rawURL, "/") {
+		rawURL = "gemini://localhost" + rawURL
+	}
+	u, err := readRequestURL(strings.NewReader(rawURL + "\r\n"))
+	if err != nil {
+		return Request{}, err
+	}
+	return Request{Request: gemini.Request{URL: u}, RemoteAddr: fakeClient, Certificates: certs}, nil
+}
+
+// ReadBody reads and closes the body of resp, if it has one.
+func ReadBody(resp *gemini.Response) (string, error) {
+	if resp.Body == nil {
+		return "", nil
+	}
+	defer resp.Body.Close()
+	b, err := ioutil.ReadAll(resp.Body)
+	return string(b), err
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"strconv"
+	"time"
+
+	"github.com/dghubble/go-twitter/twitter"
+)
+
+const hookTimeout = 30 * time.Second
+
+// newTweets returns the tweets in fetched that aren't in cached, oldest first.
+func newTweets(cached, fetched []twitter.Tweet) []twitter.Tweet {
+	known := map[int64]bool{}
+	for _, tweet := range cached {
+		known[tweet.ID] = true
+	}
+	var fresh []twitter.Tweet
+	for i := len(fetched) - 1; i >= 0; i -= 1 {
+		if !known[fetched[i].ID] {
+			fresh = append(fresh, fetched[i])
+		}
+	}
+	return fresh
+}
+
+// runCommand runs command with payload on stdin and env added to its
+// environment.
+func runCommand(command []string, payload []byte, env ...string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), hookTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, command[0], command[1:]...)
+	cmd.Stdin = bytes.NewReader(payload)
+	cmd.Env = append(os.Environ(), env...)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("hook command failed: %v: %s", err, out)
+	}
+	return nil
+}
+
+func (c *Config) runCommandHook(tweet twitter.Tweet, payload []byte) error {
+	return runCommand(c.Hooks.Command, payload, "TWEET_ID="+strconv.FormatInt(tweet.ID, 10))
+}
+
+func (c *Config) postJSON(url string, payload []byte) error {
+	client := http.Client{Timeout: hookTimeout, Transport: c.outbound(nil)}
+	resp, err := client.Post(url, "application/json", bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return nil
+}
+
+func (c *Config) postWebhook(payload []byte) error {
+	return c.postJSON(c.Hooks.WebhookURL, payload)
+}
+
+// runHooks hands each new tweet, as JSON, to the configured command and
+// webhook.
+func (c *Config) runHooks(tweets []twitter.Tweet) {
+	if len(c.Hooks.Command) == 0 && c.Hooks.WebhookURL == "" {
+		return
+	}
+	for _, tweet := range tweets {
+		payload, err := json.Marshal(tweet)
+		if err != nil {
+			fmt.Println(err)
+			continue
+		}
+		if len(c.Hooks.Command) > 0 {
+			if err := c.runCommandHook(tweet, payload); err != nil {
+				fmt.Println(err)
+			}
+		}
+		if c.Hooks.WebhookURL != "" {
+			if err := c.postWebhook(payload); err != nil {
+				fmt.Println(err)
+			}
+		}
+	}
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"html"
+	"regexp"
+	"strings"
+)
+
+var (
+	htmlLink       = regexp.MustCompile(`(?s)<a [^>]*href="([^"]*)"[^>]*>(.*?)</a>`)
+	htmlBreak      = regexp.MustCompile(`<br\s*/?>`)
+	htmlParagraph  = regexp.MustCompile(`</p>\s*<p[^>]*>`)
+	htmlTag        = regexp.MustCompile(`<[^>]*>`)
+	hashtagPattern = regexp.MustCompile(`#(\w+)`)
+)
+
+// htmlToText keeps line breaks, paragraphs and link targets of an HTML
+// snippet and drops the rest of the markup. Links labelled with their own
+// target, a hashtag or a mention only keep the label.
+func htmlToText(body string) string {
+	body = htmlBreak.ReplaceAllString(body, "\n")
+	body = htmlParagraph.ReplaceAllString(body, "\n\n")
+	body = htmlLink.ReplaceAllStringFunc(body, func(a string) string {
+		m := htmlLink.FindStringSubmatch(a)
+		href, label := html.UnescapeString(m[1]), html.UnescapeString(htmlTag.ReplaceAllString(m[2], ""))
+		if label == href || strings.HasPrefix(label, "#") || strings.HasPrefix(label, "@") || strings.HasPrefix(href, "?q=") {
+			return label
+		}
+		return label + " " + href
+	})
+	return strings.TrimSpace(html.UnescapeString(htmlTag.ReplaceAllString(body, "")))
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"time"
+)
+
+// httpHandler serves the optional plain HTTP side of the mirror, meant to
+// sit behind a TLS terminating reverse proxy.
+func (rh *RequestHandler) httpHandler() http.Handler {
+	mux := http.NewServeMux()
+	if rh.Config.Ingest.Secret != "" {
+		mux.HandleFunc("/ingest", rh.TweetCache.serveIngest)
+	}
+	if rh.Config.Twitter.Webhook {
+		mux.HandleFunc("/webhooks/twitter", rh.TweetCache.serveAccountActivity)
+	}
+	if rh.Config.Metrics.Scrape {
+		mux.HandleFunc("/metrics", rh.serveMetrics)
+	}
+	if ap := rh.TweetCache.activityPub; ap != nil {
+		ap.register(mux)
+	}
+	return mux
+}
+
+func ServeHTTP(ln net.Listener, handler http.Handler) error {
+	srv := &http.Server{
+		Handler:      handler,
+		ReadTimeout:  time.Minute,
+		WriteTimeout: time.Minute,
+	}
+	return srv.Serve(ln)
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// HTTP signatures as used by ActivityPub servers
+// (draft-cavage-http-signatures with rsa-sha256).
+
+// maxClockSkew is how far the Date of a signed request may be from now,
+// so a captured request can't be replayed later.
+const maxClockSkew = 5 * time.Minute
+
+func bodyDigest(body []byte) string {
+	sum := sha256.Sum256(body)
+	return "SHA-256=" + base64.StdEncoding.EncodeToString(sum[:])
+}
+
+func signingString(r *http.Request, headers []string) (string, error) {
+	var lines []string
+	for _, h := range headers {
+		switch h {
+		case "(request-target)":
+			lines = append(lines, fmt.Sprintf("(request-target): %s %s", strings.ToLower(r.Method), r.URL.RequestURI()))
+		case "host":
+			host := r.Host
+			if host == "" {
+				host = r.URL.Host
+			}
+			lines = append(lines, "host: "+host)
+		default:
+			v := r.Header.Get(h)
+			if v == "" {
+				return "", fmt.Errorf("signed header %q is missing", h)
+			}
+			lines = append(lines, h+": "+v)
+		}
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+func signRequest(r *http.Request, keyID string, key *rsa.PrivateKey, body []byte) error {
+	r.Header.Set("Date", time.Now().UTC().Format(http.TimeFormat))
+	headers := []string{"(request-target)", "host", "date"}
+	if body != nil {
+		r.Header.Set("Digest", bodyDigest(body))
+		headers = append(headers, "digest")
+	}
+	s, err := signingString(r, headers)
+	if err != nil {
+		return err
+	}
+	sum := sha256.Sum256([]byte(s))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, sum[:])
+	if err != nil {
+		return err
+	}
+	r.Header.Set("Signature", fmt.Sprintf(`keyId="%s",algorithm="rsa-sha256",headers="%s",signature="%s"`,
+		keyID, strings.Join(headers, " "), base64.StdEncoding.EncodeToString(sig)))
+	return nil
+}
+
+func parseSignature(header string) map[string]string {
+	params := map[string]string{}
+	for _, part := range strings.Split(header, ",") {
+		i := strings.Index(part, "=")
+		if i < 0 {
+			continue
+		}
+		params[strings.TrimSpace(part[:i])] = strings.Trim(strings.TrimSpace(part[i+1:]), `"`)
+	}
+	return params
+}
+
+func parsePublicKey(pemText string) (*rsa.PublicKey, error) {
+	block, _ := pem.Decode([]byte(pemText))
+	if block == nil {
+		return nil, errors.New("no PEM block in public key")
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	rsaKey, ok := key.(*rsa.PublicKey)
+	if !ok {
+		return nil, errors.New("public key is not RSA")
+	}
+	return rsaKey, nil
+}
+
+// verifyRequest checks r's signature with the key lookupKey returns for
+// its keyId, and returns that keyId. The signature must cover a Date
+// within maxClockSkew of now.
+func verifyRequest(r *http.Request, body []byte, lookupKey func(keyID string) (*rsa.PublicKey, error)) (string, error) {
+	params := parseSignature(r.Header.Get("Signature"))
+	keyID, sig64 := params["keyId"], params["signature"]
+	if keyID == "" || sig64 == "" {
+		return "", errors.New("request is not signed")
+	}
+	headers := strings.Fields(params["headers"])
+	if len(headers) == 0 {
+		headers = []string{"date"}
+	}
+	if !containsString(headers, "date") {
+		return "", errors.New("signature doesn't cover the date")
+	}
+	date, err := http.ParseTime(r.Header.Get("Date"))
+	if err != nil {
+		return "", errors.New("bad or missing date")
+	}
+	if skew := time.Since(date); skew > maxClockSkew || skew < -maxClockSkew {
+		return "", fmt.Errorf("date is %s off", skew.Truncate(time.Second))
+	}
+	if body != nil {
+		if !containsString(headers, "digest") {
+			return "", errors.New("signature doesn't cover the body digest")
+		}
+		if r.Header.Get("Digest") != bodyDigest(body) {
+			return "", errors.New("body digest mismatch")
+		}
+	}
+	s, err := signingString(r, headers)
+	if err != nil {
+		return "", err
+	}
+	sig, err := base64.StdEncoding.DecodeString(sig64)
+	if err != nil {
+		return "", err
+	}
+	key, err := lookupKey(keyID)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256([]byte(s))
+	if err := rsa.VerifyPKCS1v15(key, crypto.SHA256, sum[:], sig); err != nil {
+		return "", errors.New("bad signature")
+	}
+	return keyID, nil
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestVerifyRequest(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const keyID = "https://example.com/users/a#main-key"
+	body := []byte(`{"type":"Follow"}`)
+	lookup := func(string) (*rsa.PublicKey, error) { return &key.PublicKey, nil }
+	signed := func(t *testing.T) *http.Request {
+		r, err := http.NewRequest("POST", "https://mirror.example/ap/inbox", bytes.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := signRequest(r, keyID, key, body); err != nil {
+			t.Fatal(err)
+		}
+		return r
+	}
+
+	if got, err := verifyRequest(signed(t), body, lookup); err != nil || got != keyID {
+		t.Errorf("signed now: %q, %v", got, err)
+	}
+
+	tests := []struct {
+		name   string
+		change func(r *http.Request)
+		want   string
+	}{
+		{"stale date", func(r *http.Request) {
+			r.Header.Set("Date", time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat))
+		}, "date is"},
+		{"future date", func(r *http.Request) {
+			r.Header.Set("Date", time.Now().Add(time.Hour).UTC().Format(http.TimeFormat))
+		}, "date is"},
+		{"no date", func(r *http.Request) { r.Header.Del("Date") }, "bad or missing date"},
+		{"date not signed", func(r *http.Request) {
+			r.Header.Set("Signature", strings.Replace(r.Header.Get("Signature"), " date", "", 1))
+		}, "doesn't cover the date"},
+		{"other body", func(r *http.Request) { r.Header.Set("Digest", bodyDigest([]byte("{}"))) }, "body digest mismatch"},
+	}
+	for _, tt := range tests {
+		r := signed(t)
+		tt.change(r)
+		if _, err := verifyRequest(r, body, lookup); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: error %v, want %q", tt.name, err, tt.want)
+		}
+	}
+}
+
+func TestRemoteActorKeyFor(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const a, b = "https://example.com/users/a", "https://example.com/users/b"
+	actor := func(id, keyID, owner string) *remoteActor {
+		actor := &remoteActor{ID: id}
+		actor.PublicKey.ID, actor.PublicKey.Owner = keyID, owner
+		actor.PublicKey.PublicKeyPem = string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+		return actor
+	}
+
+	if _, err := actor(a, a+"#main-key", a).keyFor(a+"#main-key", a); err != nil {
+		t.Errorf("own key: %v", err)
+	}
+	for name, tt := range map[string]struct {
+		actor          *remoteActor
+		keyID, actorID string
+	}{
+		"other actor":    {actor(a, a+"#main-key", a), a + "#main-key", b},
+		"other key":      {actor(a, a+"#main-key", a), a + "#other-key", a},
+		"key owned by b": {actor(a, a+"#main-key", b), a + "#main-key", a},
+		"key not owned":  {actor(a, a+"#main-key", ""), a + "#main-key", a},
+	} {
+		if _, err := tt.actor.keyFor(tt.keyID, tt.actorID); err == nil {
+			t.Errorf("%s: key accepted", name)
+		}
+	}
+}
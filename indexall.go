@@ -0,0 +1,101 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"strconv"
+	"strings"
+
+	"github.com/dghubble/go-twitter/twitter"
+	"github.com/makeworld-the-better-one/go-gemini"
+)
+
+// Permalinks per /index-all page.
+const indexPageSize = 500
+
+func (rh *RequestHandler) formatIndexEntries(tweets []twitter.Tweet) string {
+	var entries strings.Builder
+	for _, tweet := range tweets {
+		date := ""
+		if t, err := tweet.CreatedAtTime(); err == nil {
+			date = t.Format(dateLayout) + " "
+		}
+		fmt.Fprintf(&entries, "=> %s %s%s\n", permalink(tweet), date, rh.Config.convertEmoji(entryTitle(tweet)))
+	}
+	return entries.String()
+}
+
+// indexPage returns the tweets on a numbered index page. Pages count from
+// the oldest tweet, so a full page keeps its URL and contents as new tweets
+// come in; the newest tweets that don't fill a page yet are listed on
+// /index-all itself.
+func indexPage(tweets []twitter.Tweet, page int) ([]twitter.Tweet, bool) {
+	full := len(tweets) / indexPageSize
+	if page < 1 || page > full {
+		return nil, false
+	}
+	to := len(tweets) - (page-1)*indexPageSize
+	return tweets[to-indexPageSize : to], true
+}
+
+// formatIndexAll lists every archived tweet for crawlers, which otherwise
+// only find the recent pages.
+func (rh *RequestHandler) formatIndexAll() string {
+	tweets := rh.TweetCache.archived()
+	full := len(tweets) / indexPageSize
+	page := "# All tweets\n\n"
+	if len(tweets) == 0 {
+		return page + "No tweets cached yet.\n"
+	}
+	page += "=> /archive.gmi.gz Download everything (gemtext, gzipped)\n=> /archive.gpub Download everything (Gempub book)\n\n"
+	page += rh.formatIndexEntries(tweets[:len(tweets)-full*indexPageSize])
+	if full > 0 {
+		page += "\n## Older tweets\n\n"
+	}
+	for n := full; n >= 1; n -= 1 {
+		entries, _ := indexPage(tweets, n)
+		first, _ := entries[len(entries)-1].CreatedAtTime()
+		last, _ := entries[0].CreatedAtTime()
+		page += fmt.Sprintf("=> /index-all/%d %s to %s\n", n, first.Format(dateLayout), last.Format(dateLayout))
+	}
+	return page
+}
+
+func (rh *RequestHandler) formatIndexPage(n int) (string, bool) {
+	entries, ok := indexPage(rh.TweetCache.archived(), n)
+	if !ok {
+		return "", false
+	}
+	page := fmt.Sprintf("# All tweets, page %d\n\n%s\n=> /index-all Newest tweets\n", n, rh.formatIndexEntries(entries))
+	if n > 1 {
+		page += fmt.Sprintf("=> /index-all/%d Older tweets\n", n-1)
+	}
+	return page, true
+}
+
+func (rh *RequestHandler) showIndexAll() *gemini.Response {
+	body := ioutil.NopCloser(bytes.NewBufferString(rh.wrapBody(rh.formatIndexAll())))
+	return Success(rh.meta(), body)
+}
+
+func (rh *RequestHandler) showIndexPage(n int) *gemini.Response {
+	page, ok := rh.formatIndexPage(n)
+	if !ok {
+		return NotFound("Unknown location")
+	}
+	body := ioutil.NopCloser(bytes.NewBufferString(rh.wrapBody(page)))
+	return Success(rh.meta(), body)
+}
+
+func (rh *RequestHandler) handleIndexAll(r Request, p Params) *gemini.Response {
+	return rh.showIndexAll()
+}
+
+func (rh *RequestHandler) handleIndexPage(r Request, p Params) *gemini.Response {
+	n, err := strconv.Atoi(p["page"])
+	if err != nil {
+		return NotFound("Unknown location")
+	}
+	return rh.showIndexPage(n)
+}
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestIndexAllListsArchive(t *testing.T) {
+	c := Config{}
+	c.Cache.MaxTweets = 5
+	h := newFixtureHandler(t, c, 600)
+
+	_, _, body := do(t, h, "/index-all")
+	if !strings.Contains(body, "=> /tweet/1600 ") || !strings.Contains(body, "=> /index-all/1 ") {
+		t.Fatalf("/index-all misses archived tweets:\n%s", body)
+	}
+	if status, _, body := do(t, h, "/index-all/1"); status != 20 || !strings.Contains(body, "=> /tweet/1001 ") {
+		t.Fatalf("/index-all/1: status %d:\n%s", status, body)
+	}
+	// Out of memory, but archived
+	if status, _, body := do(t, h, "/tweet/1001"); status != 20 || !strings.Contains(body, "Tweet 1 about") {
+		t.Fatalf("/tweet/1001: status %d:\n%s", status, body)
+	}
+	if status, _, _ := do(t, h, "/tweet/999"); status != 51 {
+		t.Fatalf("/tweet/999: status %d, want 51", status)
+	}
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/dghubble/go-twitter/twitter"
+)
+
+const maxIngestLength = 4 << 20
+
+// validSignature checks an X-Signature: sha256=<hex HMAC of the body>
+// header against the shared secret.
+func validSignature(secret string, body []byte, header string) bool {
+	sig, err := hex.DecodeString(strings.TrimPrefix(header, "sha256="))
+	if err != nil || !strings.HasPrefix(header, "sha256=") {
+		return false
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hmac.Equal(sig, mac.Sum(nil))
+}
+
+// decodePosts accepts a single tweet object or an array of them, in the
+// Twitter API's JSON shape.
+func decodePosts(body []byte) ([]twitter.Tweet, error) {
+	var tweets []twitter.Tweet
+	if trimmed := bytes.TrimSpace(body); len(trimmed) > 0 && trimmed[0] == '{' {
+		var tweet twitter.Tweet
+		if err := json.Unmarshal(body, &tweet); err != nil {
+			return nil, err
+		}
+		tweets = append(tweets, tweet)
+	} else if err := json.Unmarshal(body, &tweets); err != nil {
+		return nil, err
+	}
+
+	for _, tweet := range tweets {
+		if tweet.ID == 0 {
+			return nil, errors.New("post without id")
+		}
+		if _, err := tweet.CreatedAtTime(); err != nil {
+			return nil, fmt.Errorf("post %d: bad created_at: %v", tweet.ID, err)
+		}
+		if tweet.User == nil {
+			return nil, fmt.Errorf("post %d: no user", tweet.ID)
+		}
+	}
+	sortNewestFirst(tweets)
+	return tweets, nil
+}
+
+func (tc *TweetCache) serveIngest(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxIngestLength))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !validSignature(tc.Config.Ingest.Secret, body, r.Header.Get("X-Signature")) {
+		http.Error(w, "bad signature", http.StatusUnauthorized)
+		return
+	}
+	tweets, err := decodePosts(body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if tc.merge(tweets) {
+		tc.changed()
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
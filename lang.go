@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/dghubble/go-twitter/twitter"
+)
+
+func geminiMeta(lang string) string {
+	if lang == "" {
+		return "text/gemini"
+	}
+	return fmt.Sprintf("text/gemini; lang=%s", lang)
+}
+
+func (rh *RequestHandler) meta() string {
+	return geminiMeta(rh.Config.UI.Lang)
+}
+
+// tweetMeta prefers the language Twitter detected for the tweet. "und"
+// means it couldn't tell.
+func (rh *RequestHandler) tweetMeta(tweet twitter.Tweet) string {
+	if tweet.Lang == "" || tweet.Lang == "und" {
+		return rh.meta()
+	}
+	return geminiMeta(tweet.Lang)
+}
+
+// Languages limits a listing to tweets in the given languages, as
+// detected by Twitter. Empty means no limit.
+type Languages []string
+
+func (l Languages) Contains(tweet twitter.Tweet) bool {
+	if len(l) == 0 {
+		return true
+	}
+	for _, lang := range l {
+		if strings.EqualFold(lang, tweet.Lang) {
+			return true
+		}
+	}
+	return false
+}
+
+func (l Languages) String() string {
+	return strings.Join(l, ", ")
+}
+
+// parseLanguages reads ?lang=en or ?lang=en,de. An explicit "all" lifts
+// the configured default.
+func parseLanguages(u url.URL, def []string) Languages {
+	param := u.Query().Get("lang")
+	if param == "" {
+		return def
+	}
+	if param == "all" {
+		return nil
+	}
+	var langs Languages
+	for _, lang := range strings.Split(param, ",") {
+		if lang = strings.TrimSpace(lang); lang != "" {
+			langs = append(langs, lang)
+		}
+	}
+	return langs
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/dghubble/go-twitter/twitter"
+)
+
+// Words of a tweet quoted in its heading on long pages.
+const headingWords = 8
+
+// tweetHeading names the author and the time of a tweet, for the headings
+// layout.
+func (rh *RequestHandler) tweetHeading(tweet twitter.Tweet) string {
+	heading := ""
+	if tweet.User != nil {
+		heading = rh.Config.convertEmoji(tweet.User.Name)
+	}
+	if t, err := tweet.CreatedAtTime(); err == nil {
+		heading += " · " + t.Format("2006-01-02 15:04")
+	}
+	if name := rh.TweetCache.sourceName(tweet); name != "" {
+		heading += " · via " + name
+	}
+	return heading
+}
+
+// formatEntry lays out one tweet of a list: under a heading of its own, or
+// followed by the delimiter in the classic layout.
+func (rh *RequestHandler) formatEntry(tweet twitter.Tweet, text string) string {
+	if entry, ok := rh.templateEntry(tweet, text); ok {
+		return entry
+	}
+	if rh.Config.UI.Layout == "classic" {
+		return fmt.Sprintf("\n\n%s\n=> %s %s\n\n%s", text, permalink(tweet), rh.Config.label("permalink"), rh.Config.delimiter())
+	}
+	return fmt.Sprintf("\n\n## %s\n\n%s\n=> %s %s", rh.tweetHeading(tweet), text, permalink(tweet), rh.Config.label("permalink"))
+}
+
+// archiveHeading is a tweet's time and first words, which stay the same as
+// long as the tweet does, for the outline of long pages.
+func archiveHeading(tweet twitter.Tweet) string {
+	words := strings.Fields(expandLinks(tweet))
+	heading := strings.Join(words, " ")
+	if len(words) > headingWords {
+		heading = strings.Join(words[:headingWords], " ") + "…"
+	}
+	if t, err := tweet.CreatedAtTime(); err == nil {
+		heading = t.Format("2006-01-02 15:04") + " · " + heading
+	}
+	return heading
+}
+
+// formatLongEntry lays out the nth tweet, counting from 1, of a thread or
+// archive page at top. Tweets sit under ### headings so the page's own
+// heading stays above them in outlines, and every few tweets there's a
+// way back up.
+func (rh *RequestHandler) formatLongEntry(tweet twitter.Tweet, text string, n int, top string) string {
+	entry := rh.formatEntry(tweet, text)
+	if rh.Config.entryTemplate == nil && rh.Config.UI.Layout == "headings" {
+		entry = fmt.Sprintf("\n\n### %s\n\n%s\n=> %s %s", rh.Config.convertEmoji(archiveHeading(tweet)), text, permalink(tweet), rh.Config.label("permalink"))
+	}
+	if every := rh.Config.UI.BackToTopEvery; every > 0 && n%every == 0 {
+		entry += fmt.Sprintf("\n\n=> %s %sBack to top", top, rh.Config.glyph("↑ ", ""))
+	}
+	return entry
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+)
+
+// Exit codes from sysexits(3), so init scripts can tell a broken config
+// from a server that failed at runtime.
+const (
+	exitFailure = 1
+	exitConfig  = 78
+)
+
+type ConfigError struct {
+	Path string
+	Err  error
+}
+
+func (e *ConfigError) Error() string {
+	return fmt.Sprintf("config %s: %v", e.Path, e.Err)
+}
+
+func (e *ConfigError) Unwrap() error {
+	return e.Err
+}
+
+func exitCode(err error) int {
+	var configErr *ConfigError
+	if errors.As(err, &configErr) {
+		return exitConfig
+	}
+	return exitFailure
+}
+
+func exit(err error) {
+	fmt.Println(err)
+	os.Exit(exitCode(err))
+}
+
+func writePidFile(path string) error {
+	return ioutil.WriteFile(path, []byte(strconv.Itoa(os.Getpid())+"\n"), 0644)
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/dghubble/go-twitter/twitter"
+)
+
+var urlPattern = regexp.MustCompile(`https?://[^\s]+`)
+
+type RewriteRule struct {
+	Pattern string `yaml:"pattern"`
+	Replace string `yaml:"replace"`
+
+	re *regexp.Regexp
+}
+
+func (c *Config) compileRewriteRules() error {
+	for i := range c.Links.Rewrite {
+		re, err := regexp.Compile(c.Links.Rewrite[i].Pattern)
+		if err != nil {
+			return err
+		}
+		c.Links.Rewrite[i].re = re
+	}
+	return nil
+}
+
+// rewriteURL applies the first matching rewrite rule to u.
+func (c *Config) rewriteURL(u string) string {
+	for _, rule := range c.Links.Rewrite {
+		if rule.re != nil && rule.re.MatchString(u) {
+			return rule.re.ReplaceAllString(u, rule.Replace)
+		}
+	}
+	return u
+}
+
+func (c *Config) rewriteLinks(text string) string {
+	if len(c.Links.Rewrite) == 0 {
+		return text
+	}
+	return urlPattern.ReplaceAllStringFunc(text, c.rewriteURL)
+}
+
+// expandLinks replaces t.co short links with the URLs they point to, so that
+// rewrite rules can match on the real destination.
+func expandLinks(tweet twitter.Tweet) string {
+	text := tweet.Text
+	if tweet.Entities == nil {
+		return text
+	}
+	for _, u := range tweet.Entities.Urls {
+		if u.URL != "" && u.ExpandedURL != "" {
+			text = strings.Replace(text, u.URL, u.ExpandedURL, -1)
+		}
+	}
+	return text
+}
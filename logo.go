@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/makeworld-the-better-one/go-gemini"
+)
+
+// loadLogo reads a logo file, falling back to the inline text when no file
+// is configured or it doesn't exist.
+func loadLogo(file, inline string) (string, error) {
+	if file == "" {
+		return inline, nil
+	}
+	b, err := ioutil.ReadFile(file)
+	if errors.Is(err, os.ErrNotExist) {
+		return inline, nil
+	} else if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// formatLogo fences the logo as preformatted text so clients can hide it or
+// announce alt instead of reading the art out.
+func formatLogo(logo, alt string) string {
+	logo = strings.TrimRight(logo, "\n")
+	if logo == "" {
+		return ""
+	}
+	return fmt.Sprintf("```%s\n%s\n```", alt, logo)
+}
+
+func (rh *RequestHandler) getLogo() string {
+	rh.logoOnce.Do(func() {
+		if rh.Config.title != "" {
+			rh.logo = "# " + rh.Config.title
+			return
+		}
+		rh.logo = rh.readLogo(rh.Config.UI.AsciiLogoFile, rh.Config.UI.AsciiLogo)
+		rh.altLogo = rh.readLogo(rh.Config.UI.AltLogoFile, rh.Config.UI.AltLogo)
+	})
+	return rh.logo
+}
+
+func (rh *RequestHandler) readLogo(file, inline string) string {
+	logo, err := loadLogo(file, inline)
+	if err != nil {
+		fmt.Println(err)
+	}
+	if rh.Config.UI.Accessible {
+		return rh.Config.UI.AsciiLogoAlt
+	}
+	return formatLogo(logo, rh.Config.UI.AsciiLogoAlt)
+}
+
+// chooseLogo swaps the logo at the top of a page for the alternative one
+// when asked with ?logo=alt, e.g. for a dark or light background, and
+// drops it from inner pages when ui.logoPages is home.
+func (rh *RequestHandler) chooseLogo(next Handler) Handler {
+	return Func(func(r Request) *gemini.Response {
+		variant, found := rh.takeQueryParam(&r, "logo")
+		if found && variant != "alt" {
+			return BadRequest("logo must be alt")
+		}
+
+		resp := next.Handle(r)
+		logo := rh.getLogo()
+		replacement := logo
+		if rh.Config.UI.LogoPages == "home" && r.URL.Path != "/" && r.URL.Path != "" {
+			replacement = ""
+		} else if variant == "alt" && rh.altLogo != "" {
+			replacement = rh.altLogo
+		}
+		if replacement == logo || resp.Status != 20 || resp.Body == nil || !strings.HasPrefix(resp.Meta, "text/gemini") {
+			return resp
+		}
+		page, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return TempFailure("Failed to render page")
+		}
+		body := string(page)
+		if strings.HasPrefix(body, logo) {
+			body = strings.TrimLeft(replacement+body[len(logo):], "\n")
+		}
+		return Success(resp.Meta, ioutil.NopCloser(bytes.NewBufferString(body)))
+	})
+}
@@ -6,12 +6,16 @@ import (
 	"flag"
 	"fmt"
 	"gopkg.in/yaml.v2"
-	"io"
 	"io/ioutil"
+	"net"
+	"net/http"
 	"net/url"
 	"os"
-	"strconv"
+	"path/filepath"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"text/template"
 	"time"
 
 	"github.com/dghubble/go-twitter/twitter"
@@ -20,9 +24,15 @@ import (
 )
 
 type Config struct {
+	// twitter, telegram, feed, mastodon or aggregate
+	Source string `yaml:"source"`
+	// What to aggregate when source is aggregate
+	Sources []SourceConfig `yaml:"sources"`
+
 	Addr struct {
 		Host string `yaml:"host"`
 		Port int    `yaml:"port"`
+		URL  string `yaml:"url"`
 	} `yaml:"addr"`
 	Cert struct {
 		CertFile string `yaml:"certFile"`
@@ -35,189 +45,1157 @@ type Config struct {
 		AccessSecret   string `yaml:"accessSecret"`
 		UserID         int64  `yaml:"userID"`
 		ScreenName     string `yaml:"screenName"`
+		// Extra key sets to fall back on when one is rate limited
+		Apps []TwitterApp `yaml:"apps"`
+		// Replaces https://api.twitter.com, for mocks and compatible gateways
+		APIBaseURL string `yaml:"apiBaseURL"`
+		// Accept Account Activity API pushes at /webhooks/twitter
+		Webhook bool `yaml:"webhook"`
+		// API calls allowed per 15 minutes and per UTC day, 0 for no limit
+		Quota struct {
+			PerWindow int `yaml:"perWindow"`
+			PerDay    int `yaml:"perDay"`
+		} `yaml:"quota"`
 	} `yaml:"twitter"`
+	Telegram struct {
+		Channel string `yaml:"channel"`
+	} `yaml:"telegram"`
+	Feed struct {
+		URL string `yaml:"url"`
+	} `yaml:"feed"`
+	Mastodon struct {
+		// user@instance
+		Account string `yaml:"account"`
+	} `yaml:"mastodon"`
+	HTTP struct {
+		UserAgent string `yaml:"userAgent"`
+		// Optional plain HTTP listener, e.g. ":8080"
+		Listen string `yaml:"listen"`
+	} `yaml:"http"`
+	Ingest struct {
+		// Shared secret for POST /ingest; empty disables the endpoint
+		Secret string `yaml:"secret"`
+	} `yaml:"ingest"`
+	ActivityPub struct {
+		Enabled bool `yaml:"enabled"`
+		// Public https URL the HTTP listener is reachable at
+		BaseURL       string `yaml:"baseURL"`
+		Username      string `yaml:"username"`
+		KeyFile       string `yaml:"keyFile"`
+		FollowersFile string `yaml:"followersFile"`
+	} `yaml:"activityPub"`
 	UI struct {
 		AsciiLogoFile string `yaml:"asciiLogoFile"`
-		Delimiter     string `yaml:"delimiter"`
+		AsciiLogo     string `yaml:"asciiLogo"`
+		AsciiLogoAlt  string `yaml:"asciiLogoAlt"`
+		// Picked with ?logo=alt
+		AltLogoFile string `yaml:"altLogoFile"`
+		AltLogo     string `yaml:"altLogo"`
+		// all, or home to only show the logo on the front page
+		LogoPages string `yaml:"logoPages"`
+		// What / serves: profile, or a redirect to timeline or latest
+		FrontPage string `yaml:"frontPage"`
+		// Old paths permanently redirected to new ones
+		Aliases map[string]string `yaml:"aliases"`
+		// Render profiles by route pattern, full or bare
+		Profiles map[string]string `yaml:"profiles"`
+		// Built in, or a directory in themesDir; see theme.go
+		Theme     string `yaml:"theme"`
+		ThemesDir string `yaml:"themesDir"`
+		// Theme for pages asked for with ?experimental=1
+		Experimental string `yaml:"experimental"`
+		// text/template for each tweet of a list, see templates.go
+		EntryTemplate  string   `yaml:"entryTemplate"`
+		Delimiter      string   `yaml:"delimiter"`
+		Layout         string   `yaml:"layout"`
+		BackToTopEvery int      `yaml:"backToTopEvery"`
+		MaxPageBytes   int      `yaml:"maxPageBytes"`
+		FrontPageCount int      `yaml:"frontPageCount"`
+		Order          string   `yaml:"order"`
+		ThreadOrder    string   `yaml:"threadOrder"`
+		Emoji          string   `yaml:"emoji"`
+		Wrap           int      `yaml:"wrap"`
+		BidiIsolate    bool     `yaml:"bidiIsolate"`
+		Mode           string   `yaml:"mode"`
+		ShowVersion    bool     `yaml:"showVersion"`
+		Lang           string   `yaml:"lang"`
+		Languages      []string `yaml:"languages"`
+		ANSI           bool     `yaml:"ansi"`
+		Format         string   `yaml:"format"`
+		// Output for screen readers
+		Accessible bool `yaml:"accessible"`
 	} `yaml:"ui"`
+	Middleware struct {
+		AccessLog bool `yaml:"accessLog"`
+		RateLimit int  `yaml:"rateLimit"`
+	} `yaml:"middleware"`
+	Metrics struct {
+		// Serve Prometheus metrics at /metrics on http.listen
+		Scrape bool `yaml:"scrape"`
+		// Push them instead to a Pushgateway, e.g. http://host:9091
+		PushgatewayURL string `yaml:"pushgatewayURL"`
+		// or send them to statsd at host:port
+		Statsd          string `yaml:"statsd"`
+		IntervalSeconds int    `yaml:"intervalSeconds"`
+		// Pushgateway instance label, defaults to the hostname
+		Instance string `yaml:"instance"`
+	} `yaml:"metrics"`
+	Redis struct {
+		// host:port of a Redis shared by instances mirroring the same account
+		Addr     string `yaml:"addr"`
+		Password string `yaml:"password"`
+		DB       int    `yaml:"db"`
+		Prefix   string `yaml:"prefix"`
+	} `yaml:"redis"`
+	Auth struct {
+		Paths        []string `yaml:"paths"`
+		Fingerprints []string `yaml:"fingerprints"`
+	} `yaml:"auth"`
+	Cache struct {
+		ArchiveFile string `yaml:"archiveFile"`
+		MaxTweets   int    `yaml:"maxTweets"`
+		MaxAgeDays  int    `yaml:"maxAgeDays"`
+	} `yaml:"cache"`
+	Hooks struct {
+		Command    []string `yaml:"command"`
+		WebhookURL string   `yaml:"webhookURL"`
+	} `yaml:"hooks"`
+	Alerts struct {
+		// Fetch errors kept for the admin page
+		History int `yaml:"history"`
+		// Alert after this many failed fetches in a row, -1 never
+		FetchFailures int      `yaml:"fetchFailures"`
+		Command       []string `yaml:"command"`
+		WebhookURL    string   `yaml:"webhookURL"`
+	} `yaml:"alerts"`
+	Misfin struct {
+		CertFile   string   `yaml:"certFile"`
+		KeyFile    string   `yaml:"keyFile"`
+		Recipients []string `yaml:"recipients"`
+	} `yaml:"misfin"`
+	Publish struct {
+		// titan:// URL of the remote capsule's root; links in the pages
+		// are absolute paths, so publish to the root of a host
+		URL      string `yaml:"url"`
+		Token    string `yaml:"token"`
+		CertFile string `yaml:"certFile"`
+		KeyFile  string `yaml:"keyFile"`
+		// Only fetch and publish, don't serve Gemini ourselves
+		Only bool `yaml:"only"`
+	} `yaml:"publish"`
+	Snapshots struct {
+		Enabled       bool   `yaml:"enabled"`
+		File          string `yaml:"file"`
+		IntervalHours int    `yaml:"intervalHours"`
+	} `yaml:"snapshots"`
+	ReadState struct {
+		// Remember the newest tweet each client certificate has seen
+		Enabled bool   `yaml:"enabled"`
+		File    string `yaml:"file"`
+	} `yaml:"readState"`
+	ReaderBookmarks struct {
+		// Let readers bookmark tweets under their client certificate
+		Enabled bool   `yaml:"enabled"`
+		File    string `yaml:"file"`
+	} `yaml:"readerBookmarks"`
+	Comments struct {
+		// Let readers with a client certificate comment on tweets
+		Enabled bool   `yaml:"enabled"`
+		File    string `yaml:"file"`
+		// Hold new comments until approved on /admin/comments
+		Moderate  bool `yaml:"moderate"`
+		MaxLength int  `yaml:"maxLength"`
+		// Comments a certificate may leave per minute
+		RateLimit int `yaml:"rateLimit"`
+	} `yaml:"comments"`
+	Announcement struct {
+		Text string `yaml:"text"`
+		// RFC 3339, empty to show it until removed
+		Until string `yaml:"until"`
+	} `yaml:"announcement"`
+	Maintenance struct {
+		// Answer everything but /admin with 41 at start up
+		Enabled      bool   `yaml:"enabled"`
+		Message      string `yaml:"message"`
+		RetryMinutes int    `yaml:"retryMinutes"`
+	} `yaml:"maintenance"`
+	Bookmarks struct {
+		Enabled bool `yaml:"enabled"`
+		// OAuth 2.0 user access token with the bookmark.read scope
+		BearerToken string `yaml:"bearerToken"`
+		Private     bool   `yaml:"private"`
+	} `yaml:"bookmarks"`
+	Replies struct {
+		Enabled      bool `yaml:"enabled"`
+		CacheMinutes int  `yaml:"cacheMinutes"`
+	} `yaml:"replies"`
+	Media struct {
+		// proxy through /media/, link to the original, or off
+		Mode string `yaml:"mode"`
+		// Larger media are linked to instead of proxied
+		MaxBytes int64    `yaml:"maxBytes"`
+		Types    []string `yaml:"types"`
+		// Bytes proxied per UTC day, 0 means no limit
+		DailyBytes int64 `yaml:"dailyBytes"`
+		// Shrink proxied images larger than this many pixels on a side,
+		// 0 keeps their size
+		MaxDimension int `yaml:"maxDimension"`
+		// Re-encode proxied JPEGs at this quality, 1-100; 0 leaves them
+		// as they are unless they're shrunk
+		Quality int `yaml:"quality"`
+		// Longer side of thumbnails, in pixels
+		ThumbnailSize int `yaml:"thumbnailSize"`
+	} `yaml:"media"`
+	Proxy struct {
+		Enabled      bool `yaml:"enabled"`
+		CacheMinutes int  `yaml:"cacheMinutes"`
+		// Requests per minute per client IP to /u/
+		RateLimit int `yaml:"rateLimit"`
+		// Screen names; with allow set, only those are mirrored
+		Allow []string `yaml:"allow"`
+		Deny  []string `yaml:"deny"`
+		// Accounts cached at once, -1 for no limit
+		MaxAccounts int `yaml:"maxAccounts"`
+	} `yaml:"proxy"`
+	Links struct {
+		Rewrite []RewriteRule `yaml:"rewrite"`
+		Twitter string        `yaml:"twitter"`
+		Nitter  string        `yaml:"nitter"`
+	} `yaml:"links"`
+	Refresh struct {
+		IntervalMinutes int `yaml:"intervalMinutes"`
+		// Cron expressions for times when the API isn't polled
+		QuietHours []string `yaml:"quietHours"`
+		// Look the account up first and skip the timeline when it's unchanged
+		SkipUnchanged bool `yaml:"skipUnchanged"`
+	} `yaml:"refresh"`
+
+	quietHours    []cronSchedule
+	entryTemplate *template.Template
+	// Whether entryTemplate says how long ago tweets were, which a cached
+	// page would soon get wrong
+	relativeTimes bool
+	// Link labels from the theme
+	strings map[string]string
+	// The file the config was read from
+	path string
+	// What ?experimental=1 pages are rendered with
+	experimental *Config
+	// The config before it was completed, to render with other themes
+	asRead *Config
+	// Set on the configs rendering an aggregated source's pages, with the
+	// heading they put in place of the logo
+	forSource bool
+	title     string
 }
 
-func (c *Config) Parse(path string) {
+func (c *Config) Parse(path string) error {
 	f, err := os.Open(path)
 	if err != nil {
-		panic(err)
+		return &ConfigError{path, err}
 	}
 	defer f.Close()
 
 	decoder := yaml.NewDecoder(f)
 	err = decoder.Decode(&c)
 	if err != nil {
-		panic(err)
+		return &ConfigError{path, err}
+	}
+
+	// The experimental config is the same as read, with another theme.
+	exp := *c
+	err = c.prepare(path)
+	if err != nil {
+		return &ConfigError{path, err}
+	}
+	if c.UI.Experimental != "" {
+		exp.UI.Theme, exp.UI.Experimental = c.UI.Experimental, ""
+		if err := exp.prepare(path); err != nil {
+			return &ConfigError{path, fmt.Errorf("ui.experimental: %v", err)}
+		}
+		c.experimental = &exp
+	}
+	return nil
+}
+
+// prepare fills in and checks a config as read from path.
+func (c *Config) prepare(path string) error {
+	err := c.complete(filepath.Dir(path))
+	if err != nil {
+		return err
+	}
+	c.resolvePaths(path)
+	c.path = path
+
+	return c.validate()
+}
+
+// complete compiles and fills in the settings of a config, with themes
+// looked for relative to dir.
+func (c *Config) complete(dir string) error {
+	read := *c
+	c.asRead = &read
+
+	err := c.compileRewriteRules()
+	if err != nil {
+		return err
+	}
+
+	err = c.applyTheme(dir)
+	if err != nil {
+		return err
+	}
+
+	err = c.checkSourceThemes()
+	if err != nil {
+		return err
+	}
+
+	err = c.compileTemplates()
+	if err != nil {
+		return err
+	}
+
+	err = c.compileQuietHours()
+	if err != nil {
+		return err
+	}
+
+	c.setDefaults()
+	return nil
+}
+
+func (c Config) validateSource() error {
+	switch c.Source {
+	case "twitter":
+	case "telegram":
+		if c.Telegram.Channel == "" {
+			return errors.New("telegram.channel is required for the telegram source")
+		}
+	case "feed":
+		if c.Feed.URL == "" {
+			return errors.New("feed.url is required for the feed source")
+		}
+	case "mastodon":
+		if _, _, ok := splitAccount(c.Mastodon.Account); !ok {
+			return fmt.Errorf("mastodon.account must look like user@instance, got %q", c.Mastodon.Account)
+		}
+	default:
+		return fmt.Errorf("source must be twitter, telegram, feed, mastodon or aggregate, got %q", c.Source)
+	}
+	return nil
+}
+
+func (c *Config) validate() error {
+	if c.Source == "aggregate" {
+		if len(c.Sources) == 0 {
+			return errors.New("sources must list at least one source to aggregate")
+		}
+		for i, sc := range c.Sources {
+			if err := sc.config(*c).validateSource(); err != nil {
+				return fmt.Errorf("sources[%d]: %v", i, err)
+			}
+		}
+	} else if err := c.validateSource(); err != nil {
+		return err
+	}
+	switch c.UI.Mode {
+	case "timeline", "digest":
+	default:
+		return fmt.Errorf("ui.mode must be timeline or digest, got %q", c.UI.Mode)
+	}
+	if err := c.validateProfiles(); err != nil {
+		return err
+	}
+	switch c.UI.LogoPages {
+	case "all", "home":
+	default:
+		return fmt.Errorf("ui.logoPages must be all or home, got %q", c.UI.LogoPages)
+	}
+	switch c.UI.FrontPage {
+	case "profile", "timeline", "latest":
+	default:
+		return fmt.Errorf("ui.frontPage must be profile, timeline or latest, got %q", c.UI.FrontPage)
+	}
+	for from := range c.UI.Aliases {
+		if !strings.HasPrefix(from, "/") || from == "/" {
+			return fmt.Errorf("ui.aliases must map paths below /, got %q", from)
+		}
+	}
+	switch c.UI.Layout {
+	case "headings", "classic":
+	default:
+		return fmt.Errorf("ui.layout must be headings or classic, got %q", c.UI.Layout)
+	}
+	switch c.Media.Mode {
+	case "proxy", "link", "off":
+	default:
+		return fmt.Errorf("media.mode must be proxy, link or off, got %q", c.Media.Mode)
+	}
+	if c.Media.Quality < 0 || c.Media.Quality > 100 {
+		return fmt.Errorf("media.quality must be between 0 and 100, got %d", c.Media.Quality)
+	}
+	switch c.UI.Emoji {
+	case "keep", "strip", "shortcode":
+	default:
+		return fmt.Errorf("ui.emoji must be keep, strip or shortcode, got %q", c.UI.Emoji)
+	}
+	if _, ok := c.renderer(c.UI.Format); !ok {
+		return fmt.Errorf("ui.format must be one of %s, got %q", strings.Join(c.formatNames(), ", "), c.UI.Format)
+	}
+	if c.Metrics.Scrape && c.HTTP.Listen == "" {
+		return errors.New("metrics.scrape needs http.listen")
+	}
+	if c.Metrics.PushgatewayURL != "" {
+		u, err := url.Parse(c.Metrics.PushgatewayURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("metrics.pushgatewayURL must be an http(s) URL, got %q", c.Metrics.PushgatewayURL)
+		}
+	}
+	if c.Metrics.Statsd != "" {
+		if _, _, err := net.SplitHostPort(c.Metrics.Statsd); err != nil {
+			return fmt.Errorf("metrics.statsd must be host:port, got %q", c.Metrics.Statsd)
+		}
+	}
+	if c.Ingest.Secret != "" && c.HTTP.Listen == "" {
+		return errors.New("ingest needs http.listen")
+	}
+	if c.Proxy.Enabled && !c.usesTwitter() {
+		return errors.New("proxy needs the twitter source for its credentials")
+	}
+	if c.Twitter.Webhook && (!c.usesTwitter() || c.HTTP.Listen == "") {
+		return errors.New("twitter.webhook needs the twitter source and http.listen")
+	}
+	if c.ActivityPub.Enabled {
+		u, err := url.Parse(c.ActivityPub.BaseURL)
+		if err != nil || u.Scheme == "" || u.Host == "" {
+			return fmt.Errorf("activityPub.baseURL must be an absolute URL, got %q", c.ActivityPub.BaseURL)
+		}
+		if c.HTTP.Listen == "" || c.ActivityPub.Username == "" || c.ActivityPub.KeyFile == "" || c.ActivityPub.FollowersFile == "" {
+			return errors.New("activityPub needs http.listen, username, keyFile and followersFile")
+		}
+	}
+	if c.Publish.URL != "" {
+		u, err := url.Parse(c.Publish.URL)
+		if err != nil || u.Scheme != "titan" || u.Host == "" {
+			return fmt.Errorf("publish.url must be a titan:// URL, got %q", c.Publish.URL)
+		}
+	} else if c.Publish.Only {
+		return errors.New("publish.only needs publish.url")
+	}
+	if c.Redis.Addr != "" {
+		if _, _, err := net.SplitHostPort(c.Redis.Addr); err != nil {
+			return fmt.Errorf("redis.addr must be host:port, got %q", c.Redis.Addr)
+		}
+	}
+	if c.Twitter.Quota.PerWindow < 0 || c.Twitter.Quota.PerDay < 0 {
+		return errors.New("twitter.quota must not be negative, 0 means no limit")
+	}
+	if c.Maintenance.RetryMinutes < 0 {
+		return errors.New("maintenance.retryMinutes must not be negative")
+	}
+	if c.Comments.MaxLength < 0 || c.Comments.RateLimit < 0 {
+		return errors.New("comments.maxLength and comments.rateLimit must not be negative")
+	}
+	if _, err := c.announcementUntil(); err != nil {
+		return fmt.Errorf("announcement.until must be a time like 2024-06-01T00:00:00Z, got %q", c.Announcement.Until)
+	}
+	if c.Twitter.APIBaseURL != "" {
+		u, err := url.Parse(c.Twitter.APIBaseURL)
+		if err != nil || u.Scheme == "" || u.Host == "" {
+			return fmt.Errorf("twitter.apiBaseURL must be an absolute URL, got %q", c.Twitter.APIBaseURL)
+		}
+	}
+	return nil
+}
+
+func (c *Config) setDefaults() {
+	if c.UI.MaxPageBytes == 0 {
+		c.UI.MaxPageBytes = 256 << 10
+	}
+	if c.UI.BackToTopEvery == 0 {
+		c.UI.BackToTopEvery = 5
+	}
+	if c.UI.LogoPages == "" {
+		c.UI.LogoPages = "all"
+	}
+	if c.UI.FrontPage == "" {
+		c.UI.FrontPage = "profile"
+	}
+	if c.UI.Layout == "" {
+		c.UI.Layout = "headings"
+	}
+	if c.Source == "" {
+		c.Source = "twitter"
+	}
+	if c.UI.AsciiLogoAlt == "" {
+		c.UI.AsciiLogoAlt = "ASCII logo"
+	}
+	if c.UI.FrontPageCount == 0 {
+		c.UI.FrontPageCount = 3
+	}
+	if c.UI.Order == "" {
+		c.UI.Order = "desc"
+	}
+	if c.UI.ThreadOrder == "" {
+		c.UI.ThreadOrder = "asc"
+	}
+	if c.UI.Mode == "" {
+		c.UI.Mode = "timeline"
+	}
+	if c.UI.Emoji == "" {
+		c.UI.Emoji = "keep"
+	}
+	if c.UI.Format == "" {
+		c.UI.Format = "gmi"
+	}
+	if c.Alerts.History == 0 {
+		c.Alerts.History = 20
+	}
+	if c.Alerts.FetchFailures == 0 {
+		c.Alerts.FetchFailures = 6
+	}
+	if c.Metrics.IntervalSeconds == 0 {
+		c.Metrics.IntervalSeconds = 60
+	}
+	if c.Redis.Prefix == "" {
+		c.Redis.Prefix = "donaldgem:"
+	}
+	if c.Snapshots.IntervalHours == 0 {
+		c.Snapshots.IntervalHours = 24
+	}
+	if c.Refresh.IntervalMinutes == 0 {
+		c.Refresh.IntervalMinutes = 15
+	}
+	if c.Media.Mode == "" {
+		c.Media.Mode = "link"
+	}
+	if c.Media.ThumbnailSize == 0 {
+		c.Media.ThumbnailSize = 200
+	}
+	if c.Media.MaxBytes == 0 {
+		c.Media.MaxBytes = 5 << 20
+	}
+	if len(c.Media.Types) == 0 {
+		c.Media.Types = []string{"image/jpeg", "image/png", "image/gif", "image/webp"}
+	}
+	if c.Proxy.CacheMinutes == 0 {
+		c.Proxy.CacheMinutes = 5
+	}
+	if c.Proxy.RateLimit == 0 {
+		c.Proxy.RateLimit = 5
+	}
+	if c.Proxy.MaxAccounts == 0 {
+		c.Proxy.MaxAccounts = 50
+	}
+	if c.Comments.MaxLength == 0 {
+		c.Comments.MaxLength = 500
+	}
+	if c.Comments.RateLimit == 0 {
+		c.Comments.RateLimit = 2
+	}
+	if c.Maintenance.Message == "" {
+		c.Maintenance.Message = "Mirror temporarily paused for maintenance"
+	}
+	if c.Replies.CacheMinutes == 0 {
+		c.Replies.CacheMinutes = 10
+	}
+	if c.HTTP.UserAgent == "" {
+		c.HTTP.UserAgent = "donaldgem/" + version
+		if version == "" {
+			c.HTTP.UserAgent = "donaldgem"
+		}
 	}
 }
 
 type TweetCache struct {
+	generation uint64 // first for 64-bit atomic alignment
+
 	Config
 	Tweets      []twitter.Tweet
+	Pinned      *twitter.Tweet
+	Bookmarks   []v2Tweet
 	LastRefresh time.Time
+
+	CredentialsProblem string
+	LastError          string
+	// Set while the account is protected, suspended or gone
+	AccountState string
+
+	source       Source
+	activityPub  *ActivityPub
+	archive      *Archive
+	snapshots    *Snapshots
+	readState    *ReadState
+	changes      *RefreshChanges
+	archiveCheck *ArchiveCheck
+	lastFetch    []twitter.Tweet
+	fetchErrors  FetchErrors
+	quota        APIQuota
+	redis        *Redis
+	events       EventBus
+	unauthorized int
+	app          int
+
+	// The last timeline fetch, and how many refresh.skipUnchanged saved
+	mark           accountMark
+	skippedFetches int
+
+	// Bookmarks of readers here, unlike Bookmarks from Twitter
+	readerBookmarks *ReaderBookmarks
+	comments        *Comments
+
+	announcement Announcement
+	// 1 while paused for maintenance
+	maintenance uint32
+
+	refreshMu  sync.Mutex
+	refreshing *refreshCall
+	mergeMu    sync.Mutex
+	// Guards source, which /admin/sources swaps
+	sourceMu sync.RWMutex
+	// Held by the background work writing state files, which ends once
+	// stopped is set
+	stopMu  sync.RWMutex
+	stopped bool
+}
+
+func NewTweetCache(c Config) (*TweetCache, error) {
+	archive, err := LoadArchive(c.Cache.ArchiveFile)
+	if err != nil {
+		return nil, err
+	}
+	snapshots, err := LoadSnapshots(c.Snapshots.File)
+	if err != nil {
+		return nil, err
+	}
+	tc := &TweetCache{Config: c, Tweets: c.inMemory(archive.Tweets), archive: archive, snapshots: snapshots}
+	if c.ReadState.Enabled {
+		if tc.readState, err = LoadReadState(c.ReadState.File); err != nil {
+			return nil, err
+		}
+	}
+	if c.ReaderBookmarks.Enabled {
+		if tc.readerBookmarks, err = LoadReaderBookmarks(c.ReaderBookmarks.File); err != nil {
+			return nil, err
+		}
+	}
+	if c.Comments.Enabled {
+		if tc.comments, err = LoadComments(c.Comments.File); err != nil {
+			return nil, err
+		}
+	}
+	tc.subscribe()
+	until, _ := c.announcementUntil()
+	tc.announcement.Set(c.Announcement.Text, until)
+	tc.expireAnnouncement(until)
+	tc.setMaintenance(c.Maintenance.Enabled)
+	if tc.source, err = tc.newSource(); err != nil {
+		return nil, err
+	}
+	if c.Redis.Addr != "" {
+		tc.redis = NewRedis(c)
+		tc.quota.shared = tc.redis
+		tc.source = tc.shareSource(tc.source)
+	}
+	return tc, nil
+}
+
+func (tc *TweetCache) Generation() uint64 {
+	return atomic.LoadUint64(&tc.generation)
+}
+
+var errStopped = errors.New("stopped")
+
+// Stop ends the background work that writes state files, waiting for
+// writes under way, so the process taking over after an upgrade has them
+// to itself.
+func (tc *TweetCache) Stop() {
+	tc.stopMu.Lock()
+	defer tc.stopMu.Unlock()
+	tc.stopped = true
+}
+
+func (tc *TweetCache) isStopped() bool {
+	tc.stopMu.RLock()
+	defer tc.stopMu.RUnlock()
+	return tc.stopped
+}
+
+// running calls write unless the cache has been stopped, reporting which.
+func (tc *TweetCache) running(write func()) bool {
+	tc.stopMu.RLock()
+	defer tc.stopMu.RUnlock()
+	if tc.stopped {
+		return false
+	}
+	write()
+	return true
 }
 
 func (tc *TweetCache) Refresher() {
 	for {
-		if time.Since(tc.LastRefresh) < time.Minute*15 {
+		if wait := time.Until(tc.LastRefresh.Add(tc.liveConfig().refreshInterval())); wait > 0 {
+			time.Sleep(wait)
+			continue
+		}
+		if tc.Config.isQuiet(time.Now()) {
+			time.Sleep(time.Minute)
+			continue
+		}
+		if wait := tc.quotaWait(); wait > 0 {
+			time.Sleep(wait)
 			continue
 		}
 
-		tweets, err := tc.getTweets()
-
-		if err != nil {
+		if err := tc.Refresh(); err == errStopped {
+			return
+		} else if err != nil {
 			time.Sleep(time.Minute * 5)
-		} else if len(tweets) < len(tc.Tweets) {
-			continue
+		}
+	}
+}
+
+type refreshCall struct {
+	done chan struct{}
+	err  error
+}
+
+// Refresh fetches new tweets. Callers arriving while a refresh is already
+// running wait for it and share its result instead of hitting the API again.
+func (tc *TweetCache) Refresh() error {
+	tc.refreshMu.Lock()
+	if call := tc.refreshing; call != nil {
+		tc.refreshMu.Unlock()
+		<-call.done
+		return call.err
+	}
+	call := &refreshCall{done: make(chan struct{})}
+	tc.refreshing = call
+	tc.refreshMu.Unlock()
+
+	call.err = tc.refresh()
+
+	tc.refreshMu.Lock()
+	tc.refreshing = nil
+	tc.refreshMu.Unlock()
+	close(call.done)
+	return call.err
+}
+
+func (tc *TweetCache) refresh() error {
+	if tc.isStopped() {
+		return errStopped
+	}
+	tweets, err := tc.currentSource().Fetch()
+	if err != nil {
+		tc.LastError = err.Error()
+		fmt.Println("fetching posts failed:", err)
+		tc.events.Publish(Event{Kind: EventFetchFailed, Err: err})
+		return err
+	}
+	tc.LastError = ""
+	changes, latest := diffFetch(tc.archive.Tweets, tc.lastFetch, tweets, tc.sourceName)
+	tc.changes, tc.lastFetch = &changes, latest
+
+	// Pages stay cached through refreshes that bring nothing new.
+	changed := false
+	if !tc.running(func() { changed = tc.merge(tweets) }) {
+		return errStopped
+	}
+	if tc.liveConfig().usesTwitter() {
+		pinned := tc.getPinned()
+		changed = changed || pinnedID(pinned) != pinnedID(tc.Pinned)
+		tc.Pinned = pinned
+	}
+	if tc.Config.Bookmarks.Enabled {
+		if bookmarks, err := tc.fetchBookmarks(); err != nil {
+			fmt.Println("fetching bookmarks failed:", err)
 		} else {
-			tc.Tweets = tweets
-			tc.LastRefresh = time.Now()
+			changed = changed || !sameV2Tweets(bookmarks, tc.Bookmarks)
+			tc.Bookmarks = bookmarks
 		}
 	}
+	tc.LastRefresh = time.Now()
+	tc.events.Publish(Event{Kind: EventRefreshed, Changed: changed})
+	return nil
+}
+
+// merge adds fetched or pushed tweets to the archive and announces the
+// new ones. Callers bump the generation once they're done updating, if
+// merge reports a change.
+func (tc *TweetCache) merge(tweets []twitter.Tweet) bool {
+	tc.mergeMu.Lock()
+	defer tc.mergeMu.Unlock()
+
+	// The first fetch only fills the archive, nothing in it is new.
+	if len(tc.archive.Tweets) > 0 {
+		if fresh := tc.claimNew(newTweets(tc.archive.Tweets, tweets)); len(fresh) > 0 {
+			tc.events.Publish(Event{Kind: EventNewTweets, Tweets: fresh})
+		}
+	}
+	changed := tc.archive.Merge(tweets)
+	if err := tc.archive.Save(tc.Config.Cache.ArchiveFile); err != nil {
+		fmt.Println(err)
+	}
+	inMemory := tc.Config.inMemory(tc.archive.Tweets)
+	changed = changed || len(inMemory) != len(tc.Tweets)
+	tc.Tweets = inMemory
+	return changed
+}
+
+// remove takes deleted tweets out of the archive and the in-memory cache,
+// reporting whether any were there.
+func (tc *TweetCache) remove(ids []int64) bool {
+	tc.mergeMu.Lock()
+	defer tc.mergeMu.Unlock()
+
+	if !tc.archive.Remove(ids) {
+		return false
+	}
+	if err := tc.archive.Save(tc.Config.Cache.ArchiveFile); err != nil {
+		fmt.Println(err)
+	}
+	tc.Tweets = tc.Config.inMemory(tc.archive.Tweets)
+	return true
 }
 
 func (tc *TweetCache) GetOnPosition(pos int) (string, error) {
 	if len(tc.Tweets) == 0 || len(tc.Tweets)-1 < pos {
 		return "", errors.New("twit not available")
 	}
-	tweet := tc.Tweets[pos]
-	return tweet.Text + "\n\n" + tweet.User.Name, nil
+	return tc.Format(tc.Tweets[pos]), nil
 }
 
-func (tc *TweetCache) getTweets() ([]twitter.Tweet, error) {
-	config := oauth1.NewConfig(tc.Config.Twitter.ConsumerKey, tc.Config.Twitter.ConsumerSecret)
-	token := oauth1.NewToken(tc.Config.Twitter.AccessToken, tc.Config.Twitter.AccessSecret)
-	httpClient := config.Client(oauth1.NoContext, token)
-	client := twitter.NewClient(httpClient)
+func (tc *TweetCache) Format(tweet twitter.Tweet) string {
+	text := tc.sourceBadge(tweet) + tc.Config.rewriteLinks(expandLinks(tweet))
+	if tweet.RetweetedStatus != nil && tc.archive != nil {
+		if n := len(tc.archive.Retweets[tweet.RetweetedStatus.ID]); n > 1 {
+			text += fmt.Sprintf("\n\n(retweeted %d times)", n)
+		}
+	}
+	// Link lines go after wrapping, which would break them.
+	return tc.finishFormat(tweet, text) + tc.formatMedia(tweet)
+}
 
-	t := true
-	tweets, _, err := client.Timelines.UserTimeline(&twitter.UserTimelineParams{
+// finishFormat adds the byline, which the headings layout puts in the
+// heading instead, and applies the text settings.
+func (tc *TweetCache) finishFormat(tweet twitter.Tweet, text string) string {
+	if tc.Config.UI.Layout == "classic" {
+		text += "\n\n" + tweet.User.Name + tc.sourceVia(tweet)
+	}
+	text = tc.Config.convertEmoji(text)
+	return tc.Config.isolateBidi(wrapText(text, tc.Config.UI.Wrap))
+}
+
+func (tc *TweetCache) httpClient() *http.Client {
+	app := tc.currentApp()
+	config := oauth1.NewConfig(app.ConsumerKey, app.ConsumerSecret)
+	token := oauth1.NewToken(app.AccessToken, app.AccessSecret)
+	client := config.Client(oauth1.NoContext, token)
+	client.Transport = tc.twitterTransport(client.Transport)
+	return client
+}
+
+func (tc *TweetCache) getTweets() ([]twitter.Tweet, *http.Response, error) {
+	client := twitter.NewClient(tc.httpClient())
+
+	f := false
+	tweets, resp, err := client.Timelines.UserTimeline(&twitter.UserTimelineParams{
 		UserID:         tc.Config.Twitter.UserID,
 		ScreenName:     tc.Config.Twitter.ScreenName,
 		Count:          100,
-		ExcludeReplies: &t,
+		ExcludeReplies: &f,
 	})
 	if err != nil {
-		return nil, err
+		return nil, resp, err
 	}
-	return tweets, nil
+	return dropRepliesToOthers(tweets), resp, nil
 }
 
 type RequestHandler struct {
 	TweetCache *TweetCache
 	Config
+
+	logoOnce    sync.Once
+	logo        string
+	altLogo     string
+	fingerprint string
+	pages       PageCache
+	replies     RepliesCache
+	proxy       ProxyCache
+	mediaBudget MediaBudget
+	thumbnails  ThumbnailCache
+	search      SearchIndex
+	stamps      PageStamps
+	bundles     BundleCache
+	metrics     Metrics
+	router      Router
+	// Comments left by each certificate
+	commentLimit *RateLimiter
+	// Renders ?experimental=1 pages
+	experimental *RequestHandler
+	// Render the pages of sources with a look of their own
+	sources SourceHandlers
 }
 
 func (rh *RequestHandler) getFooter() string {
-	return `
+	footer := fmt.Sprintf(`
 
-=> https://github.com/vegasq/gemini-twitter-mirror Fork me on GitHub
-`
+=> /index-all %s
+=> /server-info %s
+=> https://github.com/vegasq/gemini-twitter-mirror %s
+`, rh.Config.label("allTweets"), rh.Config.label("serverInfo"), rh.Config.label("fork"))
+	if rh.Config.UI.ShowVersion {
+		footer += "donaldgem " + versionString() + "\n"
+	}
+	return footer
 }
 
 func (rh *RequestHandler) getHeader() string {
-	var logo string
-	fl, err := os.Open(rh.Config.UI.AsciiLogoFile)
-	if err == os.ErrNotExist {
-		logo = ""
-	} else {
-		b := strings.Builder{}
-		io.Copy(&b, fl)
-		logo = b.String()
-	}
 	return fmt.Sprintf(`%s
 
-=> / Last tweet
-=> /timeline Timeline
-=> /select_tweet Tweet selector
+=> / %s
+%s
+=> %s %s
+=> /tags %s
+=> /search %s
+=> /stats %s
 
-`, logo)
+`, rh.getLogo(), rh.Config.label("home"), rh.timelineLink(), rh.selectorLink(), rh.Config.label("selector"),
+		rh.Config.label("tags"), rh.Config.label("search"), rh.Config.label("stats"))
 }
 
-func (rh *RequestHandler) formatTimeline() string {
-	var timeline string
-	for i := 0; i < 10; i += 1 {
+// formatTimeline marks the tweets after seen as unread, unless seen is 0.
+func (rh *RequestHandler) formatTimeline(dr DateRange, langs Languages, order string, seen int64) string {
+	var timeline strings.Builder
+	if !dr.IsZero() {
+		fmt.Fprintf(&timeline, "## Tweets %s\n=> /timeline/dates Other dates", dr)
+	} else {
+		timeline.WriteString(rh.formatPinned())
+	}
+	unread := map[int]bool{}
+	if seen != 0 {
+		for _, i := range rh.TweetCache.newSince(seen) {
+			unread[i] = true
+		}
+	}
+	if len(unread) > 0 {
+		fmt.Fprintf(&timeline, "\n\n%s since your last visit\n=> /new?since=%d All of them", countNoun(len(unread), "unread tweet"), seen)
+	}
+	if len(langs) > 0 {
+		fmt.Fprintf(&timeline, "\n\nShowing tweets in: %s\n=> /timeline?lang=all All languages", langs)
+	}
+	shown := 0
+	for n := range rh.TweetCache.Tweets {
+		if shown == 10 {
+			break
+		}
+		i := n
+		if order == "asc" {
+			i = len(rh.TweetCache.Tweets) - 1 - n
+		}
+		tweet := rh.TweetCache.Tweets[i]
+		if !dr.Contains(tweet) || !langs.Contains(tweet) {
+			continue
+		}
 		tw, err := rh.TweetCache.GetOnPosition(i)
 		if err != nil {
 			continue
 		}
 
-		timeline += fmt.Sprintf("\n\n%s\n\n%s", tw, rh.Config.UI.Delimiter)
+		shown += 1
+		if unread[i] {
+			tw = rh.Config.glyph("🆕 ", "Unread: ") + tw
+		}
+		timeline.WriteString(rh.formatEntry(tweet, tw))
+	}
+	if shown == 0 && !dr.IsZero() {
+		timeline.WriteString("\n\nNo tweets in this period.")
+	} else if shown == 0 && len(langs) > 0 {
+		timeline.WriteString("\n\nNo tweets in these languages.")
 	}
-	return timeline
+	return timeline.String()
 }
 
 func (rh *RequestHandler) formatTweet(pos int) string {
-	tw, err := rh.TweetCache.GetOnPosition(pos)
-	if err != nil {
+	if pos < 0 || pos >= len(rh.TweetCache.Tweets) {
 		return ""
 	}
+	return rh.formatSingle(rh.TweetCache.Tweets[pos])
+}
+
+// formatSingle is a tweet on a page of its own.
+func (rh *RequestHandler) formatSingle(tweet twitter.Tweet) string {
+	tw := rh.TweetCache.Format(tweet)
+	if rh.Config.UI.Layout == "headings" {
+		return fmt.Sprintf("\n\n## %s\n\n%s", rh.tweetHeading(tweet), tw)
+	}
 	return fmt.Sprintf("\n\n%s", tw)
 }
 
 func (rh *RequestHandler) wrapBody(body string) string {
-	return fmt.Sprintf("%s%s%s", rh.getHeader(), body, rh.getFooter())
+	return fmt.Sprintf("%s%s%s%s", rh.getHeader(), rh.formatAnnouncement(), body, rh.getFooter())
 }
 
 func (rh *RequestHandler) showTweet(offset int) *gemini.Response {
 	body := ioutil.NopCloser(bytes.NewBufferString(rh.wrapBody(rh.formatTweet(offset))))
-	return &gemini.Response{20, "text/gemini", body, nil}
+	if offset >= 0 && offset < len(rh.TweetCache.Tweets) {
+		return Success(rh.tweetMeta(rh.TweetCache.Tweets[offset]), body)
+	}
+	return Success(rh.meta(), body)
 }
 
-func (rh *RequestHandler) showTimeline() *gemini.Response {
-	body := ioutil.NopCloser(bytes.NewBufferString(rh.wrapBody(rh.formatTimeline())))
-	return &gemini.Response{20, "text/gemini", body, nil}
+func (rh *RequestHandler) showFrontPage() *gemini.Response {
+	body := ioutil.NopCloser(bytes.NewBufferString(rh.wrapBody(rh.formatFrontPage())))
+	return Success(rh.meta(), body)
 }
 
-func getFirstKeyFromURL(u url.URL) string {
-	params := u.Query()
-	for k := range params {
-		return k
-	}
-	return ""
+func (rh *RequestHandler) showTimeline(dr DateRange, langs Languages, order string, seen int64) *gemini.Response {
+	body := ioutil.NopCloser(bytes.NewBufferString(rh.wrapBody(rh.formatTimeline(dr, langs, order, seen))))
+	return Success(rh.meta(), body)
 }
 
-func (rh *RequestHandler) Handle(r gemini.Request) *gemini.Response {
-	params := r.URL.Query()
-	if r.URL.Path == "/" {
-		return rh.showTweet(0)
-	} else if r.URL.Path == "/timeline" {
-		return rh.showTimeline()
-	} else if r.URL.Path == "/select_tweet" && len(params) == 0 {
-		return &gemini.Response{10, "Get tweet offset. f.e. 5", nil, nil}
-	} else if r.URL.Path == "/select_tweet" {
-		offset, err := strconv.Atoi(getFirstKeyFromURL(*r.URL))
-		if err != nil {
-			return &gemini.Response{42, "Failed to parse input. Please use numbers.", nil, nil}
-		}
-		return rh.showTweet(offset)
+func (rh *RequestHandler) showStats() *gemini.Response {
+	body := ioutil.NopCloser(bytes.NewBufferString(rh.wrapBody(rh.formatStats())))
+	return Success(rh.meta(), body)
+}
+
+func (rh *RequestHandler) showPermalink(id int64) *gemini.Response {
+	tweet, ok := rh.TweetCache.lookup(id)
+	if !ok {
+		return NotFound("Tweet not found")
 	}
-	return &gemini.Response{51, "Unknown location", nil, nil}
+	body := ioutil.NopCloser(bytes.NewBufferString(rh.wrapBody(rh.formatPermalink(tweet))))
+	return Success(rh.tweetMeta(tweet), body)
+}
+
+func (rh *RequestHandler) showTags() *gemini.Response {
+	body := ioutil.NopCloser(bytes.NewBufferString(rh.wrapBody(rh.formatTags())))
+	return Success(rh.meta(), body)
+}
+
+func (rh *RequestHandler) showSearch(query string, dr DateRange) *gemini.Response {
+	body := ioutil.NopCloser(bytes.NewBufferString(rh.wrapBody(rh.formatSearch(query, dr))))
+	return Success(rh.meta(), body)
+}
+
+// readInput is the answer to an input prompt: the whole query, decoded.
+// It isn't key=value pairs, so "=" and "&" are part of it, and "+" stays
+// a plus.
+func readInput(u url.URL) (string, error) {
+	return url.PathUnescape(u.RawQuery)
+}
+
+var commands = map[string]func(args []string) error{
+	"auth":    runAuth,
+	"backup":  runBackup,
+	"fsck":    runFsck,
+	"restore": runRestore,
+	"version": runVersion,
 }
 
 func main() {
-	var path string
+	if len(os.Args) > 1 {
+		if command, ok := commands[os.Args[1]]; ok {
+			if err := command(os.Args[2:]); err != nil {
+				exit(err)
+			}
+			return
+		}
+	}
+
+	var path, pidFile string
+	var dev bool
 	flag.StringVar(&path, "config", "config.yml", "Location of config file")
+	flag.StringVar(&pidFile, "pidfile", "", "Write the process ID to this file")
+	flag.BoolVar(&dev, "dev", false, "Re-read the config, theme and logo on every request")
 	flag.Parse()
 
 	c := Config{}
-	c.Parse(path)
+	if err := c.Parse(path); err != nil {
+		exit(err)
+	}
+
+	tc, err := NewTweetCache(c)
+	if err != nil {
+		exit(err)
+	}
 
-	tc := TweetCache{Config: c}
+	upgrader := NewUpgrader()
+	upgrader.BeforeDrain = tc.Stop
+	if pidFile != "" {
+		if err := writePidFile(pidFile); err != nil {
+			exit(err)
+		}
+		upgrader.OnStop = func() { os.Remove(pidFile) }
+	}
+	if c.ActivityPub.Enabled {
+		if tc.activityPub, err = NewActivityPub(c, tc); err != nil {
+			exit(err)
+		}
+	}
+	rh := NewRequestHandler(tc, c)
+	c.printBanner(rh.fingerprint)
+	if c.HTTP.Listen != "" {
+		if ln, err := upgrader.Listen("http", c.HTTP.Listen); err != nil {
+			fmt.Println(err)
+		} else {
+			go func() {
+				if err := ServeHTTP(ln, rh.httpHandler()); err != nil && !upgrader.Stopping() {
+					fmt.Println(err)
+				}
+			}()
+		}
+	}
+
+	if c.usesTwitter() {
+		tc.checkCredentials()
+	}
 	go tc.Refresher()
+	if c.Snapshots.Enabled {
+		go tc.SnapshotTaker()
+	}
+	if c.Cache.ArchiveFile != "" {
+		go tc.ArchiveChecker()
+	}
+	if c.Metrics.PushgatewayURL != "" || c.Metrics.Statsd != "" {
+		go rh.MetricsPusher()
+	}
+	if c.Publish.URL != "" {
+		publisher, err := NewPublisher(tc, c)
+		if err != nil {
+			exit(err)
+		}
+		if c.Publish.Only {
+			// Never returns
+			publisher.Run()
+		}
+		go publisher.Run()
+	}
 
-	err := gemini.ListenAndServe(
-		fmt.Sprintf("%s:%d", c.Addr.Host, c.Addr.Port),
-		c.Cert.CertFile,
-		c.Cert.KeyFile,
-		&RequestHandler{&tc, c},
-	)
+	var handler Handler = rh
+	if dev {
+		handler = devHandler{path, tc}
+	}
+	cert, err := loadCertificate(c.Cert.CertFile, c.Cert.KeyFile)
+	var ln net.Listener
+	if err == nil {
+		ln, err = upgrader.Listen("gemini", c.listenAddr())
+	}
+	if err == nil {
+		upgrader.Ready()
+		go upgrader.HandleSignals()
+		err = ServeTLS(ln, cert, handler)
+	}
+	if upgrader.Stopping() {
+		// HandleSignals exits once the connections are drained.
+		select {}
+	}
 	if err != nil {
-		fmt.Println(err)
+		if pidFile != "" {
+			os.Remove(pidFile)
+		}
+		exit(err)
 	}
 }
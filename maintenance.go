@@ -0,0 +1,46 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"sync/atomic"
+
+	"github.com/makeworld-the-better-one/go-gemini"
+)
+
+func (tc *TweetCache) inMaintenance() bool {
+	return atomic.LoadUint32(&tc.maintenance) == 1
+}
+
+func (tc *TweetCache) setMaintenance(on bool) {
+	var v uint32
+	if on {
+		v = 1
+	}
+	atomic.StoreUint32(&tc.maintenance, v)
+}
+
+// maintenanceMessage says the mirror is paused and when to come back. A
+// 41 has no body, so this line is all the reader sees.
+func (c Config) maintenanceMessage() string {
+	if c.Maintenance.RetryMinutes > 0 {
+		return fmt.Sprintf("%s, try again in about %s", c.Maintenance.Message, countNoun(c.Maintenance.RetryMinutes, "minute"))
+	}
+	return c.Maintenance.Message + ", try again later"
+}
+
+// pauseForMaintenance answers everything but /admin with 41 while the
+// mirror is paused, so the operator can still resume it.
+func (rh *RequestHandler) pauseForMaintenance(next Handler) Handler {
+	return Func(func(r Request) *gemini.Response {
+		if rh.TweetCache.inMaintenance() && !strings.HasPrefix(r.URL.Path, "/admin") {
+			return Unavailable(rh.Config.maintenanceMessage())
+		}
+		return next.Handle(r)
+	})
+}
+
+func (rh *RequestHandler) toggleMaintenance() *gemini.Response {
+	rh.TweetCache.setMaintenance(!rh.TweetCache.inMaintenance())
+	return Redirect("/admin")
+}
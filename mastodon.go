@@ -0,0 +1,135 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dghubble/go-twitter/twitter"
+)
+
+type mastodonAccount struct {
+	ID          string `json:"id"`
+	Username    string `json:"username"`
+	Acct        string `json:"acct"`
+	DisplayName string `json:"display_name"`
+}
+
+type mastodonStatus struct {
+	ID                 string                  `json:"id"`
+	CreatedAt          time.Time               `json:"created_at"`
+	Content            string                  `json:"content"`
+	SpoilerText        string                  `json:"spoiler_text"`
+	Language           string                  `json:"language"`
+	InReplyToID        string                  `json:"in_reply_to_id"`
+	InReplyToAccountID string                  `json:"in_reply_to_account_id"`
+	Account            mastodonAccount         `json:"account"`
+	Reblog             *mastodonStatus         `json:"reblog"`
+	Tags               []struct{ Name string } `json:"tags"`
+}
+
+// splitAccount parses user@instance, with or without a leading @.
+func splitAccount(account string) (user, instance string, ok bool) {
+	parts := strings.Split(strings.TrimPrefix(account, "@"), "@")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", false
+	}
+	return parts[0], parts[1], true
+}
+
+// mastodonSource mirrors a Mastodon account through the public API, which
+// needs no token for public posts.
+type mastodonSource struct {
+	Config
+}
+
+func (s mastodonSource) Name() string {
+	return "Mastodon"
+}
+
+func (s mastodonSource) URL(tweet twitter.Tweet) string {
+	user, instance, _ := splitAccount(s.Mastodon.Account)
+	id := tweet.IDStr
+	if id == "" {
+		id = strconv.FormatInt(tweet.ID, 10)
+	}
+	return fmt.Sprintf("https://%s/@%s/%s", instance, user, id)
+}
+
+func (s mastodonSource) getJSON(client *http.Client, u string, v interface{}) error {
+	resp, err := client.Get(u)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("mastodon: %s returned %s", u, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+func (s mastodonSource) Fetch() ([]twitter.Tweet, error) {
+	user, instance, ok := splitAccount(s.Mastodon.Account)
+	if !ok {
+		return nil, fmt.Errorf("mastodon: bad account %q", s.Mastodon.Account)
+	}
+	client := &http.Client{Timeout: time.Minute, Transport: s.Config.outbound(nil)}
+	base := "https://" + instance + "/api/v1/accounts/"
+
+	var account mastodonAccount
+	if err := s.getJSON(client, base+"lookup?acct="+url.QueryEscape(user), &account); err != nil {
+		return nil, err
+	}
+	var statuses []mastodonStatus
+	if err := s.getJSON(client, base+url.PathEscape(account.ID)+"/statuses?limit=40", &statuses); err != nil {
+		return nil, err
+	}
+	return convertMastodonStatuses(statuses), nil
+}
+
+func convertMastodonStatus(status mastodonStatus) twitter.Tweet {
+	id, _ := strconv.ParseInt(status.ID, 10, 64)
+	userID, _ := strconv.ParseInt(status.Account.ID, 10, 64)
+	name := status.Account.DisplayName
+	if name == "" {
+		name = status.Account.Username
+	}
+	tweet := twitter.Tweet{
+		ID:        id,
+		IDStr:     status.ID,
+		CreatedAt: status.CreatedAt.Format(time.RubyDate),
+		Lang:      status.Language,
+		User:      &twitter.User{ID: userID, Name: name, ScreenName: status.Account.Acct},
+		Entities:  &twitter.Entities{},
+	}
+	tweet.InReplyToStatusID, _ = strconv.ParseInt(status.InReplyToID, 10, 64)
+	tweet.InReplyToUserID, _ = strconv.ParseInt(status.InReplyToAccountID, 10, 64)
+	text := htmlToText(status.Content)
+	if status.SpoilerText != "" {
+		text = "CW: " + status.SpoilerText + "\n\n" + text
+	}
+	if status.Reblog != nil {
+		reblog := convertMastodonStatus(*status.Reblog)
+		tweet.RetweetedStatus = &reblog
+		text = fmt.Sprintf("RT @%s: %s", reblog.User.ScreenName, reblog.Text)
+	}
+	tweet.Text, tweet.FullText = text, text
+	for _, tag := range status.Tags {
+		tweet.Entities.Hashtags = append(tweet.Entities.Hashtags, twitter.HashtagEntity{Text: tag.Name})
+	}
+	return tweet
+}
+
+func convertMastodonStatuses(statuses []mastodonStatus) []twitter.Tweet {
+	var tweets []twitter.Tweet
+	for _, status := range statuses {
+		tweets = append(tweets, convertMastodonStatus(status))
+	}
+	tweets = dropRepliesToOthers(tweets)
+	sortNewestFirst(tweets)
+	return tweets
+}
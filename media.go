@@ -0,0 +1,249 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dghubble/go-twitter/twitter"
+	"github.com/makeworld-the-better-one/go-gemini"
+)
+
+// tweetMedia returns the photos, videos and GIFs attached to a tweet.
+func tweetMedia(tweet twitter.Tweet) []twitter.MediaEntity {
+	if tweet.ExtendedEntities != nil {
+		return tweet.ExtendedEntities.Media
+	}
+	if tweet.Entities != nil {
+		return tweet.Entities.Media
+	}
+	return nil
+}
+
+// mediaURL is where the media itself lives: the image, or for videos and
+// GIFs the best MP4.
+func mediaURL(media twitter.MediaEntity) string {
+	best, bitrate := "", -1
+	for _, v := range media.VideoInfo.Variants {
+		if v.ContentType == "video/mp4" && v.Bitrate > bitrate {
+			best, bitrate = v.URL, v.Bitrate
+		}
+	}
+	if best != "" {
+		return best
+	}
+	return media.MediaURLHttps
+}
+
+func mediaLabel(media twitter.MediaEntity, n int) string {
+	switch media.Type {
+	case "video":
+		return "Video"
+	case "animated_gif":
+		return "GIF"
+	}
+	return fmt.Sprintf("Image %d", n)
+}
+
+// formatMedia links a tweet's media, through /media/ when proxying, with
+// a thumbnail to preview each first.
+func (tc *TweetCache) formatMedia(tweet twitter.Tweet) string {
+	if tc.Config.Media.Mode == "off" {
+		return ""
+	}
+	links := ""
+	for i, media := range tweetMedia(tweet) {
+		label := mediaLabel(media, i+1)
+		if tc.Config.Media.Mode == "proxy" {
+			links += fmt.Sprintf("\n=> /media/%d/thumb %s, preview\n=> /media/%d/full %s", media.ID, label, media.ID, label)
+		} else if link := mediaURL(media); link != "" {
+			links += fmt.Sprintf("\n=> %s %s", link, label)
+		}
+	}
+	return links
+}
+
+func findMedia(tweets []twitter.Tweet, id int64) (twitter.MediaEntity, bool) {
+	for _, tweet := range tweets {
+		for _, media := range tweetMedia(tweet) {
+			if media.ID == id {
+				return media, true
+			}
+		}
+	}
+	return twitter.MediaEntity{}, false
+}
+
+// findMedia looks for media linked from any page: those of tweets in
+// memory, archived and on the accounts /u/ mirrors.
+func (rh *RequestHandler) findMedia(id int64) (twitter.MediaEntity, bool) {
+	if media, ok := findMedia(rh.TweetCache.Tweets, id); ok {
+		return media, true
+	}
+	if media, ok := findMedia(rh.TweetCache.archived(), id); ok {
+		return media, true
+	}
+	return rh.proxy.findMedia(id)
+}
+
+// MediaBudget counts the bytes of media served each UTC day.
+type MediaBudget struct {
+	mu   sync.Mutex
+	day  string
+	used int64
+}
+
+// Spend takes n bytes out of today's budget, if they fit.
+func (mb *MediaBudget) Spend(n, limit int64) bool {
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
+	if day := time.Now().UTC().Format(dateLayout); day != mb.day {
+		mb.day, mb.used = day, 0
+	}
+	if limit > 0 && mb.used+n > limit {
+		return false
+	}
+	mb.used += n
+	return true
+}
+
+func (c *Config) mediaTypeAllowed(contentType string) bool {
+	mediaType := strings.TrimSpace(strings.SplitN(contentType, ";", 2)[0])
+	for _, t := range c.Media.Types {
+		if strings.EqualFold(t, mediaType) {
+			return true
+		}
+	}
+	return false
+}
+
+// fetchMedia downloads media within the configured size and types. ok is
+// false when the policy says to link out instead.
+func (c *Config) fetchMedia(link string) (body []byte, contentType string, ok bool, err error) {
+	client := &http.Client{Transport: c.outbound(nil), Timeout: hookTimeout}
+	resp, err := client.Get(link)
+	if err != nil {
+		return nil, "", false, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", false, fmt.Errorf("media: %s returned %s", link, resp.Status)
+	}
+	contentType = resp.Header.Get("Content-Type")
+	if !c.mediaTypeAllowed(contentType) || resp.ContentLength > c.Media.MaxBytes {
+		return nil, "", false, nil
+	}
+	body, err = ioutil.ReadAll(io.LimitReader(resp.Body, c.Media.MaxBytes+1))
+	if err != nil {
+		return nil, "", false, err
+	}
+	if int64(len(body)) > c.Media.MaxBytes {
+		return nil, "", false, nil
+	}
+	return body, contentType, true, nil
+}
+
+// Thumbnails kept in memory; they're small, and making one means
+// downloading the full image.
+const maxThumbnails = 500
+
+type thumbnail struct {
+	body        []byte
+	contentType string
+}
+
+type ThumbnailCache struct {
+	mu     sync.Mutex
+	thumbs map[int64]thumbnail
+}
+
+func (tc *ThumbnailCache) Get(id int64) (thumbnail, bool) {
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+	thumb, ok := tc.thumbs[id]
+	return thumb, ok
+}
+
+func (tc *ThumbnailCache) Put(id int64, thumb thumbnail) {
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+	if tc.thumbs == nil {
+		tc.thumbs = map[int64]thumbnail{}
+	}
+	// Any one will do to make room, they're cheap to make again.
+	for key := range tc.thumbs {
+		if len(tc.thumbs) < maxThumbnails {
+			break
+		}
+		delete(tc.thumbs, key)
+	}
+	tc.thumbs[id] = thumb
+}
+
+// twitterThumbnail is Twitter's own small version of an image, where
+// clients are sent when we can't make one.
+func twitterThumbnail(media twitter.MediaEntity) string {
+	return media.MediaURLHttps + "?name=thumb"
+}
+
+// proxyMedia serves link cleaned, or redirects to fallback when it's too
+// large, of a type not proxied, or over the day's budget. thumb shrinks
+// it to a thumbnail first.
+func (rh *RequestHandler) proxyMedia(link, fallback string, thumb bool) *gemini.Response {
+	body, contentType, ok, err := rh.Config.fetchMedia(link)
+	if err != nil {
+		fmt.Println(err)
+		return TempFailure("Could not fetch media")
+	}
+	if ok {
+		// What can't be cleaned isn't served, the original is still
+		// there for clients to fetch themselves.
+		clean := rh.Config.cleanMedia
+		if thumb {
+			clean = rh.Config.makeThumbnail
+		}
+		if body, contentType, err = clean(body, contentType); err != nil {
+			fmt.Println("cleaning media:", err)
+			ok = false
+		}
+	}
+	if !ok || !rh.mediaBudget.Spend(int64(len(body)), rh.Config.Media.DailyBytes) {
+		return Redirect(fallback)
+	}
+	return Success(contentType, ioutil.NopCloser(bytes.NewReader(body)))
+}
+
+func (rh *RequestHandler) showMedia(id int64) *gemini.Response {
+	media, ok := rh.findMedia(id)
+	if !ok {
+		return NotFound("Media not found")
+	}
+	return rh.proxyMedia(mediaURL(media), mediaURL(media), false)
+}
+
+// showThumbnail serves a small still of an image, or of a video's
+// poster frame.
+func (rh *RequestHandler) showThumbnail(id int64) *gemini.Response {
+	media, ok := rh.findMedia(id)
+	if !ok {
+		return NotFound("Media not found")
+	}
+	if thumb, ok := rh.thumbnails.Get(id); ok {
+		if !rh.mediaBudget.Spend(int64(len(thumb.body)), rh.Config.Media.DailyBytes) {
+			return Redirect(twitterThumbnail(media))
+		}
+		return Success(thumb.contentType, ioutil.NopCloser(bytes.NewReader(thumb.body)))
+	}
+	resp := rh.proxyMedia(media.MediaURLHttps, twitterThumbnail(media), true)
+	if resp.Status == 20 {
+		body, _ := ioutil.ReadAll(resp.Body)
+		rh.thumbnails.Put(id, thumbnail{body, resp.Meta})
+		resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+	}
+	return resp
+}
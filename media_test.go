@@ -0,0 +1,35 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/dghubble/go-twitter/twitter"
+)
+
+func TestFindMediaBeyondMemory(t *testing.T) {
+	var c Config
+	c.Cache.MaxTweets = 2
+	tweets := fixtureTweets(3)
+	tweets[2].ExtendedEntities = &twitter.ExtendedEntity{Media: []twitter.MediaEntity{{ID: 7, Type: "photo"}}}
+	h, err := NewHandler(c, Deps{Source: fixtureSource{tweets}, Tweets: tweets})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(h.TweetCache.Tweets) != 2 {
+		t.Fatalf("%d tweets in memory", len(h.TweetCache.Tweets))
+	}
+	if _, ok := h.findMedia(7); !ok {
+		t.Error("media of an archived tweet not found")
+	}
+
+	proxied := fixtureTweets(1)
+	proxied[0].ExtendedEntities = &twitter.ExtendedEntity{Media: []twitter.MediaEntity{{ID: 8, Type: "photo"}}}
+	h.proxy.Put("someone", proxied, time.Minute)
+	if _, ok := h.findMedia(8); !ok {
+		t.Error("media of a /u/ page not found")
+	}
+	if _, ok := h.findMedia(9); ok {
+		t.Error("found media that isn't anywhere")
+	}
+}
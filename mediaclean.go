@@ -0,0 +1,246 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"strings"
+)
+
+// JPEG quality of thumbnails, which are for a glance only.
+const thumbnailQuality = 70
+
+var errBadImage = errors.New("media: malformed image")
+
+// stripJPEG drops the APP1 (EXIF, XMP) and APP13 (IPTC) segments, where
+// cameras put location and device details, without touching the image
+// data.
+func stripJPEG(data []byte) ([]byte, error) {
+	if len(data) < 2 || data[0] != 0xFF || data[1] != 0xD8 {
+		return nil, errBadImage
+	}
+	out := []byte{0xFF, 0xD8}
+	for i := 2; ; {
+		if i+4 > len(data) || data[i] != 0xFF {
+			return nil, errBadImage
+		}
+		marker := data[i+1]
+		// Start of scan: the rest is image data.
+		if marker == 0xDA {
+			return append(out, data[i:]...), nil
+		}
+		length := int(binary.BigEndian.Uint16(data[i+2:]))
+		end := i + 2 + length
+		if length < 2 || end > len(data) {
+			return nil, errBadImage
+		}
+		if marker != 0xE1 && marker != 0xED {
+			out = append(out, data[i:end]...)
+		}
+		i = end
+	}
+}
+
+var pngSignature = []byte("\x89PNG\r\n\x1a\n")
+
+// stripPNG drops the chunks holding EXIF, text and timestamps.
+func stripPNG(data []byte) ([]byte, error) {
+	if !bytes.HasPrefix(data, pngSignature) {
+		return nil, errBadImage
+	}
+	out := append([]byte(nil), pngSignature...)
+	for i := len(pngSignature); i < len(data); {
+		if i+8 > len(data) {
+			return nil, errBadImage
+		}
+		length := int(binary.BigEndian.Uint32(data[i:]))
+		end := i + 12 + length
+		if length < 0 || end > len(data) {
+			return nil, errBadImage
+		}
+		switch string(data[i+4 : i+8]) {
+		case "eXIf", "tEXt", "zTXt", "iTXt", "tIME":
+		default:
+			out = append(out, data[i:end]...)
+		}
+		i = end
+	}
+	return out, nil
+}
+
+// stripWebP drops the EXIF and XMP chunks and the flags announcing them.
+func stripWebP(data []byte) ([]byte, error) {
+	if len(data) < 12 || string(data[:4]) != "RIFF" || string(data[8:12]) != "WEBP" {
+		return nil, errBadImage
+	}
+	out := append([]byte(nil), data[:12]...)
+	for i := 12; i < len(data); {
+		if i+8 > len(data) {
+			return nil, errBadImage
+		}
+		size := int(binary.LittleEndian.Uint32(data[i+4:]))
+		end := i + 8 + size + size%2
+		if end > len(data) {
+			return nil, errBadImage
+		}
+		switch string(data[i : i+4]) {
+		case "EXIF", "XMP ":
+		case "VP8X":
+			chunk := append([]byte(nil), data[i:end]...)
+			if len(chunk) > 8 {
+				chunk[8] &^= 0x08 | 0x04
+			}
+			out = append(out, chunk...)
+		default:
+			out = append(out, data[i:end]...)
+		}
+		i = end
+	}
+	binary.LittleEndian.PutUint32(out[4:], uint32(len(out)-8))
+	return out, nil
+}
+
+// reencodeGIF decodes and encodes a GIF again, keeping frames, timing and
+// looping but dropping comments and application data such as XMP.
+func reencodeGIF(data []byte) ([]byte, error) {
+	g, err := gif.DecodeAll(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := gif.EncodeAll(&buf, g); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// shrink scales img down to fit within max pixels on its longer side,
+// averaging the pixels each output pixel covers.
+func shrink(img image.Image, max int) image.Image {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if w <= max && h <= max {
+		return img
+	}
+	dw, dh := max, h*max/w
+	if h > w {
+		dw, dh = w*max/h, max
+	}
+	if dw < 1 {
+		dw = 1
+	}
+	if dh < 1 {
+		dh = 1
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < dh; y += 1 {
+		y0, y1 := b.Min.Y+y*h/dh, b.Min.Y+(y+1)*h/dh
+		for x := 0; x < dw; x += 1 {
+			x0, x1 := b.Min.X+x*w/dw, b.Min.X+(x+1)*w/dw
+			var r, g, bl, a, n uint64
+			for sy := y0; sy < y1; sy += 1 {
+				for sx := x0; sx < x1; sx += 1 {
+					cr, cg, cb, ca := img.At(sx, sy).RGBA()
+					r, g, bl, a, n = r+uint64(cr), g+uint64(cg), bl+uint64(cb), a+uint64(ca), n+1
+				}
+			}
+			dst.Set(x, y, color.RGBA64{uint16(r / n), uint16(g / n), uint16(bl / n), uint16(a / n)})
+		}
+	}
+	return dst
+}
+
+// cleanMedia strips metadata from proxied images, and shrinks and
+// re-encodes them as media.maxDimension and media.quality ask. Other
+// media pass through as they are.
+func (c *Config) cleanMedia(data []byte, contentType string) ([]byte, string, error) {
+	mediaType := strings.ToLower(strings.TrimSpace(strings.SplitN(contentType, ";", 2)[0]))
+	maxDimension, quality := c.Media.MaxDimension, c.Media.Quality
+
+	tooLarge := func() bool {
+		if maxDimension <= 0 {
+			return false
+		}
+		config, _, err := image.DecodeConfig(bytes.NewReader(data))
+		return err == nil && (config.Width > maxDimension || config.Height > maxDimension)
+	}
+	decodeShrunk := func() (image.Image, error) {
+		img, _, err := image.Decode(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		if maxDimension > 0 {
+			img = shrink(img, maxDimension)
+		}
+		return img, nil
+	}
+
+	var buf bytes.Buffer
+	switch mediaType {
+	case "image/jpeg":
+		if !tooLarge() && quality <= 0 {
+			data, err := stripJPEG(data)
+			return data, mediaType, err
+		}
+		img, err := decodeShrunk()
+		if err != nil {
+			return nil, "", err
+		}
+		if quality <= 0 {
+			quality = jpeg.DefaultQuality
+		}
+		err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: quality})
+		return buf.Bytes(), mediaType, err
+	case "image/png":
+		if !tooLarge() {
+			data, err := stripPNG(data)
+			return data, mediaType, err
+		}
+		img, err := decodeShrunk()
+		if err != nil {
+			return nil, "", err
+		}
+		err = png.Encode(&buf, img)
+		return buf.Bytes(), mediaType, err
+	case "image/gif":
+		g, err := gif.DecodeAll(bytes.NewReader(data))
+		if err != nil {
+			return nil, "", err
+		}
+		// A still GIF that's too large is shrunk into a PNG, which
+		// doesn't need a palette.
+		if len(g.Image) == 1 && tooLarge() {
+			err = png.Encode(&buf, shrink(g.Image[0], maxDimension))
+			return buf.Bytes(), "image/png", err
+		}
+		data, err := reencodeGIF(data)
+		return data, mediaType, err
+	case "image/webp":
+		data, err := stripWebP(data)
+		return data, mediaType, err
+	}
+	return data, contentType, nil
+}
+
+// makeThumbnail shrinks an image, or a GIF's first frame, to
+// media.thumbnailSize. Decoding leaves the metadata behind.
+func (c *Config) makeThumbnail(data []byte, contentType string) ([]byte, string, error) {
+	img, format, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", err
+	}
+	img = shrink(img, c.Media.ThumbnailSize)
+	var buf bytes.Buffer
+	// PNG and GIF keep their transparency.
+	if format == "png" || format == "gif" {
+		err = png.Encode(&buf, img)
+		return buf.Bytes(), "image/png", err
+	}
+	err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: thumbnailQuality})
+	return buf.Bytes(), "image/jpeg", err
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/makeworld-the-better-one/go-gemini"
+)
+
+type routeMetrics struct {
+	Requests int
+	Total    time.Duration
+	Max      time.Duration
+}
+
+// Metrics counts requests and render times per route, and how fresh the
+// served pages were, for the admin page.
+type Metrics struct {
+	mu          sync.Mutex
+	routes      map[string]*routeMetrics
+	cacheHits   int
+	cacheMisses int
+	ageTotal    time.Duration
+	aged        int
+}
+
+func (m *Metrics) observe(route string, took time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.routes == nil {
+		m.routes = map[string]*routeMetrics{}
+	}
+	rm, ok := m.routes[route]
+	if !ok {
+		rm = &routeMetrics{}
+		m.routes[route] = rm
+	}
+	rm.Requests += 1
+	rm.Total += took
+	if took > rm.Max {
+		rm.Max = took
+	}
+}
+
+func (m *Metrics) observeCache(hit bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if hit {
+		m.cacheHits += 1
+	} else {
+		m.cacheMisses += 1
+	}
+}
+
+func (m *Metrics) observeAge(age time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.ageTotal += age
+	m.aged += 1
+}
+
+func (rh *RequestHandler) recordMetrics(next Handler) Handler {
+	return Func(func(r Request) *gemini.Response {
+		start := time.Now()
+		resp := next.Handle(r)
+		route := rh.router.Pattern(r.URL.Path)
+		if route == "" {
+			route = "(unknown)"
+		}
+		rh.metrics.observe(route, time.Since(start))
+		if resp.Status == 20 && !rh.TweetCache.LastRefresh.IsZero() {
+			rh.metrics.observeAge(time.Since(rh.TweetCache.LastRefresh))
+		}
+		return resp
+	})
+}
+
+func (m *Metrics) format() string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	page := "\n## Requests\n\n"
+	if len(m.routes) == 0 {
+		return page + "None yet.\n"
+	}
+	var routes []string
+	for route := range m.routes {
+		routes = append(routes, route)
+	}
+	sort.Strings(routes)
+	page += "```\n"
+	page += fmt.Sprintf("%-24s %8s %10s %10s\n", "route", "requests", "avg", "max")
+	for _, route := range routes {
+		rm := m.routes[route]
+		avg := rm.Total / time.Duration(rm.Requests)
+		page += fmt.Sprintf("%-24s %8d %10s %10s\n", route, rm.Requests, avg.Round(time.Microsecond), rm.Max.Round(time.Microsecond))
+	}
+	page += "```\n"
+	if total := m.cacheHits + m.cacheMisses; total > 0 {
+		page += fmt.Sprintf("\nPage cache: %d hits, %d misses (%d%% hit rate)\n", m.cacheHits, m.cacheMisses, m.cacheHits*100/total)
+	}
+	if m.aged > 0 {
+		page += fmt.Sprintf("Average tweet cache age when serving: %s\n", (m.ageTotal / time.Duration(m.aged)).Truncate(time.Second))
+	}
+	return page
+}
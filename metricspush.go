@@ -0,0 +1,185 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type metricSample struct {
+	name string
+	// Route the sample is for, if any
+	route string
+	// counter or gauge
+	kind  string
+	value float64
+}
+
+// samples are the metrics as they are now, sorted by name and route.
+func (rh *RequestHandler) samples() []metricSample {
+	m := &rh.metrics
+	m.mu.Lock()
+	var samples []metricSample
+	for route, rm := range m.routes {
+		samples = append(samples,
+			metricSample{"requests_total", route, "counter", float64(rm.Requests)},
+			metricSample{"request_seconds_total", route, "counter", rm.Total.Seconds()},
+			metricSample{"request_seconds_max", route, "gauge", rm.Max.Seconds()})
+	}
+	samples = append(samples,
+		metricSample{"page_cache_hits_total", "", "counter", float64(m.cacheHits)},
+		metricSample{"page_cache_misses_total", "", "counter", float64(m.cacheMisses)})
+	m.mu.Unlock()
+
+	events := &rh.TweetCache.events
+	samples = append(samples,
+		metricSample{"tweets", "", "gauge", float64(len(rh.TweetCache.Tweets))},
+		metricSample{"refreshes_total", "", "counter", float64(events.Count(EventRefreshed))},
+		metricSample{"fetch_failures_total", "", "counter", float64(events.Count(EventFetchFailed))})
+	if last := rh.TweetCache.LastRefresh; !last.IsZero() {
+		samples = append(samples, metricSample{"last_refresh_timestamp_seconds", "", "gauge", float64(last.Unix())})
+	}
+	sort.Slice(samples, func(i, j int) bool {
+		if samples[i].name != samples[j].name {
+			return samples[i].name < samples[j].name
+		}
+		return samples[i].route < samples[j].route
+	})
+	return samples
+}
+
+// prometheusText renders samples in the Prometheus text format, which
+// both scrapers and the Pushgateway take.
+func prometheusText(samples []metricSample) string {
+	var b strings.Builder
+	for i, s := range samples {
+		name := "donaldgem_" + s.name
+		if i == 0 || samples[i-1].name != s.name {
+			fmt.Fprintf(&b, "# TYPE %s %s\n", name, s.kind)
+		}
+		if s.route != "" {
+			fmt.Fprintf(&b, "%s{route=%q} %g\n", name, s.route, s.value)
+		} else {
+			fmt.Fprintf(&b, "%s %g\n", name, s.value)
+		}
+	}
+	return b.String()
+}
+
+func (rh *RequestHandler) serveMetrics(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	fmt.Fprint(w, prometheusText(rh.samples()))
+}
+
+// statsdName turns a route into a statsd path: /tweet/:id/replies
+// becomes tweet.id.replies, and / root.
+func statsdName(route string) string {
+	name := strings.Trim(strings.NewReplacer("/", ".", ":", "", ".", "_").Replace(strings.Trim(route, "/")), ".")
+	if name == "" {
+		return "root"
+	}
+	return name
+}
+
+// statsdLines renders samples as statsd metrics. Counters go out as what
+// they grew by since previous, which is updated.
+func statsdLines(samples []metricSample, previous map[string]float64) []string {
+	var lines []string
+	for _, s := range samples {
+		name := "donaldgem." + s.name
+		if s.route != "" {
+			name += "." + statsdName(s.route)
+		}
+		if s.kind == "gauge" {
+			lines = append(lines, fmt.Sprintf("%s:%s|g", name, statsdValue(s.value)))
+			continue
+		}
+		delta := s.value - previous[name]
+		previous[name] = s.value
+		if delta > 0 {
+			lines = append(lines, fmt.Sprintf("%s:%s|c", name, statsdValue(delta)))
+		}
+	}
+	return lines
+}
+
+// statsdValue formats v without an exponent, which not every statsd
+// server parses.
+func statsdValue(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+// Statsd packets are kept under what fits in one Ethernet frame.
+const statsdPacketSize = 1400
+
+func sendStatsd(addr string, lines []string) error {
+	conn, err := net.Dial("udp", addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	var packet bytes.Buffer
+	for i, line := range lines {
+		packet.WriteString(line + "\n")
+		if i == len(lines)-1 || packet.Len()+len(lines[i+1]) >= statsdPacketSize {
+			if _, err := conn.Write(packet.Bytes()); err != nil {
+				return err
+			}
+			packet.Reset()
+		}
+	}
+	return nil
+}
+
+func (c Config) pushgatewayURL() string {
+	instance := c.Metrics.Instance
+	if instance == "" {
+		instance, _ = os.Hostname()
+	}
+	return strings.TrimSuffix(c.Metrics.PushgatewayURL, "/") + "/metrics/job/donaldgem/instance/" + url.PathEscape(instance)
+}
+
+func (c Config) pushPrometheus(text string) error {
+	req, err := http.NewRequest(http.MethodPut, c.pushgatewayURL(), strings.NewReader(text))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "text/plain; version=0.0.4")
+	client := &http.Client{Transport: c.outbound(nil), Timeout: hookTimeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("pushgateway returned %s", resp.Status)
+	}
+	return nil
+}
+
+// MetricsPusher sends the metrics to the Pushgateway and statsd every
+// metrics.intervalSeconds, for hosts that can't be scraped.
+func (rh *RequestHandler) MetricsPusher() {
+	c := rh.Config
+	previous := map[string]float64{}
+	for range time.Tick(time.Duration(c.Metrics.IntervalSeconds) * time.Second) {
+		samples := rh.samples()
+		if c.Metrics.PushgatewayURL != "" {
+			if err := c.pushPrometheus(prometheusText(samples)); err != nil {
+				fmt.Println("pushing metrics:", err)
+			}
+		}
+		if c.Metrics.Statsd != "" {
+			if err := sendStatsd(c.Metrics.Statsd, statsdLines(samples, previous)); err != nil {
+				fmt.Println("sending metrics to statsd:", err)
+			}
+		}
+	}
+}
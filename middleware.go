@@ -0,0 +1,98 @@
+package main
+
+import (
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/makeworld-the-better-one/go-gemini"
+)
+
+func logRequests(next Handler) Handler {
+	return Func(func(r Request) *gemini.Response {
+		start := time.Now()
+		resp := next.Handle(r)
+		log.Printf("%s %s %d %s", r.RemoteIP(), r.URL, resp.Status, time.Since(start).Truncate(time.Microsecond))
+		return resp
+	})
+}
+
+// RateLimiter counts requests per client IP in fixed one minute windows.
+type RateLimiter struct {
+	PerMinute int
+
+	mu     sync.Mutex
+	window time.Time
+	counts map[string]int
+}
+
+func (rl *RateLimiter) Allow(ip string) bool {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	now := time.Now().Truncate(time.Minute)
+	if !now.Equal(rl.window) {
+		rl.window = now
+		rl.counts = map[string]int{}
+	}
+	rl.counts[ip] += 1
+	return rl.counts[ip] <= rl.PerMinute
+}
+
+func (rl *RateLimiter) slowDown() *gemini.Response {
+	return SlowDown(time.Until(rl.window.Add(time.Minute)))
+}
+
+func (rl *RateLimiter) Middleware(next Handler) Handler {
+	return Func(func(r Request) *gemini.Response {
+		if !rl.Allow(r.RemoteIP()) {
+			return rl.slowDown()
+		}
+		return next.Handle(r)
+	})
+}
+
+// Limit rate limits a single route, on top of any server wide limit.
+func (rl *RateLimiter) Limit(handler HandlerFunc) HandlerFunc {
+	return func(r Request, p Params) *gemini.Response {
+		if !rl.Allow(r.RemoteIP()) {
+			return rl.slowDown()
+		}
+		return handler(r, p)
+	}
+}
+
+func normalizeFingerprint(fp string) string {
+	return strings.ToLower(strings.Replace(fp, ":", "", -1))
+}
+
+// requireCert only lets clients presenting one of the given certificate
+// fingerprints through to paths under the given prefixes.
+func requireCert(prefixes, fingerprints []string) Middleware {
+	allowed := map[string]bool{}
+	for _, fp := range fingerprints {
+		allowed[normalizeFingerprint(fp)] = true
+	}
+	return func(next Handler) Handler {
+		return Func(func(r Request) *gemini.Response {
+			protected := false
+			for _, prefix := range prefixes {
+				if strings.HasPrefix(r.URL.Path, prefix) {
+					protected = true
+					break
+				}
+			}
+			if !protected {
+				return next.Handle(r)
+			}
+			fp := r.Fingerprint()
+			if fp == "" {
+				return CertificateRequired("Client certificate required")
+			}
+			if !allowed[fp] {
+				return CertificateNotAuthorised("Certificate not authorised")
+			}
+			return next.Handle(r)
+		})
+	}
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"bufio"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/dghubble/go-twitter/twitter"
+)
+
+const (
+	misfinPort       = "1958"
+	misfinMaxRequest = 2048
+)
+
+func misfinHost(address string) (string, error) {
+	at := strings.LastIndex(address, "@")
+	if at < 0 {
+		return "", fmt.Errorf("misfin: bad address %q", address)
+	}
+	host := address[at+1:]
+	if _, _, err := net.SplitHostPort(host); err != nil {
+		host = net.JoinHostPort(host, misfinPort)
+	}
+	return host, nil
+}
+
+// sendMisfin delivers message to address, identifying ourselves with cert.
+// Misfin servers are trusted on first use, so their certificate isn't
+// verified.
+func sendMisfin(cert tls.Certificate, address, message string) error {
+	host, err := misfinHost(address)
+	if err != nil {
+		return err
+	}
+
+	request := "misfin://" + address + " "
+	if len(request)+len(message)+2 > misfinMaxRequest {
+		message = message[:misfinMaxRequest-len(request)-2]
+		message = strings.ToValidUTF8(message, "")
+	}
+
+	dialer := &net.Dialer{Timeout: hookTimeout}
+	conn, err := tls.DialWithDialer(dialer, "tcp", host, &tls.Config{
+		Certificates:       []tls.Certificate{cert},
+		InsecureSkipVerify: true,
+	})
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(hookTimeout))
+
+	if _, err := fmt.Fprintf(conn, "%s%s\r\n", request, message); err != nil {
+		return err
+	}
+	status, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil {
+		return err
+	}
+	if !strings.HasPrefix(status, "2") {
+		return fmt.Errorf("misfin: %s refused message: %s", address, strings.TrimSpace(status))
+	}
+	return nil
+}
+
+func (c *Config) formatMisfin(tweet twitter.Tweet) string {
+	message := fmt.Sprintf("New tweet by %s\n\n%s", tweet.User.Name, expandLinks(tweet))
+	if c.Addr.URL != "" {
+		message += fmt.Sprintf("\n\n=> %s%s", strings.TrimSuffix(c.Addr.URL, "/"), permalink(tweet))
+	}
+	return message
+}
+
+func (c *Config) sendMisfinNotifications(tweets []twitter.Tweet) {
+	if len(c.Misfin.Recipients) == 0 || len(tweets) == 0 {
+		return
+	}
+	cert, err := tls.LoadX509KeyPair(c.Misfin.CertFile, c.Misfin.KeyFile)
+	if err != nil {
+		fmt.Println("misfin:", err)
+		return
+	}
+	for _, tweet := range tweets {
+		message := c.formatMisfin(tweet)
+		for _, address := range c.Misfin.Recipients {
+			if err := sendMisfin(cert, address, message); err != nil {
+				fmt.Println(err)
+			}
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const twitterAPIHost = "api.twitter.com"
+
+// outboundTransport stamps our User-Agent on every request and sends
+// Twitter API calls to the configured base URL instead.
+type outboundTransport struct {
+	base      http.RoundTripper
+	userAgent string
+	apiBase   *url.URL
+}
+
+func (t *outboundTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	if t.userAgent != "" {
+		req.Header.Set("User-Agent", t.userAgent)
+	}
+	if t.apiBase != nil && req.URL.Host == twitterAPIHost {
+		req.URL.Scheme = t.apiBase.Scheme
+		req.URL.Host = t.apiBase.Host
+		req.URL.Path = strings.TrimSuffix(t.apiBase.Path, "/") + req.URL.Path
+		req.Host = ""
+	}
+	return t.base.RoundTrip(req)
+}
+
+func (c Config) outbound(base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	t := &outboundTransport{base: base, userAgent: c.HTTP.UserAgent}
+	if c.Twitter.APIBaseURL != "" {
+		// Checked by validate.
+		t.apiBase, _ = url.Parse(c.Twitter.APIBaseURL)
+	}
+	return t
+}
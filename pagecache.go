@@ -0,0 +1,100 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"strings"
+	"sync"
+
+	"github.com/makeworld-the-better-one/go-gemini"
+)
+
+const maxCachedPages = 1000
+
+type cachedPage struct {
+	meta string
+	body string
+}
+
+// PageCache keeps rendered pages until the tweet cache moves to a new
+// generation.
+type PageCache struct {
+	mu         sync.Mutex
+	generation uint64
+	pages      map[string]cachedPage
+}
+
+func (pc *PageCache) Get(key string, generation uint64) (cachedPage, bool) {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+	if pc.generation != generation {
+		return cachedPage{}, false
+	}
+	page, ok := pc.pages[key]
+	return page, ok
+}
+
+func (pc *PageCache) Put(key string, generation uint64, page cachedPage) {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+	if pc.generation != generation || pc.pages == nil {
+		pc.generation = generation
+		pc.pages = map[string]cachedPage{}
+	}
+	if len(pc.pages) >= maxCachedPages {
+		return
+	}
+	pc.pages[key] = page
+}
+
+var uncachedRoutes = map[string]bool{
+	"/stats":              true,
+	"/admin":              true,
+	"/admin/refresh":      true,
+	"/admin/changes":      true,
+	"/admin/sources":      true,
+	"/admin/comments":     true,
+	"/admin/announcement": true,
+	"/admin/maintenance":  true,
+}
+
+func (rh *RequestHandler) cachePages(next Handler) Handler {
+	return Func(func(r Request) *gemini.Response {
+		// Replies and proxied accounts expire on their own schedule, see
+		// RepliesCache and ProxyCache, downloads are kept by BundleCache,
+		// and media are only worth the memory while being sent.
+		if _, ok := bundles[r.URL.Path]; ok || uncachedRoutes[r.URL.Path] || strings.HasSuffix(r.URL.Path, "/replies") || strings.HasPrefix(r.URL.Path, "/u/") || strings.HasPrefix(r.URL.Path, "/media/") {
+			return next.Handle(r)
+		}
+		// "3 minutes ago" is only right for a minute.
+		if rh.Config.relativeTimes {
+			return next.Handle(r)
+		}
+		// Pages marking what a reader hasn't seen, and their bookmarks,
+		// are theirs alone.
+		if rh.reader(r) != "" && (r.URL.Path == "/timeline" || r.URL.Path == "/new") || strings.HasPrefix(r.URL.Path, "/my/") || strings.HasSuffix(r.URL.Path, "/bookmark") {
+			return next.Handle(r)
+		}
+
+		key := r.URL.Path + "?" + r.URL.RawQuery
+		generation := rh.TweetCache.Generation()
+		page, ok := rh.pages.Get(key, generation)
+		rh.metrics.observeCache(ok)
+		if ok {
+			return Success(page.meta, ioutil.NopCloser(bytes.NewBufferString(page.body)))
+		}
+
+		resp := next.Handle(r)
+		if resp.Status != 20 || resp.Body == nil {
+			return resp
+		}
+		body, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return TempFailure("Failed to render page")
+		}
+		rh.pages.Put(key, generation, cachedPage{resp.Meta, string(body)})
+		resp.Body = ioutil.NopCloser(bytes.NewBuffer(body))
+		return resp
+	})
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/makeworld-the-better-one/go-gemini"
+)
+
+// entryBoundaries returns the offsets in a page where a tweet starts: at
+// its heading, or after the delimiter in the classic layout.
+func (c *Config) entryBoundaries(page string) []int {
+	var boundaries []int
+	for _, prefix := range []string{"\n\n## ", "\n\n### "} {
+		for i := 0; ; {
+			n := strings.Index(page[i:], prefix)
+			if n < 0 {
+				break
+			}
+			boundaries = append(boundaries, i+n)
+			i += n + len(prefix)
+		}
+	}
+	if d := c.delimiter(); d != "" && c.UI.Layout == "classic" {
+		line := "\n" + d + "\n"
+		for i := 0; ; {
+			n := strings.Index(page[i:], line)
+			if n < 0 {
+				break
+			}
+			boundaries = append(boundaries, i+n+len(line))
+			i += n + len(line)
+		}
+	}
+	return boundaries
+}
+
+// splitPage cuts page into parts of at most limit bytes, at entry
+// boundaries only, so an entry longer than limit gets a part of its own.
+func splitPage(page string, boundaries []int, limit int) []string {
+	sort.Ints(boundaries)
+	var parts []string
+	start, last := 0, 0
+	for _, b := range boundaries {
+		if b-start > limit && last > start {
+			parts = append(parts, page[start:last])
+			start = last
+		}
+		last = b
+	}
+	if len(page)-start > limit && last > start {
+		parts = append(parts, page[start:last])
+		start = last
+	}
+	return append(parts, page[start:])
+}
+
+func partLink(path, rawQuery string, part int) string {
+	if part > 1 {
+		if rawQuery != "" {
+			rawQuery += "&"
+		}
+		rawQuery += "part=" + strconv.Itoa(part)
+	}
+	if rawQuery == "" {
+		return path
+	}
+	return path + "?" + rawQuery
+}
+
+// splitPages serves pages over ui.maxPageBytes in parts, since some
+// clients choke on very large responses. ?part=N picks a part.
+func (rh *RequestHandler) splitPages(next Handler) Handler {
+	return Func(func(r Request) *gemini.Response {
+		limit := rh.Config.UI.MaxPageBytes
+		// Downloads are meant to be whole, and on routes taking input a
+		// link to another part would be read as part of the answer.
+		if _, ok := bundles[r.URL.Path]; ok || limit <= 0 || rh.router.TakesInput(r.URL.Path) {
+			return next.Handle(r)
+		}
+		part := 1
+		if partParam, found := rh.takeQueryParam(&r, "part"); found {
+			n, err := strconv.Atoi(partParam)
+			if err != nil || n < 1 {
+				return BadRequest("Bad part number")
+			}
+			part = n
+		}
+
+		resp := next.Handle(r)
+		if resp.Status != 20 || resp.Body == nil || !strings.HasPrefix(resp.Meta, "text/gemini") {
+			return resp
+		}
+		body, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return TempFailure("Failed to render page")
+		}
+		if len(body) <= limit && part == 1 {
+			resp.Body = ioutil.NopCloser(bytes.NewBuffer(body))
+			return resp
+		}
+
+		page := string(body)
+		parts := splitPage(page, rh.Config.entryBoundaries(page), limit)
+		if part > len(parts) {
+			return NotFound("No such part")
+		}
+		// Links to the other parts keep ?fmt= and the like, which the
+		// middleware before this one took out.
+		query := r.URL.RawQuery
+		if r.SentQuery != "" {
+			_, _, query = takeParam(r.SentQuery, "part")
+		}
+		text := parts[part-1]
+		if part > 1 {
+			text = fmt.Sprintf("=> %s Previous part\n%s", partLink(r.URL.Path, query, part-1), text)
+		}
+		if part < len(parts) {
+			text += fmt.Sprintf("\n\n=> %s Continue on next page (part %d of %d)\n", partLink(r.URL.Path, query, part+1), part+1, len(parts))
+		}
+		resp.Body = ioutil.NopCloser(bytes.NewBufferString(text))
+		return resp
+	})
+}
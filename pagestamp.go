@@ -0,0 +1,75 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/makeworld-the-better-one/go-gemini"
+)
+
+type pageStamp struct {
+	etag    string
+	updated time.Time
+}
+
+// PageStamps remembers when each page last rendered differently, so the
+// "last updated" line only moves when the content does.
+type PageStamps struct {
+	mu     sync.Mutex
+	stamps map[string]pageStamp
+}
+
+func (ps *PageStamps) Stamp(key, etag string) time.Time {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	if stamp, ok := ps.stamps[key]; ok && stamp.etag == etag {
+		return stamp.updated
+	}
+	if ps.stamps == nil {
+		ps.stamps = map[string]pageStamp{}
+	}
+	now := time.Now().UTC()
+	if len(ps.stamps) < maxCachedPages {
+		ps.stamps[key] = pageStamp{etag, now}
+	}
+	return now
+}
+
+func pageETag(body []byte) string {
+	sum := sha256.Sum256(body)
+	return hex.EncodeToString(sum[:8])
+}
+
+// stampPages ends every page with when it last changed, for readers, and
+// the same again with an etag in the alt text of an empty preformatted
+// block, which clients don't show but crawlers can compare before fetching
+// the rest of the mirror again.
+func (rh *RequestHandler) stampPages(next Handler) Handler {
+	return Func(func(r Request) *gemini.Response {
+		resp := next.Handle(r)
+		if _, ok := bundles[r.URL.Path]; ok || !rh.profile(r.URL.Path).stamp || resp.Status != 20 || resp.Body == nil || !strings.HasPrefix(resp.Meta, "text/gemini") {
+			return resp
+		}
+		body, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return TempFailure("Failed to render page")
+		}
+
+		etag := pageETag(body)
+		updated := rh.stamps.Stamp(r.URL.Path+"?"+r.URL.RawQuery, etag)
+		body = append(body, fmt.Sprintf("\nLast updated %s\n", updated.Format("2006-01-02 15:04 MST"))...)
+		// Screen readers would announce the alt text.
+		if !rh.Config.UI.Accessible {
+			body = append(body, fmt.Sprintf("```updated=%s etag=%s\n```\n", updated.Format(time.RFC3339), etag)...)
+		}
+		resp.Body = ioutil.NopCloser(bytes.NewBuffer(body))
+		return resp
+	})
+}
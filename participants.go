@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+type participant struct {
+	ScreenName string
+	Name       string
+	Tweets     int
+}
+
+// threadParticipants lists the accounts in a thread: its authors, the
+// accounts they reply to and everyone they mention, in order of
+// appearance.
+func (tc *TweetCache) threadParticipants(thread []int) []*participant {
+	var people []*participant
+	seen := map[string]*participant{}
+	add := func(screenName, name string) *participant {
+		key := strings.ToLower(screenName)
+		if p, ok := seen[key]; ok {
+			if p.Name == "" {
+				p.Name = name
+			}
+			return p
+		}
+		p := &participant{ScreenName: screenName, Name: name}
+		seen[key] = p
+		people = append(people, p)
+		return p
+	}
+
+	for n := len(thread) - 1; n >= 0; n -= 1 {
+		tweet := tc.Tweets[thread[n]]
+		if tweet.User != nil {
+			add(tweet.User.ScreenName, tweet.User.Name).Tweets += 1
+		}
+		if tweet.InReplyToScreenName != "" {
+			add(tweet.InReplyToScreenName, "")
+		}
+		if tweet.Entities != nil {
+			for _, mention := range tweet.Entities.UserMentions {
+				add(mention.ScreenName, mention.Name)
+			}
+		}
+	}
+	return people
+}
+
+func profileURL(base, screenName string) string {
+	return fmt.Sprintf("%s/%s", strings.TrimSuffix(base, "/"), screenName)
+}
+
+func (rh *RequestHandler) formatParticipants(thread []int) string {
+	people := rh.TweetCache.threadParticipants(thread)
+	if len(people) == 0 {
+		return ""
+	}
+	page := "\n\n## Participants\n"
+	for _, p := range people {
+		label := "@" + p.ScreenName
+		if p.Name != "" {
+			label = fmt.Sprintf("%s (@%s)", p.Name, p.ScreenName)
+		}
+		if p.Tweets > 0 {
+			label += fmt.Sprintf(", %d tweets", p.Tweets)
+		}
+		switch {
+		case rh.Config.Links.Nitter != "":
+			page += fmt.Sprintf("=> %s %s\n", profileURL(rh.Config.Links.Nitter, p.ScreenName), label)
+			if rh.Config.Links.Twitter != "" {
+				page += fmt.Sprintf("=> %s @%s on Twitter\n", profileURL(rh.Config.Links.Twitter, p.ScreenName), p.ScreenName)
+			}
+		case rh.Config.Links.Twitter != "":
+			page += fmt.Sprintf("=> %s %s\n", profileURL(rh.Config.Links.Twitter, p.ScreenName), label)
+		default:
+			page += fmt.Sprintf("* %s\n", label)
+		}
+	}
+	return page
+}
@@ -0,0 +1,35 @@
+package main
+
+import "path/filepath"
+
+// resolvePath makes a path from the config file relative to the config's
+// directory rather than to wherever the server happens to be started.
+func resolvePath(dir, path string) string {
+	if path == "" || filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(dir, filepath.FromSlash(path))
+}
+
+func (c *Config) resolvePaths(configPath string) {
+	dir := filepath.Dir(configPath)
+	for _, p := range []*string{
+		&c.Cert.CertFile,
+		&c.Cert.KeyFile,
+		&c.UI.AsciiLogoFile,
+		&c.UI.AltLogoFile,
+		&c.Cache.ArchiveFile,
+		&c.Snapshots.File,
+		&c.ReadState.File,
+		&c.ReaderBookmarks.File,
+		&c.Comments.File,
+		&c.ActivityPub.KeyFile,
+		&c.ActivityPub.FollowersFile,
+		&c.Misfin.CertFile,
+		&c.Misfin.KeyFile,
+		&c.Publish.CertFile,
+		&c.Publish.KeyFile,
+	} {
+		*p = resolvePath(dir, *p)
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/dghubble/go-twitter/twitter"
+)
+
+func (tc *TweetCache) FindByID(id int64) (int, error) {
+	for i, tweet := range tc.Tweets {
+		if tweet.ID == id {
+			return i, nil
+		}
+	}
+	return 0, errors.New("twit not available")
+}
+
+// lookup finds a tweet in memory or, past that, in the archive, which is
+// sorted newest first.
+func (tc *TweetCache) lookup(id int64) (twitter.Tweet, bool) {
+	if pos, err := tc.FindByID(id); err == nil {
+		return tc.Tweets[pos], true
+	}
+	if tc.archive != nil {
+		tweets := tc.archive.Tweets
+		i := sort.Search(len(tweets), func(i int) bool { return tweets[i].ID <= id })
+		if i < len(tweets) && tweets[i].ID == id {
+			return tweets[i], true
+		}
+	}
+	return twitter.Tweet{}, false
+}
+
+func permalink(tweet twitter.Tweet) string {
+	return fmt.Sprintf("/tweet/%d", tweet.ID)
+}
+
+func statusURL(base string, tweet twitter.Tweet) string {
+	screenName := ""
+	if tweet.User != nil {
+		screenName = tweet.User.ScreenName
+	}
+	return fmt.Sprintf("%s/%s/status/%d", strings.TrimSuffix(base, "/"), screenName, tweet.ID)
+}
+
+func (rh *RequestHandler) formatPermalink(tweet twitter.Tweet) string {
+	page := rh.formatSingle(tweet) + "\n\n"
+	if thread, err := rh.TweetCache.Thread(tweet.ID); err == nil && len(thread) > 1 {
+		page += fmt.Sprintf("=> /thread/%d Thread (%d tweets)\n", tweet.ID, len(thread))
+	}
+	if rh.Config.Replies.Enabled {
+		page += fmt.Sprintf("=> %s/replies Replies\n", permalink(tweet))
+	}
+	if rh.Config.ReaderBookmarks.Enabled {
+		page += fmt.Sprintf("=> %s/bookmark Bookmark\n", permalink(tweet))
+	}
+	if rh.Config.Comments.Enabled {
+		page += fmt.Sprintf("=> %s/comments Comments (%d)\n", permalink(tweet), len(rh.TweetCache.comments.On(tweet.ID)))
+	}
+	if source, kind := rh.TweetCache.origin(tweet); kind != "twitter" {
+		if source != nil {
+			page += fmt.Sprintf("=> %s View on %s\n", source.URL(tweet), source.Name())
+		}
+		return page
+	}
+	if rh.Config.Links.Twitter != "" {
+		page += fmt.Sprintf("=> %s View on Twitter\n", statusURL(rh.Config.Links.Twitter, tweet))
+	}
+	if rh.Config.Links.Nitter != "" {
+		page += fmt.Sprintf("=> %s View on Nitter\n", statusURL(rh.Config.Links.Nitter, tweet))
+	}
+	return page
+}
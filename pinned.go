@@ -0,0 +1,87 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/dghubble/go-twitter/twitter"
+)
+
+const twitterAPIv2 = "https://api.twitter.com/2/"
+
+// The v1.1 API doesn't expose pinned tweets, so look the ID up via v2.
+func (tc *TweetCache) pinnedTweetID() (int64, error) {
+	endpoint := twitterAPIv2 + "users/by/username/" + url.PathEscape(tc.Config.Twitter.ScreenName)
+	if tc.Config.Twitter.UserID != 0 {
+		endpoint = twitterAPIv2 + "users/" + strconv.FormatInt(tc.Config.Twitter.UserID, 10)
+	}
+
+	resp, err := tc.httpClient().Get(endpoint + "?user.fields=pinned_tweet_id")
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("twitter: user lookup returned %s", resp.Status)
+	}
+
+	var user struct {
+		Data struct {
+			PinnedTweetID string `json:"pinned_tweet_id"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&user); err != nil {
+		return 0, err
+	}
+	if user.Data.PinnedTweetID == "" {
+		return 0, nil
+	}
+	return strconv.ParseInt(user.Data.PinnedTweetID, 10, 64)
+}
+
+func pinnedID(tweet *twitter.Tweet) int64 {
+	if tweet == nil {
+		return 0
+	}
+	return tweet.ID
+}
+
+// getPinned returns the account's pinned tweet, or nil if there is none or
+// it couldn't be fetched.
+func (tc *TweetCache) getPinned() *twitter.Tweet {
+	id, err := tc.pinnedTweetID()
+	if err != nil || id == 0 {
+		return nil
+	}
+	if pos, err := tc.FindByID(id); err == nil {
+		tweet := tc.Tweets[pos]
+		return &tweet
+	}
+	tweet, _, err := twitter.NewClient(tc.httpClient()).Statuses.Show(id, nil)
+	if err != nil {
+		return nil
+	}
+	return tweet
+}
+
+func (rh *RequestHandler) formatPinned() string {
+	pinned := rh.TweetCache.Pinned
+	if pinned == nil {
+		return ""
+	}
+	if rh.Config.UI.Layout == "headings" {
+		page := fmt.Sprintf("\n\n## %s%s\n\n%s", rh.Config.glyph(rh.Config.convertEmoji("📌 "), "Pinned: "), rh.tweetHeading(*pinned), rh.TweetCache.Format(*pinned))
+		if _, err := rh.TweetCache.FindByID(pinned.ID); err == nil {
+			page += fmt.Sprintf("\n=> %s %s", permalink(*pinned), rh.Config.label("permalink"))
+		}
+		return page
+	}
+	page := fmt.Sprintf("\n\n%s%s", rh.Config.glyph(rh.Config.convertEmoji("📌 "), "Pinned: "), rh.TweetCache.Format(*pinned))
+	if _, err := rh.TweetCache.FindByID(pinned.ID); err == nil {
+		page += fmt.Sprintf("\n=> %s %s", permalink(*pinned), rh.Config.label("permalink"))
+	}
+	return page + "\n\n" + rh.Config.delimiter()
+}
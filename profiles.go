@@ -0,0 +1,82 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+
+	"github.com/makeworld-the-better-one/go-gemini"
+)
+
+// renderProfile is what a route's pages carry besides their content.
+type renderProfile struct {
+	// Logo, navigation and footer
+	chrome bool
+	// The "Last updated" line and stamp block
+	stamp bool
+}
+
+var renderProfiles = map[string]renderProfile{
+	"full": {chrome: true, stamp: true},
+	// For pages read by subscription clients, which would otherwise
+	// capture the same decoration with every entry
+	"bare": {},
+}
+
+// Profiles of routes, by pattern, unless ui.profiles says otherwise.
+var defaultProfiles = map[string]string{
+	"/digest":        "bare",
+	"/search/:query": "bare",
+}
+
+func profileNames() []string {
+	var names []string
+	for name := range renderProfiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (c *Config) validateProfiles() error {
+	for pattern, name := range c.UI.Profiles {
+		if _, ok := renderProfiles[name]; !ok {
+			return fmt.Errorf("ui.profiles: %s must be one of %s, got %q", pattern, strings.Join(profileNames(), ", "), name)
+		}
+	}
+	return nil
+}
+
+func (rh *RequestHandler) profile(path string) renderProfile {
+	pattern := rh.router.Pattern(path)
+	name, ok := rh.Config.UI.Profiles[pattern]
+	if !ok {
+		name, ok = defaultProfiles[pattern]
+	}
+	if !ok {
+		return renderProfiles["full"]
+	}
+	return renderProfiles[name]
+}
+
+// applyProfiles takes the header and footer off pages whose profile goes
+// without them. It runs inside the page cache, so cached pages are
+// already trimmed.
+func (rh *RequestHandler) applyProfiles(next Handler) Handler {
+	return Func(func(r Request) *gemini.Response {
+		resp := next.Handle(r)
+		if rh.profile(r.URL.Path).chrome || resp.Status != 20 || resp.Body == nil || !strings.HasPrefix(resp.Meta, "text/gemini") {
+			return resp
+		}
+		page, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return TempFailure("Failed to render page")
+		}
+		body := strings.TrimPrefix(string(page), rh.getHeader())
+		body = strings.TrimSuffix(body, rh.getFooter()) + "\n"
+		return Success(resp.Meta, ioutil.NopCloser(bytes.NewBufferString(body)))
+	})
+}
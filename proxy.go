@@ -0,0 +1,221 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dghubble/go-twitter/twitter"
+	"github.com/makeworld-the-better-one/go-gemini"
+)
+
+// Tweets shown per proxied account.
+const proxyTweetCount = 20
+
+var screenNamePattern = regexp.MustCompile(`^[A-Za-z0-9_]{1,15}$`)
+
+type proxiedAccount struct {
+	fetched time.Time
+	tweets  []twitter.Tweet
+	// Set from admission until the first fetch is in
+	pending bool
+}
+
+// ProxyCache keeps timelines fetched for /u/ briefly, so a popular
+// account doesn't cost an API call per visit.
+type ProxyCache struct {
+	mu       sync.Mutex
+	accounts map[string]proxiedAccount
+}
+
+func (pc *ProxyCache) Get(name string, ttl time.Duration) ([]twitter.Tweet, bool) {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+	account, ok := pc.accounts[name]
+	if !ok || account.pending || time.Since(account.fetched) > ttl {
+		return nil, false
+	}
+	return account.tweets, true
+}
+
+func (pc *ProxyCache) Put(name string, tweets []twitter.Tweet, ttl time.Duration) {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+	if pc.accounts == nil {
+		pc.accounts = map[string]proxiedAccount{}
+	}
+	for key, account := range pc.accounts {
+		if time.Since(account.fetched) > ttl {
+			delete(pc.accounts, key)
+		}
+	}
+	pc.accounts[name] = proxiedAccount{fetched: time.Now(), tweets: tweets}
+}
+
+func (pc *ProxyCache) findMedia(id int64) (twitter.MediaEntity, bool) {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+	for _, account := range pc.accounts {
+		if media, ok := findMedia(account.tweets, id); ok {
+			return media, true
+		}
+	}
+	return twitter.MediaEntity{}, false
+}
+
+// Admit reports whether there's room for one more account, or how long
+// until the oldest expires if there isn't. Accounts already cached are
+// always let through. An admitted account takes its place right away, so
+// fetches under way count against max; Release gives it back if the
+// fetch fails.
+func (pc *ProxyCache) Admit(name string, max int, ttl time.Duration) (time.Duration, bool) {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+	if account, ok := pc.accounts[name]; (ok && time.Since(account.fetched) <= ttl) || max <= 0 {
+		return 0, true
+	}
+	live := 0
+	var oldest time.Time
+	for _, account := range pc.accounts {
+		if time.Since(account.fetched) > ttl {
+			continue
+		}
+		live += 1
+		if oldest.IsZero() || account.fetched.Before(oldest) {
+			oldest = account.fetched
+		}
+	}
+	if live < max {
+		if pc.accounts == nil {
+			pc.accounts = map[string]proxiedAccount{}
+		}
+		pc.accounts[name] = proxiedAccount{fetched: time.Now(), pending: true}
+		return 0, true
+	}
+	return time.Until(oldest.Add(ttl)), false
+}
+
+// Release frees the place of an admitted account whose fetch failed.
+func (pc *ProxyCache) Release(name string) {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+	if account, ok := pc.accounts[name]; ok && account.pending {
+		delete(pc.accounts, name)
+	}
+}
+
+// proxyAllowed applies proxy.allow and proxy.deny, ignoring case like
+// Twitter does.
+func (c *Config) proxyAllowed(screenName string) bool {
+	for _, name := range c.Proxy.Deny {
+		if strings.EqualFold(strings.TrimPrefix(name, "@"), screenName) {
+			return false
+		}
+	}
+	if len(c.Proxy.Allow) == 0 {
+		return true
+	}
+	for _, name := range c.Proxy.Allow {
+		if strings.EqualFold(strings.TrimPrefix(name, "@"), screenName) {
+			return true
+		}
+	}
+	return false
+}
+
+// proxyError is an account that can't be mirrored, explained.
+type proxyError struct {
+	meta string
+}
+
+func (e proxyError) Error() string {
+	return e.meta
+}
+
+// fetchAccount reads any public account's recent tweets.
+func (tc *TweetCache) fetchAccount(screenName string) ([]twitter.Tweet, error) {
+	client := twitter.NewClient(tc.httpClient())
+	f := false
+	tweets, resp, err := client.Timelines.UserTimeline(&twitter.UserTimelineParams{
+		ScreenName:     screenName,
+		Count:          proxyTweetCount,
+		ExcludeReplies: &f,
+	})
+	if resp != nil {
+		switch resp.StatusCode {
+		case http.StatusNotFound:
+			return nil, proxyError{"No such account"}
+		case http.StatusUnauthorized:
+			return nil, proxyError{"This account's tweets are protected"}
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return dropRepliesToOthers(tweets), nil
+}
+
+// formatProxyEntry is formatEntry for tweets that only live on Twitter,
+// so they link there instead of to a permalink.
+func (rh *RequestHandler) formatProxyEntry(tweet twitter.Tweet) string {
+	text := rh.TweetCache.Format(tweet)
+	link := ""
+	if rh.Config.Links.Twitter != "" {
+		link = fmt.Sprintf("\n=> %s View on Twitter", statusURL(rh.Config.Links.Twitter, tweet))
+	}
+	if rh.Config.UI.Layout == "classic" {
+		return fmt.Sprintf("\n\n%s%s\n\n%s", text, link, rh.Config.delimiter())
+	}
+	return fmt.Sprintf("\n\n## %s\n\n%s%s", rh.tweetHeading(tweet), text, link)
+}
+
+func (rh *RequestHandler) formatProxy(screenName string, tweets []twitter.Tweet) string {
+	page := fmt.Sprintf("# @%s\n\nRecent tweets, mirrored on request.", screenName)
+	if len(tweets) == 0 {
+		return page + "\n\nNo tweets."
+	}
+	for _, tweet := range tweets {
+		page += rh.formatProxyEntry(tweet)
+	}
+	return page
+}
+
+func (rh *RequestHandler) showProxy(screenName string) *gemini.Response {
+	if !screenNamePattern.MatchString(screenName) {
+		return BadRequest("Not a Twitter screen name")
+	}
+	if !rh.Config.proxyAllowed(screenName) {
+		return NotFound("This account isn't mirrored here")
+	}
+	key := strings.ToLower(screenName)
+	ttl := time.Duration(rh.Config.Proxy.CacheMinutes) * time.Minute
+	tweets, ok := rh.proxy.Get(key, ttl)
+	if !ok {
+		if wait, ok := rh.proxy.Admit(key, rh.Config.Proxy.MaxAccounts, ttl); !ok {
+			return SlowDown(wait)
+		}
+		var err error
+		tweets, err = rh.TweetCache.fetchAccount(screenName)
+		if err != nil {
+			rh.proxy.Release(key)
+		}
+		var quota quotaError
+		if pe, ok := err.(proxyError); ok {
+			return NotFound(pe.meta)
+		} else if errors.As(err, &quota) {
+			return SlowDown(time.Until(quota.until))
+		} else if err != nil {
+			fmt.Println(err)
+			return TempFailure("Could not fetch tweets")
+		}
+		rh.proxy.Put(key, tweets, ttl)
+	}
+	body := ioutil.NopCloser(bytes.NewBufferString(rh.wrapBody(rh.formatProxy(screenName, tweets))))
+	return Success(rh.meta(), body)
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestProxyAdmitCountsFetchesUnderWay(t *testing.T) {
+	var pc ProxyCache
+	if _, ok := pc.Admit("a", 2, time.Minute); !ok {
+		t.Fatal("first account refused")
+	}
+	if _, ok := pc.Admit("b", 2, time.Minute); !ok {
+		t.Fatal("second account refused")
+	}
+	if _, ok := pc.Admit("c", 2, time.Minute); ok {
+		t.Error("third account let in while two are being fetched")
+	}
+	if _, ok := pc.Get("a", time.Minute); ok {
+		t.Error("an account being fetched is cached")
+	}
+	if _, ok := pc.Admit("a", 2, time.Minute); !ok {
+		t.Error("an admitted account refused")
+	}
+
+	pc.Release("b")
+	if _, ok := pc.Admit("c", 2, time.Minute); !ok {
+		t.Error("a failed fetch kept its place")
+	}
+	pc.Put("a", fixtureTweets(1), time.Minute)
+	pc.Release("a")
+	if tweets, ok := pc.Get("a", time.Minute); !ok || len(tweets) != 1 {
+		t.Error("releasing a fetched account dropped it")
+	}
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"crypto/tls"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/makeworld-the-better-one/go-gemini"
+)
+
+const titanPort = "1965"
+
+// titanUpload stores body at target, a titan:// URL, authorising with
+// token if there is one. Like misfin, the remote capsule's certificate
+// is trusted on first use and not verified.
+func titanUpload(cert *tls.Certificate, target, token, mediaType string, body []byte) error {
+	u, err := url.Parse(target)
+	if err != nil {
+		return err
+	}
+	host := u.Host
+	if _, _, err := net.SplitHostPort(host); err != nil {
+		host = net.JoinHostPort(host, titanPort)
+	}
+	// Titan parameters are separated by ";", so the media type goes
+	// without its own parameters.
+	mediaType = strings.TrimSpace(strings.SplitN(mediaType, ";", 2)[0])
+	request := fmt.Sprintf("%s;mime=%s;size=%d", target, mediaType, len(body))
+	if token != "" {
+		request += ";token=" + url.PathEscape(token)
+	}
+
+	tlsConfig := &tls.Config{InsecureSkipVerify: true}
+	if cert != nil {
+		tlsConfig.Certificates = []tls.Certificate{*cert}
+	}
+	dialer := &net.Dialer{Timeout: hookTimeout}
+	conn, err := tls.DialWithDialer(dialer, "tcp", host, tlsConfig)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(hookTimeout))
+
+	if _, err := fmt.Fprintf(conn, "%s\r\n", request); err != nil {
+		return err
+	}
+	if _, err := conn.Write(body); err != nil {
+		return err
+	}
+	status, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil {
+		return err
+	}
+	// Some servers redirect to the uploaded page, that's a success too.
+	if !strings.HasPrefix(status, "2") && !strings.HasPrefix(status, "3") {
+		return fmt.Errorf("titan: %s refused upload: %s", target, strings.TrimSpace(status))
+	}
+	return nil
+}
+
+// Publisher pushes rendered pages to another capsule over Titan whenever
+// the tweets change, so any Gemini server can serve the mirror.
+type Publisher struct {
+	rh   *RequestHandler
+	cert *tls.Certificate
+	// Hash of each path's last upload, to only send pages that changed
+	uploaded   map[string][sha256.Size]byte
+	generation uint64
+}
+
+func NewPublisher(tc *TweetCache, c Config) (*Publisher, error) {
+	p := &Publisher{uploaded: map[string][sha256.Size]byte{}}
+	if c.Publish.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(c.Publish.CertFile, c.Publish.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		p.cert = &cert
+	}
+	// Pages are rendered for files: no per client rate limits, no
+	// access log, and no parts, since ?part= doesn't survive as a file.
+	c.Middleware.AccessLog = false
+	c.Middleware.RateLimit = 0
+	c.UI.MaxPageBytes = -1
+	p.rh = NewRequestHandler(tc, c)
+	return p, nil
+}
+
+// remotePath maps a route to a file on the remote capsule. Pages become
+// directory indexes so their links keep working; downloads keep their
+// names.
+func (p *Publisher) remotePath(path string) string {
+	base := strings.TrimSuffix(p.rh.Config.Publish.URL, "/")
+	if _, ok := bundles[path]; ok {
+		return base + path
+	}
+	return base + strings.TrimSuffix(path, "/") + "/index.gmi"
+}
+
+func (p *Publisher) paths() []string {
+	paths := []string{"/", "/timeline", "/tags", "/stats", "/digest", "/index-all"}
+	for path := range bundles {
+		paths = append(paths, path)
+	}
+	tweets := p.rh.TweetCache.Tweets
+	for n := 1; n <= len(tweets)/indexPageSize; n += 1 {
+		paths = append(paths, "/index-all/"+strconv.Itoa(n))
+	}
+	days := map[string]bool{}
+	for _, tweet := range tweets {
+		paths = append(paths, permalink(tweet))
+		if t, err := tweet.CreatedAtTime(); err == nil && !days[t.Format(dateLayout)] {
+			days[t.Format(dateLayout)] = true
+			paths = append(paths, "/digest/"+t.Format(dateLayout))
+		}
+	}
+	return paths
+}
+
+func (p *Publisher) render(path string) (*gemini.Response, []byte, error) {
+	u, err := url.Parse("gemini://localhost" + path)
+	if err != nil {
+		return nil, nil, err
+	}
+	resp := p.rh.Handle(Request{Request: gemini.Request{URL: u}})
+	if resp.Status != 20 {
+		return resp, nil, nil
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	return resp, body, err
+}
+
+// Publish uploads every page that changed since the last upload.
+func (p *Publisher) Publish() error {
+	uploads := 0
+	for _, path := range p.paths() {
+		resp, body, err := p.render(path)
+		if err != nil {
+			return err
+		}
+		if resp.Status != 20 {
+			continue
+		}
+		sum := sha256.Sum256(body)
+		if p.uploaded[path] == sum {
+			continue
+		}
+		if err := titanUpload(p.cert, p.remotePath(path), p.rh.Config.Publish.Token, resp.Meta, body); err != nil {
+			return err
+		}
+		p.uploaded[path] = sum
+		uploads += 1
+	}
+	if uploads > 0 {
+		fmt.Printf("published %d pages to %s\n", uploads, p.rh.Config.Publish.URL)
+	}
+	return nil
+}
+
+// Run publishes after every refresh that changed something. Failed
+// uploads are retried on the next round.
+func (p *Publisher) Run() {
+	for {
+		if generation := p.rh.TweetCache.Generation(); generation != p.generation {
+			if err := p.Publish(); err != nil {
+				fmt.Println("publishing failed:", err)
+			} else {
+				p.generation = generation
+			}
+		}
+		time.Sleep(time.Minute)
+	}
+}
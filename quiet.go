@@ -0,0 +1,105 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cronField is the set of values one cron field matches.
+type cronField map[int]bool
+
+// cronSchedule matches times like a crontab line: minute, hour, day of
+// month, month and day of week (0 is Sunday). As in cron, when neither
+// day field starts with *, a day matching either of them will do.
+type cronSchedule struct {
+	fields    [5]cronField
+	eitherDay bool
+}
+
+var cronBounds = [5][2]int{{0, 59}, {0, 23}, {1, 31}, {1, 12}, {0, 6}}
+
+func parseCronField(spec string, min, max int) (cronField, error) {
+	field := cronField{}
+	for _, part := range strings.Split(spec, ",") {
+		step := 1
+		if i := strings.Index(part, "/"); i >= 0 {
+			n, err := strconv.Atoi(part[i+1:])
+			if err != nil || n <= 0 {
+				return nil, fmt.Errorf("bad step in %q", part)
+			}
+			step, part = n, part[:i]
+		}
+
+		lo, hi := min, max
+		if part != "*" {
+			bounds := strings.SplitN(part, "-", 2)
+			var err error
+			if lo, err = strconv.Atoi(bounds[0]); err != nil {
+				return nil, fmt.Errorf("bad value %q", part)
+			}
+			hi = lo
+			if len(bounds) == 2 {
+				if hi, err = strconv.Atoi(bounds[1]); err != nil {
+					return nil, fmt.Errorf("bad range %q", part)
+				}
+			}
+		}
+		if lo < min || hi > max || lo > hi {
+			return nil, fmt.Errorf("%q is outside %d-%d", part, min, max)
+		}
+		for v := lo; v <= hi; v += step {
+			field[v] = true
+		}
+	}
+	return field, nil
+}
+
+func parseCron(spec string) (cronSchedule, error) {
+	var s cronSchedule
+	fields := strings.Fields(spec)
+	if len(fields) != len(s.fields) {
+		return s, fmt.Errorf("cron expression %q needs 5 fields", spec)
+	}
+	for i, f := range fields {
+		field, err := parseCronField(f, cronBounds[i][0], cronBounds[i][1])
+		if err != nil {
+			return s, fmt.Errorf("cron expression %q: %v", spec, err)
+		}
+		s.fields[i] = field
+	}
+	s.eitherDay = !strings.HasPrefix(fields[2], "*") && !strings.HasPrefix(fields[4], "*")
+	return s, nil
+}
+
+func (s cronSchedule) Matches(t time.Time) bool {
+	f := s.fields
+	day := f[2][t.Day()] && f[4][int(t.Weekday())]
+	if s.eitherDay {
+		day = f[2][t.Day()] || f[4][int(t.Weekday())]
+	}
+	return f[0][t.Minute()] && f[1][t.Hour()] && day && f[3][int(t.Month())]
+}
+
+func (c *Config) compileQuietHours() error {
+	c.quietHours = nil
+	for _, spec := range c.Refresh.QuietHours {
+		s, err := parseCron(spec)
+		if err != nil {
+			return err
+		}
+		c.quietHours = append(c.quietHours, s)
+	}
+	return nil
+}
+
+// isQuiet reports whether polling is paused at t.
+func (c *Config) isQuiet(t time.Time) bool {
+	for _, s := range c.quietHours {
+		if s.Matches(t) {
+			return true
+		}
+	}
+	return false
+}
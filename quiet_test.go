@@ -0,0 +1,39 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCronMatches(t *testing.T) {
+	// 2020-07-01 is a Wednesday, the 5th and 12th are Sundays.
+	at := func(day, hour int) time.Time {
+		return time.Date(2020, 7, day, hour, 30, 0, 0, time.UTC)
+	}
+	tests := []struct {
+		spec string
+		t    time.Time
+		want bool
+	}{
+		{"* 0-6 * * *", at(1, 3), true},
+		{"* 0-6 * * *", at(1, 7), false},
+		{"* * 1 * 0", at(1, 12), true},
+		{"* * 1 * 0", at(5, 12), true},
+		{"* * 1 * 0", at(2, 12), false},
+		{"* * * * 0", at(1, 12), false},
+		{"* * * * 0", at(5, 12), true},
+		{"* * 1 * *", at(5, 12), false},
+		{"* * */2 * 0", at(12, 12), false},
+		{"* * */2 * 0", at(3, 12), false},
+		{"* 0-6 1 * 0", at(5, 12), false},
+	}
+	for _, tt := range tests {
+		s, err := parseCron(tt.spec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := s.Matches(tt.t); got != tt.want {
+			t.Errorf("%q at %s: %v, want %v", tt.spec, tt.t.Format("Mon 2006-01-02 15:04"), got, tt.want)
+		}
+	}
+}
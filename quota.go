@@ -0,0 +1,143 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Twitter's rate limits reset every 15 minutes; budgets count calls in
+// windows aligned to the clock, and per UTC day.
+const quotaWindow = 15 * time.Minute
+
+// APIQuota counts Twitter API calls against twitter.quota, in Redis when
+// instances share one.
+type APIQuota struct {
+	mu     sync.Mutex
+	window time.Time
+	calls  int
+	day    string
+	daily  int
+	shared *Redis
+}
+
+type quotaError struct {
+	until time.Time
+}
+
+func (e quotaError) Error() string {
+	return fmt.Sprintf("twitter: API quota used up until %s", e.until.Format(time.RFC3339))
+}
+
+// reset starts new counts when the window or day has moved on.
+func (q *APIQuota) reset(now time.Time) {
+	if window := now.Truncate(quotaWindow); !window.Equal(q.window) {
+		q.window, q.calls = window, 0
+	}
+	if day := now.UTC().Format(dateLayout); day != q.day {
+		q.day, q.daily = day, 0
+	}
+}
+
+// quotaReset is how long until another call fits the budgets, 0 if one
+// does now.
+func quotaReset(now time.Time, calls, daily, perWindow, perDay int) time.Duration {
+	if perDay > 0 && daily >= perDay {
+		y, m, d := now.UTC().Date()
+		return time.Date(y, m, d+1, 0, 0, 0, 0, time.UTC).Sub(now)
+	}
+	if perWindow > 0 && calls >= perWindow {
+		return now.Truncate(quotaWindow).Add(quotaWindow).Sub(now)
+	}
+	return 0
+}
+
+// counts are the calls made this window and today. Without Redis each
+// instance falls back on its own.
+func (q *APIQuota) counts(now time.Time) (calls, daily int) {
+	q.reset(now)
+	if q.shared != nil {
+		calls, daily, err := q.sharedCounts(now)
+		if err == nil {
+			return calls, daily
+		}
+		fmt.Println("reading shared quota:", err)
+	}
+	return q.calls, q.daily
+}
+
+// Take counts a call, or refuses it when it would go over a budget.
+func (q *APIQuota) Take(perWindow, perDay int) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	now := time.Now()
+	q.reset(now)
+	if q.shared != nil {
+		err := q.takeShared(now, perWindow, perDay)
+		if _, ok := err.(quotaError); err == nil || ok {
+			return err
+		}
+		fmt.Println("counting shared quota:", err)
+	}
+	if wait := quotaReset(now, q.calls, q.daily, perWindow, perDay); wait > 0 {
+		return quotaError{now.Add(wait)}
+	}
+	q.calls++
+	q.daily++
+	return nil
+}
+
+// Wait is how long until the budgets allow another call.
+func (q *APIQuota) Wait(perWindow, perDay int) time.Duration {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	now := time.Now()
+	calls, daily := q.counts(now)
+	return quotaReset(now, calls, daily, perWindow, perDay)
+}
+
+func quotaLine(used, limit int, period string) string {
+	if limit <= 0 {
+		return fmt.Sprintf("%d %s", used, period)
+	}
+	return fmt.Sprintf("%d of %d %s, %d left", used, limit, period, limit-used)
+}
+
+func (q *APIQuota) format(perWindow, perDay int) string {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	now := time.Now()
+	calls, daily := q.counts(now)
+	return fmt.Sprintf("API calls: %s (resets %s), %s\n",
+		quotaLine(calls, perWindow, "this window"), now.Truncate(quotaWindow).Add(quotaWindow).Format("15:04"),
+		quotaLine(daily, perDay, "today"))
+}
+
+type quotaTransport struct {
+	base http.RoundTripper
+	tc   *TweetCache
+}
+
+func (t quotaTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	quota := t.tc.Config.Twitter.Quota
+	if err := t.tc.quota.Take(quota.PerWindow, quota.PerDay); err != nil {
+		return nil, err
+	}
+	return t.base.RoundTrip(req)
+}
+
+// twitterTransport is the outbound transport for Twitter API calls, which
+// all count against the quota.
+func (tc *TweetCache) twitterTransport(base http.RoundTripper) http.RoundTripper {
+	return quotaTransport{tc.Config.outbound(base), tc}
+}
+
+// quotaWait is how long fetches from Twitter have to wait for the quota.
+func (tc *TweetCache) quotaWait() time.Duration {
+	if !tc.liveConfig().usesTwitter() {
+		return 0
+	}
+	quota := tc.Config.Twitter.Quota
+	return tc.quota.Wait(quota.PerWindow, quota.PerDay)
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/makeworld-the-better-one/go-gemini"
+)
+
+// Bookmarks kept per reader; the oldest make room for new ones.
+const maxReaderBookmarks = 500
+
+// ReaderBookmarks are the tweets readers bookmarked, by client certificate
+// fingerprint, newest first.
+type ReaderBookmarks struct {
+	mu        sync.Mutex
+	Bookmarks map[string][]int64 `json:"bookmarks"`
+}
+
+func LoadReaderBookmarks(path string) (*ReaderBookmarks, error) {
+	rb := &ReaderBookmarks{Bookmarks: map[string][]int64{}}
+	if path == "" {
+		return rb, nil
+	}
+	b, err := ioutil.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return rb, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, rb); err != nil {
+		return nil, err
+	}
+	if rb.Bookmarks == nil {
+		rb.Bookmarks = map[string][]int64{}
+	}
+	return rb, nil
+}
+
+func (rb *ReaderBookmarks) Save(path string) error {
+	if path == "" {
+		return nil
+	}
+	rb.mu.Lock()
+	b, err := json.Marshal(rb)
+	rb.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path, b)
+}
+
+func (rb *ReaderBookmarks) Get(fingerprint string) []int64 {
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+	return append([]int64(nil), rb.Bookmarks[fingerprint]...)
+}
+
+// Add puts id at the top of the reader's bookmarks, moving it there if it
+// was already bookmarked.
+func (rb *ReaderBookmarks) Add(fingerprint string, id int64) {
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+	ids := []int64{id}
+	for _, old := range rb.Bookmarks[fingerprint] {
+		if old != id && len(ids) < maxReaderBookmarks {
+			ids = append(ids, old)
+		}
+	}
+	rb.Bookmarks[fingerprint] = ids
+}
+
+// Remove reports whether id was bookmarked.
+func (rb *ReaderBookmarks) Remove(fingerprint string, id int64) bool {
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+	var ids []int64
+	for _, old := range rb.Bookmarks[fingerprint] {
+		if old != id {
+			ids = append(ids, old)
+		}
+	}
+	removed := len(ids) != len(rb.Bookmarks[fingerprint])
+	if len(ids) == 0 {
+		delete(rb.Bookmarks, fingerprint)
+	} else {
+		rb.Bookmarks[fingerprint] = ids
+	}
+	return removed
+}
+
+func (rh *RequestHandler) saveReaderBookmarks() {
+	if err := rh.TweetCache.readerBookmarks.Save(rh.Config.ReaderBookmarks.File); err != nil {
+		fmt.Println(err)
+	}
+}
+
+func (rh *RequestHandler) bookmarkTweet(fingerprint string, id int64) *gemini.Response {
+	if fingerprint == "" {
+		return CertificateRequired("Bookmarks are kept by client certificate")
+	}
+	if _, ok := rh.TweetCache.lookup(id); !ok {
+		return NotFound("Tweet not found")
+	}
+	rh.TweetCache.readerBookmarks.Add(fingerprint, id)
+	rh.saveReaderBookmarks()
+	return Redirect("/my/bookmarks")
+}
+
+func (rh *RequestHandler) removeReaderBookmark(fingerprint string, id int64) *gemini.Response {
+	if fingerprint == "" {
+		return CertificateRequired("Bookmarks are kept by client certificate")
+	}
+	if !rh.TweetCache.readerBookmarks.Remove(fingerprint, id) {
+		return NotFound("No such bookmark")
+	}
+	rh.saveReaderBookmarks()
+	return Redirect("/my/bookmarks")
+}
+
+func (rh *RequestHandler) formatReaderBookmarks(fingerprint string) string {
+	var page strings.Builder
+	page.WriteString("# My bookmarks")
+	ids := rh.TweetCache.readerBookmarks.Get(fingerprint)
+	if len(ids) == 0 {
+		page.WriteString("\n\nNothing bookmarked yet. Tweet pages have a link to bookmark them.")
+		return page.String()
+	}
+	for _, id := range ids {
+		tweet, ok := rh.TweetCache.lookup(id)
+		if !ok {
+			fmt.Fprintf(&page, "\n\nTweet %d is no longer mirrored.", id)
+		} else {
+			page.WriteString(rh.formatEntry(tweet, rh.TweetCache.Format(tweet)))
+		}
+		fmt.Fprintf(&page, "\n=> /my/bookmarks/remove/%d Remove bookmark", id)
+	}
+	return page.String()
+}
+
+func (rh *RequestHandler) showReaderBookmarks(fingerprint string) *gemini.Response {
+	if fingerprint == "" {
+		return CertificateRequired("Bookmarks are kept by client certificate")
+	}
+	body := ioutil.NopCloser(bytes.NewBufferString(rh.wrapBody(rh.formatReaderBookmarks(fingerprint))))
+	return Success(rh.meta(), body)
+}
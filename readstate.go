@@ -0,0 +1,91 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
+)
+
+// ReadState remembers, by client certificate fingerprint, the newest
+// tweet each reader has seen, so their timeline can mark what came after.
+type ReadState struct {
+	mu   sync.Mutex
+	Seen map[string]int64 `json:"seen"`
+}
+
+func LoadReadState(path string) (*ReadState, error) {
+	rs := &ReadState{Seen: map[string]int64{}}
+	if path == "" {
+		return rs, nil
+	}
+	b, err := ioutil.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return rs, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, rs); err != nil {
+		return nil, err
+	}
+	if rs.Seen == nil {
+		rs.Seen = map[string]int64{}
+	}
+	return rs, nil
+}
+
+func (rs *ReadState) Save(path string) error {
+	if path == "" {
+		return nil
+	}
+	rs.mu.Lock()
+	b, err := json.Marshal(rs)
+	rs.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path, b)
+}
+
+// Get is the newest tweet the reader has seen, 0 for a new reader.
+func (rs *ReadState) Get(fingerprint string) int64 {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	return rs.Seen[fingerprint]
+}
+
+// Mark records that the reader has seen up to id, reporting whether that
+// is news.
+func (rs *ReadState) Mark(fingerprint string, id int64) bool {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	if rs.Seen[fingerprint] == id {
+		return false
+	}
+	rs.Seen[fingerprint] = id
+	return true
+}
+
+// reader is the fingerprint read state is kept under for r, or "" when
+// there is none to keep.
+func (rh *RequestHandler) reader(r Request) string {
+	if rh.TweetCache.readState == nil {
+		return ""
+	}
+	return r.Fingerprint()
+}
+
+// seenBy returns the newest tweet the reader had seen, and marks all of
+// the cache as seen now that they're looking at it.
+func (rh *RequestHandler) seenBy(fingerprint string) int64 {
+	tc := rh.TweetCache
+	seen := tc.readState.Get(fingerprint)
+	if len(tc.Tweets) > 0 && tc.readState.Mark(fingerprint, tc.Tweets[0].ID) {
+		if err := tc.readState.Save(rh.Config.ReadState.File); err != nil {
+			fmt.Println(err)
+		}
+	}
+	return seen
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const redisTimeout = 5 * time.Second
+
+// Redis is just enough of a client for sharing a cache between
+// instances: one connection, commands sent one at a time.
+type Redis struct {
+	addr     string
+	password string
+	db       int
+	prefix   string
+
+	mu   sync.Mutex
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+type redisError string
+
+func (e redisError) Error() string {
+	return "redis: " + string(e)
+}
+
+func NewRedis(c Config) *Redis {
+	return &Redis{addr: c.Redis.Addr, password: c.Redis.Password, db: c.Redis.DB, prefix: c.Redis.Prefix}
+}
+
+func (rd *Redis) dial() error {
+	conn, err := net.DialTimeout("tcp", rd.addr, redisTimeout)
+	if err != nil {
+		return err
+	}
+	rd.conn, rd.r = conn, bufio.NewReader(conn)
+	if rd.password != "" {
+		if _, err := rd.roundTrip("AUTH", rd.password); err != nil {
+			return err
+		}
+	}
+	if rd.db != 0 {
+		if _, err := rd.roundTrip("SELECT", strconv.Itoa(rd.db)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Do sends a command and returns its reply: a string, an int64, nil, or
+// a []interface{} of those. The connection is made again after anything
+// but an error reply.
+func (rd *Redis) Do(args ...string) (interface{}, error) {
+	rd.mu.Lock()
+	defer rd.mu.Unlock()
+	if rd.conn == nil {
+		if err := rd.dial(); err != nil {
+			rd.close()
+			return nil, err
+		}
+	}
+	reply, err := rd.roundTrip(args...)
+	if _, ok := err.(redisError); err != nil && !ok {
+		rd.close()
+	}
+	return reply, err
+}
+
+func (rd *Redis) close() {
+	if rd.conn != nil {
+		rd.conn.Close()
+		rd.conn = nil
+	}
+}
+
+func (rd *Redis) roundTrip(args ...string) (interface{}, error) {
+	rd.conn.SetDeadline(time.Now().Add(redisTimeout))
+	var b strings.Builder
+	fmt.Fprintf(&b, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(&b, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+	if _, err := io.WriteString(rd.conn, b.String()); err != nil {
+		return nil, err
+	}
+	return readRedisReply(rd.r)
+}
+
+func readRedisReply(r *bufio.Reader) (interface{}, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+	if line == "" {
+		return nil, errors.New("redis: empty reply")
+	}
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return nil, redisError(line[1:])
+	case ':':
+		return strconv.ParseInt(line[1:], 10, 64)
+	case '$':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		buf := make([]byte, n+2)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		return string(buf[:n]), nil
+	case '*':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		items := make([]interface{}, n)
+		for i := range items {
+			if items[i], err = readRedisReply(r); err != nil {
+				return nil, err
+			}
+		}
+		return items, nil
+	}
+	return nil, fmt.Errorf("redis: unexpected reply %q", line)
+}
+
+// Get returns a key's value, with ok false when it isn't set.
+func (rd *Redis) Get(key string) (value string, ok bool, err error) {
+	reply, err := rd.Do("GET", rd.prefix+key)
+	value, ok = reply.(string)
+	return value, ok, err
+}
+
+func (rd *Redis) Set(key, value string) error {
+	_, err := rd.Do("SET", rd.prefix+key, value)
+	return err
+}
+
+// Lock takes key for ttl unless someone else holds it.
+func (rd *Redis) Lock(key, owner string, ttl time.Duration) (bool, error) {
+	reply, err := rd.Do("SET", rd.prefix+key, owner, "NX", "PX", strconv.FormatInt(int64(ttl/time.Millisecond), 10))
+	return reply == "OK", err
+}
+
+// Incr adds by to a counter that expires after ttl.
+func (rd *Redis) Incr(key string, by int, ttl time.Duration) (int, error) {
+	reply, err := rd.Do("INCRBY", rd.prefix+key, strconv.Itoa(by))
+	if err != nil {
+		return 0, err
+	}
+	if _, err := rd.Do("PEXPIRE", rd.prefix+key, strconv.FormatInt(int64(ttl/time.Millisecond), 10)); err != nil {
+		return 0, err
+	}
+	n, _ := reply.(int64)
+	return int(n), nil
+}
+
+// Count reads a counter, 0 when it isn't set.
+func (rd *Redis) Count(key string) (int, error) {
+	value, ok, err := rd.Get(key)
+	if err != nil || !ok {
+		return 0, err
+	}
+	return strconv.Atoi(value)
+}
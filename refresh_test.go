@@ -0,0 +1,69 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/dghubble/go-twitter/twitter"
+)
+
+// failingSource fails every fetch with err.
+type failingSource struct {
+	err error
+}
+
+func (s failingSource) Name() string {
+	return "Failing"
+}
+
+func (s failingSource) Fetch() ([]twitter.Tweet, error) {
+	return nil, s.err
+}
+
+func (s failingSource) URL(tweet twitter.Tweet) string {
+	return ""
+}
+
+func TestFetchErrorShownForEverySource(t *testing.T) {
+	admin, err := throwawayCert()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, source := range []string{"twitter", "mastodon"} {
+		c := Config{Source: source}
+		c.Mastodon.Account = "someone@example.com"
+		c.Auth.Fingerprints = []string{certFingerprint(admin.Leaf)}
+		h, err := NewHandler(c, Deps{Source: failingSource{errors.New("timeline unavailable")}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := h.TweetCache.refresh(); err == nil {
+			t.Fatalf("%s: refresh didn't fail", source)
+		}
+		if _, _, body := do(t, h, "/admin", admin.Leaf); !strings.Contains(body, "Last fetch error: timeline unavailable\n") {
+			t.Errorf("%s: /admin misses the fetch error:\n%s", source, body)
+		}
+	}
+}
+
+func TestStopEndsRefreshes(t *testing.T) {
+	h, err := NewHandler(Config{}, Deps{Source: fixtureSource{fixtureTweets(2)}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tc := h.TweetCache
+	tc.Stop()
+	if err := tc.Refresh(); err != errStopped {
+		t.Errorf("refreshing once stopped: %v", err)
+	}
+	if len(tc.archive.Tweets) != 0 {
+		t.Errorf("%d tweets archived once stopped", len(tc.archive.Tweets))
+	}
+	done := make(chan struct{})
+	go func() {
+		tc.Refresher()
+		close(done)
+	}()
+	<-done
+}
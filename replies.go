@@ -0,0 +1,128 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/dghubble/go-twitter/twitter"
+	"github.com/makeworld-the-better-one/go-gemini"
+)
+
+type cachedReplies struct {
+	fetched time.Time
+	replies []v2Tweet
+}
+
+// RepliesCache keeps fetched conversations for a few minutes, since every
+// search counts against the API quota.
+type RepliesCache struct {
+	mu      sync.Mutex
+	entries map[int64]cachedReplies
+}
+
+func (rc *RepliesCache) Get(id int64, ttl time.Duration) ([]v2Tweet, bool) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	entry, ok := rc.entries[id]
+	if !ok || time.Since(entry.fetched) > ttl {
+		return nil, false
+	}
+	return entry.replies, true
+}
+
+func (rc *RepliesCache) Put(id int64, replies []v2Tweet, ttl time.Duration) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	if rc.entries == nil {
+		rc.entries = map[int64]cachedReplies{}
+	}
+	for key, entry := range rc.entries {
+		if time.Since(entry.fetched) > ttl {
+			delete(rc.entries, key)
+		}
+	}
+	rc.entries[id] = cachedReplies{time.Now(), replies}
+}
+
+// conversationID is the thread root, which is what v2 search knows the
+// whole conversation by.
+func (tc *TweetCache) conversationID(id int64) int64 {
+	thread, err := tc.Thread(id)
+	if err != nil || len(thread) == 0 {
+		return id
+	}
+	return tc.Tweets[thread[len(thread)-1]].ID
+}
+
+// fetchReplies searches the last week of tweets in a conversation, leaving
+// out the account's own ones.
+func (tc *TweetCache) fetchReplies(conversation int64) ([]v2Tweet, error) {
+	params := url.Values{}
+	params.Set("query", fmt.Sprintf("conversation_id:%d", conversation))
+	params.Set("max_results", "100")
+	v2TweetFields(params)
+
+	resp, err := tc.httpClient().Get(twitterAPIv2 + "tweets/search/recent?" + params.Encode())
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("twitter: reply search returned %s", resp.Status)
+	}
+
+	tweets, err := decodeV2Tweets(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	self := strconv.FormatInt(tc.Config.Twitter.UserID, 10)
+	var replies []v2Tweet
+	for _, t := range tweets {
+		if t.AuthorID == self || (tc.Config.Twitter.ScreenName != "" && t.ScreenName == tc.Config.Twitter.ScreenName) {
+			continue
+		}
+		replies = append(replies, t)
+	}
+	// Search returns newest first; conversations read better oldest first.
+	for i, j := 0, len(replies)-1; i < j; i, j = i+1, j-1 {
+		replies[i], replies[j] = replies[j], replies[i]
+	}
+	return replies, nil
+}
+
+func (rh *RequestHandler) formatReplies(tweet twitter.Tweet, replies []v2Tweet) string {
+	page := fmt.Sprintf("## Replies\n\n=> %s Back to the tweet\n", permalink(tweet))
+	if len(replies) == 0 {
+		return page + "\nNo replies from the last seven days."
+	}
+	for _, r := range replies {
+		page += rh.formatV2Tweet(r)
+	}
+	return page
+}
+
+func (rh *RequestHandler) showReplies(id int64) *gemini.Response {
+	pos, err := rh.TweetCache.FindByID(id)
+	if err != nil {
+		return NotFound("Tweet not found")
+	}
+	ttl := time.Duration(rh.Config.Replies.CacheMinutes) * time.Minute
+	replies, ok := rh.replies.Get(id, ttl)
+	if !ok {
+		replies, err = rh.TweetCache.fetchReplies(rh.TweetCache.conversationID(id))
+		if err != nil {
+			fmt.Println(err)
+			return TempFailure("Could not fetch replies")
+		}
+		rh.replies.Put(id, replies, ttl)
+	}
+	body := ioutil.NopCloser(bytes.NewBufferString(rh.wrapBody(rh.formatReplies(rh.TweetCache.Tweets[pos], replies))))
+	return Success(rh.meta(), body)
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/makeworld-the-better-one/go-gemini"
+)
+
+// Responses by what they mean, so handlers don't pick status codes by
+// number. Only Success has a body; the spec gives the others none.
+
+func Success(meta string, body io.ReadCloser) *gemini.Response {
+	return &gemini.Response{Status: gemini.StatusSuccess, Meta: meta, Body: body}
+}
+
+// Input asks for input, and asks again when an answer doesn't make sense.
+func Input(prompt string) *gemini.Response {
+	return &gemini.Response{Status: gemini.StatusInput, Meta: prompt}
+}
+
+func Redirect(target string) *gemini.Response {
+	return &gemini.Response{Status: gemini.StatusRedirectTemporary, Meta: target}
+}
+
+func PermanentRedirect(target string) *gemini.Response {
+	return &gemini.Response{Status: gemini.StatusRedirectPermanent, Meta: target}
+}
+
+// Unavailable is for when the whole server is down on purpose.
+func Unavailable(meta string) *gemini.Response {
+	return &gemini.Response{Status: gemini.StatusUnavailable, Meta: meta}
+}
+
+// TempFailure is for what may work if tried again later.
+func TempFailure(meta string) *gemini.Response {
+	return &gemini.Response{Status: gemini.StatusTemporaryFailure, Meta: meta}
+}
+
+// SlowDown tells the client how many seconds to wait, rounded up.
+func SlowDown(wait time.Duration) *gemini.Response {
+	return &gemini.Response{Status: gemini.StatusSlowDown, Meta: fmt.Sprint(int(wait/time.Second) + 1)}
+}
+
+func NotFound(meta string) *gemini.Response {
+	return &gemini.Response{Status: gemini.StatusNotFound, Meta: meta}
+}
+
+// BadRequest is for malformed requests, not for answers to a prompt.
+func BadRequest(meta string) *gemini.Response {
+	return &gemini.Response{Status: gemini.StatusBadRequest, Meta: meta}
+}
+
+func CertificateRequired(meta string) *gemini.Response {
+	return &gemini.Response{Status: gemini.StatusClientCertificateRequired, Meta: meta}
+}
+
+func CertificateNotAuthorised(meta string) *gemini.Response {
+	return &gemini.Response{Status: gemini.StatusCertificateNotAuthorised, Meta: meta}
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/makeworld-the-better-one/go-gemini"
+)
+
+// Params holds the values captured by :name segments of a route pattern.
+type Params map[string]string
+
+type HandlerFunc func(r Request, params Params) *gemini.Response
+
+// Func adapts a plain function to Handler.
+type Func func(r Request) *gemini.Response
+
+func (f Func) Handle(r Request) *gemini.Response {
+	return f(r)
+}
+
+type Middleware func(next Handler) Handler
+
+type route struct {
+	pattern  string
+	segments []string
+	handler  HandlerFunc
+	// The query is the answer to an input prompt
+	input bool
+}
+
+// canonical spells a path matched by the route the way the route does.
+func (rte *route) canonical(segments []string) string {
+	canonical := make([]string, len(segments))
+	for i, s := range rte.segments {
+		if strings.HasPrefix(s, ":") {
+			canonical[i] = segments[i]
+		} else {
+			canonical[i] = s
+		}
+	}
+	return "/" + strings.Join(canonical, "/")
+}
+
+// Router dispatches requests by path. Patterns are matched segment by
+// segment; a segment starting with ":" matches anything and is captured.
+// Paths that only match with other letter case or extra slashes, or that
+// end in .gmi or /index.gmi, are redirected to how the route spells them.
+type Router struct {
+	routes     []route
+	middleware []Middleware
+	aliases    map[string]string
+}
+
+func splitPath(path string) []string {
+	var segments []string
+	for _, s := range strings.Split(path, "/") {
+		if s != "" {
+			segments = append(segments, s)
+		}
+	}
+	return segments
+}
+
+func (rt *Router) Route(pattern string, handler HandlerFunc) {
+	rt.routes = append(rt.routes, route{pattern, splitPath(pattern), handler, false})
+}
+
+// Input registers a route reading its whole query as the answer to an
+// input prompt, so middleware leaves the query alone.
+func (rt *Router) Input(pattern string, handler HandlerFunc) {
+	rt.routes = append(rt.routes, route{pattern, splitPath(pattern), handler, true})
+}
+
+// TakesInput reports whether path is served by a route registered with
+// Input.
+func (rt *Router) TakesInput(path string) bool {
+	rte := rt.find(path)
+	return rte != nil && rte.input
+}
+
+// Alias permanently redirects from, with or without a trailing slash, to
+// another path or URL.
+func (rt *Router) Alias(from, to string) {
+	if rt.aliases == nil {
+		rt.aliases = map[string]string{}
+	}
+	rt.aliases[strings.TrimSuffix(from, "/")] = to
+}
+
+// Use registers middleware; the first registered is the outermost.
+func (rt *Router) Use(m Middleware) {
+	rt.middleware = append(rt.middleware, m)
+}
+
+// Pattern returns the route pattern path is served by, or "" if none
+// matches.
+func (rt *Router) Pattern(path string) string {
+	if rte := rt.find(path); rte != nil {
+		return rte.pattern
+	}
+	return ""
+}
+
+func (rt *Router) find(path string) *route {
+	segments := splitPath(path)
+	for n := range rt.routes {
+		rte := &rt.routes[n]
+		if len(rte.segments) != len(segments) {
+			continue
+		}
+		matched := true
+		for i, s := range rte.segments {
+			if !strings.HasPrefix(s, ":") && !strings.EqualFold(s, segments[i]) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return rte
+		}
+	}
+	return nil
+}
+
+// findNearMiss finds the route for a path written as the file it would
+// be published as.
+func (rt *Router) findNearMiss(path string) (*route, string) {
+	for _, suffix := range []string{"/index.gmi", ".gmi"} {
+		if trimmed := strings.TrimSuffix(path, suffix); trimmed != path {
+			if rte := rt.find(trimmed); rte != nil {
+				return rte, trimmed
+			}
+		}
+	}
+	return nil, ""
+}
+
+// withQuery carries a request's query over to where it is redirected.
+func withQuery(target string, u *url.URL) string {
+	if u.RawQuery != "" {
+		return target + "?" + u.RawQuery
+	}
+	return target
+}
+
+func (rt *Router) dispatch(r Request) *gemini.Response {
+	path := r.URL.Path
+	if to, ok := rt.aliases[strings.TrimSuffix(path, "/")]; ok && path != "" {
+		return PermanentRedirect(withQuery(to, r.URL))
+	}
+	rte := rt.find(path)
+	if rte == nil {
+		if rte, path = rt.findNearMiss(path); rte == nil {
+			return NotFound("Unknown location")
+		}
+	}
+	segments := splitPath(path)
+	if canonical := rte.canonical(segments); canonical != r.URL.Path && r.URL.Path != "" {
+		return Redirect(withQuery((&url.URL{Path: canonical}).EscapedPath(), r.URL))
+	}
+	params := Params{}
+	for i, s := range rte.segments {
+		if strings.HasPrefix(s, ":") {
+			params[s[1:]] = segments[i]
+		}
+	}
+	return rte.handler(r, params)
+}
+
+func (rt *Router) Handle(r Request) *gemini.Response {
+	var h Handler = Func(rt.dispatch)
+	for i := len(rt.middleware) - 1; i >= 0; i-- {
+		h = rt.middleware[i](h)
+	}
+	return h.Handle(r)
+}
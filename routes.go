@@ -0,0 +1,465 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/makeworld-the-better-one/go-gemini"
+)
+
+func NewRequestHandler(tc *TweetCache, c Config) *RequestHandler {
+	rh := &RequestHandler{TweetCache: tc, Config: c}
+	// Handlers not serving Gemini themselves have no certificate.
+	if c.Cert.CertFile != "" {
+		if fp, err := c.serverFingerprint(); err != nil {
+			fmt.Println(err)
+		} else {
+			rh.fingerprint = fp
+		}
+	}
+
+	if c.Middleware.AccessLog {
+		rh.router.Use(logRequests)
+	}
+	if c.Middleware.RateLimit > 0 {
+		limiter := &RateLimiter{PerMinute: c.Middleware.RateLimit}
+		rh.router.Use(limiter.Middleware)
+	}
+	rh.router.Use(rh.pauseForMaintenance)
+	protected := append([]string{"/admin"}, c.Auth.Paths...)
+	if c.Bookmarks.Private {
+		protected = append(protected, "/bookmarks")
+	}
+	rh.router.Use(requireCert(protected, c.Auth.Fingerprints))
+	rh.router.Use(rh.recordMetrics)
+	if c.experimental != nil {
+		ec := *c.experimental
+		// This handler already logs and limits the requests.
+		ec.Middleware.AccessLog = false
+		ec.Middleware.RateLimit = 0
+		rh.experimental = NewRequestHandler(tc, ec)
+	}
+	rh.router.Use(rh.previewExperimental)
+	if !c.forSource {
+		rh.router.Use(rh.applySourceUI)
+	}
+	rh.router.Use(rh.renderFormats)
+	rh.router.Use(rh.chooseLogo)
+	rh.router.Use(rh.stampPages)
+	rh.router.Use(rh.splitPages)
+	rh.router.Use(rh.cachePages)
+	rh.router.Use(rh.applyProfiles)
+
+	rh.router.Route("/", rh.handleFrontPage)
+	rh.router.Route("/timeline", rh.handleTimeline)
+	rh.router.Input("/timeline/dates", rh.handleTimelineDates)
+	rh.router.Route("/new", rh.handleNew)
+	rh.router.Route("/stats", rh.handleStats)
+	rh.router.Route("/tweet/:id", rh.handlePermalink)
+	if c.Replies.Enabled {
+		rh.router.Route("/tweet/:id/replies", rh.handleReplies)
+	}
+	rh.router.Route("/thread/:id", rh.handleThread)
+	if c.ReaderBookmarks.Enabled {
+		rh.router.Route("/tweet/:id/bookmark", rh.handleBookmarkTweet)
+		rh.router.Route("/my/bookmarks", rh.handleReaderBookmarks)
+		rh.router.Route("/my/bookmarks/remove/:id", rh.handleRemoveReaderBookmark)
+	}
+	if c.Comments.Enabled {
+		rh.commentLimit = &RateLimiter{PerMinute: c.Comments.RateLimit}
+		rh.router.Route("/tweet/:id/comments", rh.handleComments)
+		rh.router.Input("/tweet/:id/comments/add", rh.handleAddComment)
+		rh.router.Route("/admin/comments", rh.handleAdminComments)
+		rh.router.Route("/admin/comments/remove/:id", rh.handleRemoveComment)
+		rh.router.Route("/admin/comments/approve/:id", rh.handleApproveComment)
+		rh.router.Route("/admin/comments/ban/:id", rh.handleBanCommenter)
+		rh.router.Route("/admin/comments/unban/:fingerprint", rh.handleUnbanCommenter)
+	}
+	if c.Media.Mode == "proxy" {
+		rh.router.Route("/media/:id", rh.handleMediaRedirect)
+		rh.router.Route("/media/:id/full", rh.handleMedia)
+		rh.router.Route("/media/:id/thumb", rh.handleThumbnail)
+	}
+	if c.Proxy.Enabled {
+		limiter := &RateLimiter{PerMinute: c.Proxy.RateLimit}
+		rh.router.Route("/u/:name", limiter.Limit(rh.handleProxy))
+	}
+	rh.router.Route("/digest", rh.handleDigestIndex)
+	rh.router.Route("/digest/:date", rh.handleDigestDay)
+	if c.Snapshots.Enabled {
+		rh.router.Route("/followers", rh.handleFollowers)
+		rh.router.Route("/following", rh.handleFollowing)
+	}
+	if c.Bookmarks.Enabled {
+		rh.router.Route("/bookmarks", rh.handleBookmarks)
+	}
+	rh.router.Route("/tags", rh.handleTags)
+	rh.router.Input("/search", rh.handleSearch)
+	rh.router.Route("/search/:query", rh.handleSavedSearch)
+	rh.router.Route("/index-all", rh.handleIndexAll)
+	rh.router.Route("/index-all/:page", rh.handleIndexPage)
+	for path := range bundles {
+		rh.router.Route(path, rh.handleBundle)
+	}
+	rh.router.Route("/api/timeline.json", rh.handleAPITimeline)
+	rh.router.Route("/api/tweet/:id", rh.handleAPITweet)
+	rh.router.Route("/server-info", rh.handleServerInfo)
+	rh.router.Route("/admin", rh.handleAdmin)
+	rh.router.Route("/admin/refresh", rh.handleRefresh)
+	rh.router.Route("/admin/changes", rh.handleChanges)
+	rh.router.Route("/admin/sources", rh.handleSources)
+	rh.router.Input("/admin/sources/add", rh.handleAddSource)
+	rh.router.Route("/admin/sources/remove/:name", rh.handleRemoveSource)
+	rh.router.Route("/admin/sources/pause/:name", rh.handlePauseSource)
+	rh.router.Route("/admin/sources/resume/:name", rh.handleResumeSource)
+	rh.router.Route("/admin/maintenance", rh.handleMaintenance)
+	rh.router.Route("/admin/announcement", rh.handleAdminAnnouncement)
+	rh.router.Input("/admin/announcement/set", rh.handleSetAnnouncement)
+	rh.router.Route("/admin/announcement/clear", rh.handleClearAnnouncement)
+	rh.router.Input("/select_tweet", rh.handleSelectTweet)
+	rh.router.Input("/select_tweet/:anchor", rh.handleSelectTweet)
+	for from, to := range c.UI.Aliases {
+		rh.router.Alias(from, to)
+	}
+	return rh
+}
+
+func (rh *RequestHandler) Handle(r Request) *gemini.Response {
+	return rh.router.Handle(r)
+}
+
+func (rh *RequestHandler) handleFrontPage(r Request, p Params) *gemini.Response {
+	target := ""
+	switch rh.Config.UI.FrontPage {
+	case "timeline":
+		target = "/timeline"
+	case "latest":
+		if len(rh.TweetCache.Tweets) > 0 {
+			target = fmt.Sprintf("/tweet/%d", rh.TweetCache.Tweets[0].ID)
+		}
+	}
+	if target == "" {
+		return rh.showFrontPage()
+	}
+	if r.URL.RawQuery != "" {
+		target += "?" + r.URL.RawQuery
+	}
+	return Redirect(target)
+}
+
+func (rh *RequestHandler) handleTimeline(r Request, p Params) *gemini.Response {
+	dr, err := parseTimelineRange(*r.URL)
+	if err != nil {
+		return BadRequest("Bad date range, expected from=YYYY-MM-DD&to=YYYY-MM-DD")
+	}
+	order, err := parseOrder(*r.URL, rh.Config.UI.Order)
+	if err != nil {
+		return BadRequest(err.Error())
+	}
+	var seen int64
+	if reader := rh.reader(r); reader != "" && dr.IsZero() {
+		seen = rh.seenBy(reader)
+	}
+	return rh.showTimeline(dr, parseLanguages(*r.URL, rh.Config.UI.Languages), order, seen)
+}
+
+const datesPrompt = "Enter a date range, e.g. 2023-01-01..2023-06-30"
+
+// handleTimelineDates asks for a date range to show the timeline of.
+func (rh *RequestHandler) handleTimelineDates(r Request, p Params) *gemini.Response {
+	if _, err := parseTimelineRange(*r.URL); err != nil || r.URL.RawQuery == "" || strings.Contains(r.URL.RawQuery, "=") {
+		return Input(datesPrompt)
+	}
+	return Redirect("/timeline?" + r.URL.RawQuery)
+}
+
+func (rh *RequestHandler) handleNew(r Request, p Params) *gemini.Response {
+	var since int64
+	if param := r.URL.Query().Get("since"); param != "" {
+		var err error
+		if since, err = strconv.ParseInt(param, 10, 64); err != nil || since <= 0 {
+			return BadRequest("since must be a tweet ID")
+		}
+	}
+	order, err := parseOrder(*r.URL, rh.Config.UI.Order)
+	if err != nil {
+		return BadRequest(err.Error())
+	}
+	if reader := rh.reader(r); reader != "" && since == 0 {
+		since = rh.seenBy(reader)
+	}
+	return rh.showNew(since, order)
+}
+
+func (rh *RequestHandler) handleStats(r Request, p Params) *gemini.Response {
+	return rh.showStats()
+}
+
+func (rh *RequestHandler) handlePermalink(r Request, p Params) *gemini.Response {
+	id, err := strconv.ParseInt(p["id"], 10, 64)
+	if err != nil {
+		return NotFound("Unknown location")
+	}
+	return rh.showPermalink(id)
+}
+
+func (rh *RequestHandler) handleReplies(r Request, p Params) *gemini.Response {
+	id, err := strconv.ParseInt(p["id"], 10, 64)
+	if err != nil {
+		return NotFound("Unknown location")
+	}
+	return rh.showReplies(id)
+}
+
+func (rh *RequestHandler) handleBookmarkTweet(r Request, p Params) *gemini.Response {
+	id, err := strconv.ParseInt(p["id"], 10, 64)
+	if err != nil {
+		return NotFound("Unknown location")
+	}
+	return rh.bookmarkTweet(r.Fingerprint(), id)
+}
+
+func (rh *RequestHandler) handleReaderBookmarks(r Request, p Params) *gemini.Response {
+	return rh.showReaderBookmarks(r.Fingerprint())
+}
+
+func (rh *RequestHandler) handleRemoveReaderBookmark(r Request, p Params) *gemini.Response {
+	id, err := strconv.ParseInt(p["id"], 10, 64)
+	if err != nil {
+		return NotFound("Unknown location")
+	}
+	return rh.removeReaderBookmark(r.Fingerprint(), id)
+}
+
+func (rh *RequestHandler) handleComments(r Request, p Params) *gemini.Response {
+	id, err := strconv.ParseInt(p["id"], 10, 64)
+	if err != nil {
+		return NotFound("Unknown location")
+	}
+	return rh.showComments(id)
+}
+
+func (rh *RequestHandler) handleAddComment(r Request, p Params) *gemini.Response {
+	id, err := strconv.ParseInt(p["id"], 10, 64)
+	if err != nil {
+		return NotFound("Unknown location")
+	}
+	if r.Fingerprint() == "" {
+		return CertificateRequired("Comments are signed with a client certificate")
+	}
+	if rh.TweetCache.comments.IsBanned(r.Fingerprint()) {
+		return CertificateNotAuthorised("This certificate may not comment")
+	}
+	input, err := readInput(*r.URL)
+	if err != nil || input == "" {
+		return Input(commentPrompt)
+	}
+	return rh.addComment(r, id, input)
+}
+
+func (rh *RequestHandler) handleAdminComments(r Request, p Params) *gemini.Response {
+	return rh.showAdminComments()
+}
+
+func (rh *RequestHandler) handleRemoveComment(r Request, p Params) *gemini.Response {
+	id, err := strconv.ParseInt(p["id"], 10, 64)
+	if err != nil {
+		return NotFound("Unknown location")
+	}
+	return rh.removeComment(id)
+}
+
+func (rh *RequestHandler) handleApproveComment(r Request, p Params) *gemini.Response {
+	id, err := strconv.ParseInt(p["id"], 10, 64)
+	if err != nil {
+		return NotFound("Unknown location")
+	}
+	return rh.approveComment(id)
+}
+
+func (rh *RequestHandler) handleBanCommenter(r Request, p Params) *gemini.Response {
+	id, err := strconv.ParseInt(p["id"], 10, 64)
+	if err != nil {
+		return NotFound("Unknown location")
+	}
+	return rh.banCommenter(id)
+}
+
+func (rh *RequestHandler) handleUnbanCommenter(r Request, p Params) *gemini.Response {
+	return rh.unbanCommenter(p["fingerprint"])
+}
+
+func (rh *RequestHandler) handleThread(r Request, p Params) *gemini.Response {
+	id, err := strconv.ParseInt(p["id"], 10, 64)
+	if err != nil {
+		return NotFound("Unknown location")
+	}
+	order, err := parseOrder(*r.URL, rh.Config.UI.ThreadOrder)
+	if err != nil {
+		return BadRequest(err.Error())
+	}
+	return rh.showThread(id, order)
+}
+
+func (rh *RequestHandler) handleDigestIndex(r Request, p Params) *gemini.Response {
+	return rh.showDigestIndex()
+}
+
+func (rh *RequestHandler) handleDigestDay(r Request, p Params) *gemini.Response {
+	day, err := time.Parse(dateLayout, p["date"])
+	if err != nil {
+		return NotFound("Unknown location")
+	}
+	return rh.showDigestDay(day)
+}
+
+func (rh *RequestHandler) handleFollowers(r Request, p Params) *gemini.Response {
+	return rh.showFollowers()
+}
+
+func (rh *RequestHandler) handleFollowing(r Request, p Params) *gemini.Response {
+	return rh.showFollowing()
+}
+
+func (rh *RequestHandler) handleBookmarks(r Request, p Params) *gemini.Response {
+	return rh.showBookmarks()
+}
+
+func (rh *RequestHandler) handleTags(r Request, p Params) *gemini.Response {
+	return rh.showTags()
+}
+
+const searchPrompt = "Search tweets (\"exact phrase\", prefix*)"
+
+func (rh *RequestHandler) handleSearch(r Request, p Params) *gemini.Response {
+	input, err := readInput(*r.URL)
+	if err != nil || input == "" {
+		return Input(searchPrompt)
+	}
+	query, dr, err := parseSearchQuery(input)
+	if err != nil {
+		return Input("Search tweets (limit dates with from:YYYY-MM-DD to:YYYY-MM-DD)")
+	}
+	return rh.showSearch(query, dr)
+}
+
+func (rh *RequestHandler) handleSavedSearch(r Request, p Params) *gemini.Response {
+	query, dr, err := parseSearchQuery(p["query"])
+	if err != nil || query == "" {
+		return NotFound("Unknown location")
+	}
+	return rh.showSavedSearch(query, dr)
+}
+
+func (rh *RequestHandler) handleServerInfo(r Request, p Params) *gemini.Response {
+	return rh.showServerInfo()
+}
+
+func (rh *RequestHandler) handleAdmin(r Request, p Params) *gemini.Response {
+	return rh.showAdmin()
+}
+
+func (rh *RequestHandler) handleRefresh(r Request, p Params) *gemini.Response {
+	return rh.refreshNow()
+}
+
+func (rh *RequestHandler) handleAPITimeline(r Request, p Params) *gemini.Response {
+	dr, err := parseTimelineRange(*r.URL)
+	if err != nil {
+		return BadRequest(err.Error())
+	}
+	return rh.showAPITimeline(dr)
+}
+
+func (rh *RequestHandler) handleAPITweet(r Request, p Params) *gemini.Response {
+	name := p["id"]
+	if !strings.HasSuffix(name, ".json") {
+		return NotFound("Unknown location")
+	}
+	id, err := strconv.ParseInt(strings.TrimSuffix(name, ".json"), 10, 64)
+	if err != nil {
+		return NotFound("Unknown location")
+	}
+	return rh.showAPITweet(id)
+}
+
+func (rh *RequestHandler) handleMedia(r Request, p Params) *gemini.Response {
+	id, err := strconv.ParseInt(p["id"], 10, 64)
+	if err != nil {
+		return NotFound("Unknown location")
+	}
+	return rh.showMedia(id)
+}
+
+// handleMediaRedirect keeps links from before thumbnails working.
+func (rh *RequestHandler) handleMediaRedirect(r Request, p Params) *gemini.Response {
+	return PermanentRedirect("/media/" + p["id"] + "/full")
+}
+
+func (rh *RequestHandler) handleThumbnail(r Request, p Params) *gemini.Response {
+	id, err := strconv.ParseInt(p["id"], 10, 64)
+	if err != nil {
+		return NotFound("Unknown location")
+	}
+	return rh.showThumbnail(id)
+}
+
+func (rh *RequestHandler) handleProxy(r Request, p Params) *gemini.Response {
+	return rh.showProxy(p["name"])
+}
+
+func (rh *RequestHandler) handleSources(r Request, p Params) *gemini.Response {
+	return rh.showSources()
+}
+
+const sourcePrompt = "Account to mirror, e.g. user@example.social, https://t.me/channel or https://example.org/feed.xml"
+
+func (rh *RequestHandler) handleAddSource(r Request, p Params) *gemini.Response {
+	input, err := readInput(*r.URL)
+	if err != nil || input == "" {
+		return Input(sourcePrompt)
+	}
+	return rh.addSource(input)
+}
+
+func (rh *RequestHandler) handleRemoveSource(r Request, p Params) *gemini.Response {
+	return rh.removeSource(p["name"])
+}
+
+func (rh *RequestHandler) handlePauseSource(r Request, p Params) *gemini.Response {
+	return rh.pauseSource(p["name"], true)
+}
+
+func (rh *RequestHandler) handleResumeSource(r Request, p Params) *gemini.Response {
+	return rh.pauseSource(p["name"], false)
+}
+
+func (rh *RequestHandler) handleMaintenance(r Request, p Params) *gemini.Response {
+	return rh.toggleMaintenance()
+}
+
+func (rh *RequestHandler) handleAdminAnnouncement(r Request, p Params) *gemini.Response {
+	return rh.showAdminAnnouncement()
+}
+
+func (rh *RequestHandler) handleSetAnnouncement(r Request, p Params) *gemini.Response {
+	input, err := readInput(*r.URL)
+	if err != nil || input == "" {
+		return Input(announcementPrompt)
+	}
+	return rh.setAnnouncement(input)
+}
+
+func (rh *RequestHandler) handleClearAnnouncement(r Request, p Params) *gemini.Response {
+	return rh.clearAnnouncement()
+}
+
+func (rh *RequestHandler) handleChanges(r Request, p Params) *gemini.Response {
+	return rh.showChanges()
+}
+
+func (rh *RequestHandler) handleSelectTweet(r Request, p Params) *gemini.Response {
+	return rh.selectTweet(*r.URL, p["anchor"])
+}
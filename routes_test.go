@@ -0,0 +1,324 @@
+package main
+
+import (
+	"crypto/x509"
+	"strings"
+	"testing"
+)
+
+func newFixtureHandler(t *testing.T, c Config, n int) *RequestHandler {
+	t.Helper()
+	tweets := fixtureTweets(n)
+	h, err := NewHandler(c, Deps{Source: fixtureSource{tweets}, Tweets: tweets})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return h
+}
+
+// do sends rawURL to h, returning the status, meta and body.
+func do(t *testing.T, h Handler, rawURL string, certs ...*x509.Certificate) (int, string, string) {
+	t.Helper()
+	r, err := NewRequest(rawURL, certs...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp := h.Handle(r)
+	body, err := ReadBody(resp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp.Status, resp.Meta, body
+}
+
+func TestRoutes(t *testing.T) {
+	h := newFixtureHandler(t, Config{}, 30)
+	tests := []struct {
+		url    string
+		status int
+		// The meta for other statuses than 20, else in the body
+		want string
+	}{
+		{"/", 20, "Tweet 30 about #topic0"},
+		{"/timeline", 20, "Tweet 27 about #topic0"},
+		{"/timeline?from=2020-06-30&to=2020-06-30", 20, "Tweet 28 about #topic2"},
+		{"/timeline?2020-13-01..2020-06-30", 59, "Bad date range, expected from=YYYY-MM-DD&to=YYYY-MM-DD"},
+		{"/timeline/dates", 10, datesPrompt},
+		{"/timeline/dates?2020-13-01..2020-06-30", 10, datesPrompt},
+		{"/timeline/dates?2020-06-30..2020-06-30", 30, "/timeline?2020-06-30..2020-06-30"},
+		{"/TIMELINE", 30, "/timeline"},
+		{"/tweet/1030", 20, "Tweet 30 about #topic0"},
+		{"/tweet/1", 51, "Tweet not found"},
+		{"/tweet/x", 51, "Unknown location"},
+		{"/thread/1026", 20, "## Participants"},
+		{"/tags", 20, "## Hashtags"},
+		{"/stats", 20, "Tweets archived: 30"},
+		{"/search", 10, searchPrompt},
+		{"/search?%23topic1", 20, "Tweet 29 about »#topic1«"},
+		{"/admin", 60, "Client certificate required"},
+		{"/nope", 51, "Unknown location"},
+
+		// Parameters for the middleware are whole keys, and never taken
+		// from the answer to a prompt.
+		{"/timeline?fmt=b", 59, "fmt must be one of gmi, txt"},
+		{"/timeline?logo=x", 59, "logo must be alt"},
+		{"/timeline?part=two", 59, "Bad part number"},
+		{"/timeline?experimental=2", 59, "experimental must be 0 or 1"},
+		{"/timeline?catalogo=1", 20, "Tweet 30 about #topic0"},
+		{"/search?catalogo=1", 20, `No tweets found for "catalogo=1".`},
+		{"/search?a&fmt=b", 20, `No tweets found for "a&fmt=b".`},
+		{"/search?part=two", 20, `No tweets found for "part=two".`},
+		{"/search?x=experimental=1", 20, `No tweets found for "x=experimental=1".`},
+	}
+	for _, tt := range tests {
+		status, meta, body := do(t, h, tt.url)
+		if status != tt.status {
+			t.Errorf("%s: status %d %q, want %d", tt.url, status, meta, tt.status)
+		} else if status != 20 && meta != tt.want {
+			t.Errorf("%s: meta %q, want %q", tt.url, meta, tt.want)
+		} else if status == 20 && !strings.Contains(body, tt.want) {
+			t.Errorf("%s: body misses %q:\n%s", tt.url, tt.want, body)
+		}
+	}
+}
+
+func TestAdminNeedsAuthorisedCertificate(t *testing.T) {
+	admin, err := throwawayCert()
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := throwawayCert()
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := Config{}
+	c.Auth.Fingerprints = []string{certFingerprint(admin.Leaf)}
+	h := newFixtureHandler(t, c, 5)
+
+	if status, meta, _ := do(t, h, "/admin", other.Leaf); status != 61 {
+		t.Errorf("other certificate: status %d %q, want 61", status, meta)
+	}
+	if status, meta, body := do(t, h, "/admin", admin.Leaf); status != 20 || !strings.Contains(body, "# Admin") {
+		t.Errorf("admin certificate: status %d %q:\n%s", status, meta, body)
+	}
+}
+
+func TestTextFormatsStripControlCharacters(t *testing.T) {
+	var c Config
+	c.UI.ANSI = true
+	h := newFixtureHandler(t, c, 3)
+	h.TweetCache.Tweets[0].Text = "Tweet\x1b]0;owned\x07 with\u009b2J escapes\tand a tab"
+	for _, format := range []string{"ansi", "txt"} {
+		status, meta, body := do(t, h, "/tweet/1003?fmt="+format)
+		if status != 20 {
+			t.Fatalf("%s: %d %q", format, status, meta)
+		}
+		if !strings.Contains(body, "Tweet]0;owned with2J escapes\tand a tab") {
+			t.Errorf("%s: post text missing:\n%q", format, body)
+		}
+		if strings.ContainsAny(body, "\x07\u009b") || (format == "txt" && strings.Contains(body, "\x1b")) {
+			t.Errorf("%s: control characters left in:\n%q", format, body)
+		}
+		if format == "ansi" && strings.Contains(body, "\x1b]") {
+			t.Errorf("ansi: escape from the post left in:\n%q", body)
+		}
+	}
+}
+
+func TestPartLinksKeepQuery(t *testing.T) {
+	var c Config
+	c.UI.MaxPageBytes = 1000
+	h := newFixtureHandler(t, c, 30)
+	status, meta, body := do(t, h, "/timeline?fmt=txt")
+	if status != 20 {
+		t.Fatalf("%d %q", status, meta)
+	}
+	if !strings.Contains(body, "</timeline?fmt=txt&part=2>") {
+		t.Errorf("no link to part 2 in text:\n%s", body)
+	}
+	_, _, body = do(t, h, "/timeline?part=2&fmt=txt")
+	if !strings.Contains(body, "Previous part </timeline?fmt=txt>") {
+		t.Errorf("part 2 links lose the format:\n%s", body)
+	}
+}
+
+func TestComments(t *testing.T) {
+	reader, err := throwawayCert()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var c Config
+	c.Comments.Enabled = true
+	c.Comments.RateLimit = 1
+	h := newFixtureHandler(t, c, 3)
+
+	if status, _, _ := do(t, h, "/tweet/1003/comments/add?Hi"); status != 60 {
+		t.Errorf("commenting without a certificate: %d", status)
+	}
+	if status, meta, _ := do(t, h, "/tweet/1003/comments/add", reader.Leaf); status != 10 || meta != commentPrompt {
+		t.Errorf("commenting without text: %d %q", status, meta)
+	}
+	if status, meta, _ := do(t, h, "/tweet/1003/comments/add?Nice%20  tweet", reader.Leaf); status != 30 || meta != "/tweet/1003/comments" {
+		t.Fatalf("commenting: %d %q", status, meta)
+	}
+	if _, _, body := do(t, h, "/tweet/1003/comments"); !strings.Contains(body, "\n\n> Nice tweet") {
+		t.Errorf("comment not shown:\n%s", body)
+	}
+	if _, _, body := do(t, h, "/tweet/1003"); !strings.Contains(body, "=> /tweet/1003/comments Comments (1)\n") {
+		t.Errorf("tweet page doesn't count the comment:\n%s", body)
+	}
+	if status, meta, _ := do(t, h, "/tweet/1003/comments/add?Again", reader.Leaf); status != 44 {
+		t.Errorf("commenting over comments.rateLimit: %d %q", status, meta)
+	}
+	if status, meta, _ := do(t, h, "/tweet/1/comments/add?Hi", reader.Leaf); status != 51 {
+		t.Errorf("commenting on a tweet that isn't mirrored: %d %q", status, meta)
+	}
+}
+
+func TestCommentModeration(t *testing.T) {
+	admin, err := throwawayCert()
+	if err != nil {
+		t.Fatal(err)
+	}
+	reader, err := throwawayCert()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var c Config
+	c.Comments.Enabled = true
+	c.Comments.Moderate = true
+	c.Auth.Fingerprints = []string{certFingerprint(admin.Leaf)}
+	h := newFixtureHandler(t, c, 3)
+
+	if status, meta, _ := do(t, h, "/tweet/1003/comments/add?Buy%20now", reader.Leaf); status != 30 {
+		t.Fatalf("commenting: %d %q", status, meta)
+	}
+	if _, _, body := do(t, h, "/tweet/1003/comments"); strings.Contains(body, "Buy now") {
+		t.Errorf("comment shown before approval:\n%s", body)
+	}
+	if status, _, _ := do(t, h, "/admin/comments", reader.Leaf); status != 61 {
+		t.Errorf("a reader moderating: %d", status)
+	}
+	if _, _, body := do(t, h, "/admin/comments", admin.Leaf); !strings.Contains(body, "## Waiting for approval\n\n\n### ") || !strings.Contains(body, "=> /admin/comments/approve/1 Approve\n") {
+		t.Errorf("comment not waiting for approval:\n%s", body)
+	}
+	if status, meta, _ := do(t, h, "/admin/comments/approve/1", admin.Leaf); status != 30 {
+		t.Fatalf("approving: %d %q", status, meta)
+	}
+	if _, _, body := do(t, h, "/tweet/1003/comments"); !strings.Contains(body, "> Buy now") {
+		t.Errorf("approved comment not shown:\n%s", body)
+	}
+
+	if status, meta, _ := do(t, h, "/admin/comments/ban/1", admin.Leaf); status != 30 {
+		t.Fatalf("banning: %d %q", status, meta)
+	}
+	if _, _, body := do(t, h, "/tweet/1003/comments"); strings.Contains(body, "Buy now") {
+		t.Errorf("banned commenter's comment still shown:\n%s", body)
+	}
+	if status, _, _ := do(t, h, "/tweet/1003/comments/add?Again", reader.Leaf); status != 61 {
+		t.Errorf("banned commenter commenting: %d", status)
+	}
+	if status, meta, _ := do(t, h, "/admin/comments/unban/"+certFingerprint(reader.Leaf), admin.Leaf); status != 30 {
+		t.Fatalf("unbanning: %d %q", status, meta)
+	}
+	if status, _, _ := do(t, h, "/tweet/1003/comments/add?Sorry", reader.Leaf); status != 30 {
+		t.Errorf("unbanned commenter commenting: %d", status)
+	}
+}
+
+func TestReaderState(t *testing.T) {
+	reader, err := throwawayCert()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var c Config
+	c.ReadState.Enabled = true
+	h := newFixtureHandler(t, c, 3)
+
+	if _, _, body := do(t, h, "/timeline", reader.Leaf); strings.Contains(body, "since your last visit") {
+		t.Errorf("unread tweets on the first visit:\n%s", body)
+	}
+	h.TweetCache.merge(fixtureTweets(5))
+	_, _, body := do(t, h, "/timeline", reader.Leaf)
+	if !strings.Contains(body, "2 unread tweets since your last visit\n=> /new?since=1003 All of them") {
+		t.Errorf("new tweets not marked unread:\n%s", body)
+	}
+	if strings.Count(body, "🆕 ") != 2 {
+		t.Errorf("%d tweets marked unread, want 2:\n%s", strings.Count(body, "🆕 "), body)
+	}
+	if _, _, body := do(t, h, "/timeline", reader.Leaf); strings.Contains(body, "since your last visit") {
+		t.Errorf("tweets still unread once seen:\n%s", body)
+	}
+	if _, _, body := do(t, h, "/timeline"); strings.Contains(body, "since your last visit") {
+		t.Errorf("unread tweets without a certificate:\n%s", body)
+	}
+}
+
+func TestReaderBookmarks(t *testing.T) {
+	reader, err := throwawayCert()
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := throwawayCert()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var c Config
+	c.ReaderBookmarks.Enabled = true
+	h := newFixtureHandler(t, c, 3)
+
+	if status, _, _ := do(t, h, "/tweet/1002/bookmark"); status != 60 {
+		t.Errorf("bookmarking without a certificate: %d", status)
+	}
+	for _, id := range []string{"1002", "1003"} {
+		if status, meta, _ := do(t, h, "/tweet/"+id+"/bookmark", reader.Leaf); status != 30 || meta != "/my/bookmarks" {
+			t.Fatalf("bookmarking %s: %d %q", id, status, meta)
+		}
+	}
+	_, _, body := do(t, h, "/my/bookmarks", reader.Leaf)
+	if i, j := strings.Index(body, "Tweet 3 about"), strings.Index(body, "Tweet 2 about"); i < 0 || j < i {
+		t.Errorf("bookmarks missing or not newest first:\n%s", body)
+	}
+	if _, _, body := do(t, h, "/my/bookmarks", other.Leaf); !strings.Contains(body, "Nothing bookmarked yet.") {
+		t.Errorf("another reader sees the bookmarks:\n%s", body)
+	}
+	if status, meta, _ := do(t, h, "/my/bookmarks/remove/1002", reader.Leaf); status != 30 {
+		t.Fatalf("removing: %d %q", status, meta)
+	}
+	if _, _, body := do(t, h, "/my/bookmarks", reader.Leaf); strings.Contains(body, "Tweet 2 about") || !strings.Contains(body, "Tweet 3 about") {
+		t.Errorf("removing took the wrong bookmark:\n%s", body)
+	}
+	if status, _, _ := do(t, h, "/my/bookmarks/remove/1002", reader.Leaf); status != 51 {
+		t.Errorf("removing twice: %d", status)
+	}
+}
+
+func TestMaintenance(t *testing.T) {
+	admin, err := throwawayCert()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var c Config
+	c.Maintenance.Enabled = true
+	c.Maintenance.RetryMinutes = 10
+	c.Auth.Fingerprints = []string{certFingerprint(admin.Leaf)}
+	h := newFixtureHandler(t, c, 3)
+
+	for _, url := range []string{"/", "/timeline", "/tweet/1003", "/nope"} {
+		if status, meta, _ := do(t, h, url); status != 41 || meta != "Mirror temporarily paused for maintenance, try again in about 10 minutes" {
+			t.Errorf("%s: %d %q", url, status, meta)
+		}
+	}
+	if status, _, _ := do(t, h, "/admin"); status != 60 {
+		t.Errorf("/admin needs a certificate while paused: %d", status)
+	}
+	if _, _, body := do(t, h, "/admin", admin.Leaf); !strings.Contains(body, "=> /admin/maintenance Resume the mirror\n") {
+		t.Errorf("/admin can't resume the mirror:\n%s", body)
+	}
+	if status, meta, _ := do(t, h, "/admin/maintenance", admin.Leaf); status != 30 {
+		t.Fatalf("resuming: %d %q", status, meta)
+	}
+	if status, meta, _ := do(t, h, "/"); status != 20 {
+		t.Errorf("/ once resumed: %d %q", status, meta)
+	}
+}
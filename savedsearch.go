@@ -0,0 +1,52 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"strings"
+
+	"github.com/dghubble/go-twitter/twitter"
+	"github.com/makeworld-the-better-one/go-gemini"
+)
+
+// Length of the entry titles on saved search pages, in runes.
+const entryTitleLength = 80
+
+func savedSearchLink(query string) string {
+	return "/search/" + url.PathEscape(query)
+}
+
+// entryTitle squeezes a tweet onto one short line.
+func entryTitle(tweet twitter.Tweet) string {
+	title := []rune(strings.Join(strings.Fields(expandLinks(tweet)), " "))
+	if len(title) > entryTitleLength {
+		return string(title[:entryTitleLength-1]) + "…"
+	}
+	return string(title)
+}
+
+// formatSavedSearch renders a gmisub compatible feed of the tweets
+// matching query, so readers can follow a search.
+func (rh *RequestHandler) formatSavedSearch(query string, dr DateRange) string {
+	var page strings.Builder
+	fmt.Fprintf(&page, "# Tweets matching \"%s\"\n\n", query)
+	found := rh.search.Search(rh.TweetCache, query, dr.Contains)
+	if len(found) == 0 {
+		page.WriteString("No tweets yet.\n")
+	}
+	for _, tweet := range found {
+		date := ""
+		if t, err := tweet.CreatedAtTime(); err == nil {
+			date = t.Format(dateLayout) + " "
+		}
+		fmt.Fprintf(&page, "=> %s %s%s\n", permalink(tweet), date, rh.Config.convertEmoji(entryTitle(tweet)))
+	}
+	return page.String()
+}
+
+func (rh *RequestHandler) showSavedSearch(query string, dr DateRange) *gemini.Response {
+	body := ioutil.NopCloser(bytes.NewBufferString(rh.wrapBody(rh.formatSavedSearch(query, dr))))
+	return Success(rh.meta(), body)
+}
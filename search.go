@@ -0,0 +1,308 @@
+package main
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/dghubble/go-twitter/twitter"
+)
+
+// Results shown per search.
+const maxSearchResults = 20
+
+// posting is where a term is found in a tweet, by tweet ID.
+type posting struct {
+	doc       int64
+	positions []int
+}
+
+// indexedText is what a tweet said when it was indexed, to notice edits
+// without expanding its links again.
+type indexedText struct {
+	text, fullText string
+}
+
+// SearchIndex is an inverted index over the whole archive. When the tweet
+// cache moves to a new generation, only tweets that are new, edited or
+// removed since are indexed again.
+type SearchIndex struct {
+	mu         sync.Mutex
+	built      bool
+	generation uint64
+	// Newest first, as last indexed
+	order   []twitter.Tweet
+	tweets  map[int64]twitter.Tweet
+	indexed map[int64]indexedText
+	terms   map[string][]posting
+	// Every term, sorted, for prefix lookups
+	sorted []string
+}
+
+type token struct {
+	term string
+	pos  int
+	// Byte offsets of the word in the text, sigil included
+	start, end int
+}
+
+// tokenize lowercases text and splits it into words. When indexing,
+// hashtags and mentions are stored both with and without their # or @, so
+// searching "#go" finds only the hashtag while "go" finds both.
+func tokenize(text string, indexing bool) []token {
+	var tokens []token
+	var word []rune
+	var sigil rune
+	pos, start := 0, 0
+	flush := func(end int) {
+		if len(word) > 0 {
+			if sigil != 0 {
+				tokens = append(tokens, token{string(sigil) + string(word), pos, start, end})
+			}
+			if sigil == 0 || indexing {
+				tokens = append(tokens, token{string(word), pos, start, end})
+			}
+			pos += 1
+		}
+		word, sigil = nil, 0
+	}
+	for i, r := range text {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_':
+			if len(word) == 0 && sigil == 0 {
+				start = i
+			}
+			word = append(word, unicode.ToLower(r))
+		case (r == '#' || r == '@') && len(word) == 0:
+			sigil, start = r, i
+		default:
+			flush(i)
+		}
+	}
+	flush(len(text))
+	return tokens
+}
+
+// update brings the index in line with tweets, reindexing only what
+// changed since the last update.
+func (ix *SearchIndex) update(tweets []twitter.Tweet) {
+	if ix.tweets == nil {
+		ix.tweets = map[int64]twitter.Tweet{}
+		ix.indexed = map[int64]indexedText{}
+		ix.terms = map[string][]posting{}
+	}
+	newTerms := false
+	for _, tweet := range tweets {
+		text := indexedText{tweet.Text, tweet.FullText}
+		if old, ok := ix.indexed[tweet.ID]; ok {
+			if old == text {
+				continue
+			}
+			ix.remove(tweet.ID)
+		}
+		newTerms = ix.add(tweet) || newTerms
+	}
+	// Everything in tweets is indexed now, so anything more was removed.
+	if len(ix.tweets) > len(tweets) {
+		kept := make(map[int64]bool, len(tweets))
+		for _, tweet := range tweets {
+			kept[tweet.ID] = true
+		}
+		for id := range ix.tweets {
+			if !kept[id] {
+				ix.remove(id)
+				newTerms = true
+			}
+		}
+	}
+	ix.order = tweets
+	if newTerms {
+		ix.sorted = ix.sorted[:0]
+		for term := range ix.terms {
+			ix.sorted = append(ix.sorted, term)
+		}
+		sort.Strings(ix.sorted)
+	}
+}
+
+// add indexes a tweet, reporting whether it brought terms not seen before.
+func (ix *SearchIndex) add(tweet twitter.Tweet) bool {
+	newTerms := false
+	doc := tweet.ID
+	for _, t := range tokenize(expandLinks(tweet), true) {
+		postings := ix.terms[t.term]
+		if n := len(postings); n > 0 && postings[n-1].doc == doc {
+			postings[n-1].positions = append(postings[n-1].positions, t.pos)
+			continue
+		}
+		newTerms = newTerms || len(postings) == 0
+		ix.terms[t.term] = append(postings, posting{doc, []int{t.pos}})
+	}
+	ix.tweets[doc] = tweet
+	ix.indexed[doc] = indexedText{tweet.Text, tweet.FullText}
+	return newTerms
+}
+
+func (ix *SearchIndex) remove(id int64) {
+	for _, t := range tokenize(expandLinks(ix.tweets[id]), true) {
+		var kept []posting
+		for _, p := range ix.terms[t.term] {
+			if p.doc != id {
+				kept = append(kept, p)
+			}
+		}
+		if len(kept) == 0 {
+			delete(ix.terms, t.term)
+		} else {
+			ix.terms[t.term] = kept
+		}
+	}
+	delete(ix.tweets, id)
+	delete(ix.indexed, id)
+}
+
+// queryTerm is one word of a query; prefix terms match every indexed word
+// they start.
+type queryTerm struct {
+	text   string
+	prefix bool
+}
+
+// parseQuery splits a query into phrases, all of which must match. Quoted
+// text is one phrase, and so is each other word; a trailing * makes the
+// word before it a prefix.
+func parseQuery(query string) [][]queryTerm {
+	var phrases [][]queryTerm
+	add := func(text string) {
+		var phrase []queryTerm
+		tokens := tokenize(text, false)
+		for i, t := range tokens {
+			phrase = append(phrase, queryTerm{t.term, false})
+			if i == len(tokens)-1 && strings.HasSuffix(text, "*") {
+				phrase[i].prefix = true
+			}
+		}
+		if len(phrase) > 0 {
+			phrases = append(phrases, phrase)
+		}
+	}
+	for i, part := range strings.Split(query, `"`) {
+		if i%2 == 1 {
+			add(part)
+			continue
+		}
+		for _, word := range strings.Fields(part) {
+			add(word)
+		}
+	}
+	return phrases
+}
+
+// lookup returns the positions of a term in each document containing it.
+func (ix *SearchIndex) lookup(term queryTerm) map[int64][]int {
+	found := map[int64][]int{}
+	if !term.prefix {
+		for _, p := range ix.terms[term.text] {
+			found[p.doc] = p.positions
+		}
+		return found
+	}
+	for i := sort.SearchStrings(ix.sorted, term.text); i < len(ix.sorted) && strings.HasPrefix(ix.sorted[i], term.text); i += 1 {
+		for _, p := range ix.terms[ix.sorted[i]] {
+			found[p.doc] = append(found[p.doc], p.positions...)
+		}
+	}
+	return found
+}
+
+// matchPhrase returns the documents where the phrase's terms follow each
+// other.
+func (ix *SearchIndex) matchPhrase(phrase []queryTerm) map[int64]bool {
+	var hits []map[int64][]int
+	for _, term := range phrase {
+		hits = append(hits, ix.lookup(term))
+	}
+	docs := map[int64]bool{}
+	for doc, starts := range hits[0] {
+		for _, start := range starts {
+			ok := true
+			for i := 1; i < len(hits) && ok; i += 1 {
+				ok = containsPos(hits[i][doc], start+i)
+			}
+			if ok {
+				docs[doc] = true
+				break
+			}
+		}
+	}
+	return docs
+}
+
+func containsPos(positions []int, pos int) bool {
+	for _, p := range positions {
+		if p == pos {
+			return true
+		}
+	}
+	return false
+}
+
+func (term queryTerm) matches(s string) bool {
+	if term.prefix {
+		return strings.HasPrefix(s, term.text)
+	}
+	return s == term.text
+}
+
+// Search returns the archived tweets matching every phrase of query,
+// newest first, that pass keep.
+func (ix *SearchIndex) Search(tc *TweetCache, query string, keep func(twitter.Tweet) bool) []twitter.Tweet {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	if generation := tc.Generation(); !ix.built || ix.generation != generation {
+		ix.update(tc.archived())
+		ix.built, ix.generation = true, generation
+	}
+
+	var docs map[int64]bool
+	for _, phrase := range parseQuery(query) {
+		matched := ix.matchPhrase(phrase)
+		if docs != nil {
+			for doc := range docs {
+				if !matched[doc] {
+					delete(docs, doc)
+				}
+			}
+		} else {
+			docs = matched
+		}
+	}
+
+	var results []twitter.Tweet
+	if docs == nil {
+		for _, tweet := range ix.order {
+			if len(results) == maxSearchResults {
+				break
+			}
+			if keep(tweet) {
+				results = append(results, tweet)
+			}
+		}
+		return results
+	}
+	ids := make([]int64, 0, len(docs))
+	for id := range docs {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] > ids[j] })
+	for _, id := range ids {
+		if len(results) == maxSearchResults {
+			break
+		}
+		if tweet := ix.tweets[id]; keep(tweet) {
+			results = append(results, tweet)
+		}
+	}
+	return results
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/dghubble/go-twitter/twitter"
+)
+
+func searchIDs(rh *RequestHandler, query string) []int64 {
+	var ids []int64
+	for _, tweet := range rh.search.Search(rh.TweetCache, query, func(twitter.Tweet) bool { return true }) {
+		ids = append(ids, tweet.ID)
+	}
+	return ids
+}
+
+func TestSearchArchive(t *testing.T) {
+	c := Config{}
+	c.Cache.MaxTweets = 5
+	h := newFixtureHandler(t, c, 30)
+	tc := h.TweetCache
+
+	// Tweet 3 is long out of memory, but still archived.
+	if ids := searchIDs(h, `"tweet 3 about"`); len(ids) != 1 || ids[0] != 1003 {
+		t.Fatalf("tweet 3: found %v", ids)
+	}
+	if ids := searchIDs(h, "topic1"); len(ids) != 10 || ids[0] != 1029 || ids[9] != 1002 {
+		t.Fatalf("topic1: found %v, want the 10 tweets newest first", ids)
+	}
+
+	fresh := fixtureTweets(31)[0]
+	fresh.Text = "Brand new tweet about gophers"
+	tc.merge([]twitter.Tweet{fresh})
+	tc.changed()
+	if ids := searchIDs(h, "gophers"); len(ids) != 1 || ids[0] != fresh.ID {
+		t.Fatalf("gophers after merging: found %v", ids)
+	}
+
+	edited := fresh
+	edited.Text = "Brand new tweet about gerbils"
+	tc.merge([]twitter.Tweet{edited})
+	tc.changed()
+	if ids := searchIDs(h, "gophers"); len(ids) != 0 {
+		t.Fatalf("gophers after editing: found %v", ids)
+	}
+	if ids := searchIDs(h, "gerb*"); len(ids) != 1 {
+		t.Fatalf("gerb* after editing: found %v", ids)
+	}
+
+	tc.remove([]int64{1003})
+	tc.changed()
+	if ids := searchIDs(h, `"tweet 3 about"`); len(ids) != 0 {
+		t.Fatalf("tweet 3 after removing: found %v", ids)
+	}
+	if _, _, body := do(t, h, "/search?%22tweet%202%20about%22"); !strings.Contains(body, "Tweet 2 about") {
+		t.Fatalf("search page misses an archived tweet:\n%s", body)
+	}
+}
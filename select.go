@@ -0,0 +1,55 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/makeworld-the-better-one/go-gemini"
+)
+
+// ResolveOffset turns an offset taken from a page rendered when anchor was the
+// newest tweet into a position in the current cache, so refreshes in between
+// don't shift the selection.
+func (tc *TweetCache) ResolveOffset(anchor int64, offset int) int {
+	pos, err := tc.FindByID(anchor)
+	if err != nil {
+		return offset
+	}
+	return pos + offset
+}
+
+func (rh *RequestHandler) selectorLink() string {
+	if len(rh.TweetCache.Tweets) == 0 {
+		return "/select_tweet"
+	}
+	return fmt.Sprintf("/select_tweet/%d", rh.TweetCache.Tweets[0].ID)
+}
+
+func (rh *RequestHandler) selectTweet(u url.URL, anchor string) *gemini.Response {
+	anchored := 0
+	if anchor != "" {
+		id, err := strconv.ParseInt(anchor, 10, 64)
+		if err != nil {
+			return NotFound("Unknown location")
+		}
+		anchored = rh.TweetCache.ResolveOffset(id, 0)
+	}
+
+	last := len(rh.TweetCache.Tweets) - 1 - anchored
+	if last < 0 {
+		return TempFailure("No tweets cached yet")
+	}
+	prompt := fmt.Sprintf("Enter a tweet offset, 0–%d", last)
+
+	input, err := readInput(u)
+	if err != nil || input == "" {
+		return Input(prompt)
+	}
+	offset, err := strconv.Atoi(strings.TrimSpace(input))
+	if err != nil || offset < 0 || offset > last {
+		return Input(prompt)
+	}
+	return rh.showTweet(anchored + offset)
+}
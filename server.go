@@ -0,0 +1,156 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/makeworld-the-better-one/go-gemini"
+)
+
+// Request is a gemini.Request plus what we know about the connection it
+// arrived on.
+type Request struct {
+	gemini.Request
+	RemoteAddr   net.Addr
+	Certificates []*x509.Certificate
+	// The query as sent, once middleware has taken parameters out of it
+	SentQuery string
+}
+
+// Fingerprint returns the hex SHA-256 of the client certificate, or "" when
+// the client didn't present one.
+func (r Request) Fingerprint() string {
+	if len(r.Certificates) == 0 {
+		return ""
+	}
+	return certFingerprint(r.Certificates[0])
+}
+
+func (r Request) RemoteIP() string {
+	if r.RemoteAddr == nil {
+		return ""
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr.String())
+	if err != nil {
+		return r.RemoteAddr.String()
+	}
+	return host
+}
+
+func certFingerprint(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.Raw)
+	return hex.EncodeToString(sum[:])
+}
+
+type Handler interface {
+	Handle(r Request) *gemini.Response
+}
+
+func loadCertificate(certFile, keyFile string) (tls.Certificate, error) {
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("failed to load certificates: %v", err)
+	}
+	return cert, nil
+}
+
+// ServeTLS is like gemini.ListenAndServe on a socket that's already
+// listening, and asks clients for a certificate so handlers can identify
+// them.
+func ServeTLS(ln net.Listener, cert tls.Certificate, handler Handler) error {
+	defer ln.Close()
+	return Serve(tls.NewListener(ln, serverTLSConfig(cert)), handler)
+}
+
+func serverTLSConfig(cert tls.Certificate) *tls.Config {
+	return &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		ClientAuth:   tls.RequestClientCert,
+		MinVersion:   tls.VersionTLS12,
+	}
+}
+
+func listenTLS(addr string, cert tls.Certificate) (net.Listener, error) {
+	ln, err := tls.Listen("tcp", addr, serverTLSConfig(cert))
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen: %v", err)
+	}
+	return ln, nil
+}
+
+func Serve(ln net.Listener, handler Handler) error {
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if ne, ok := err.(net.Error); ok && ne.Temporary() {
+				time.Sleep(50 * time.Millisecond)
+				continue
+			}
+			return err
+		}
+		go serveConn(conn, handler)
+	}
+}
+
+func readRequestURL(conn io.Reader) (*url.URL, error) {
+	// 1024 bytes of URL plus CRLF
+	line, err := bufio.NewReader(io.LimitReader(conn, 1026)).ReadString('\n')
+	if err != nil {
+		return nil, fmt.Errorf("request line too long or incomplete")
+	}
+	u, err := url.Parse(strings.TrimRight(line, "\r\n"))
+	if err != nil {
+		return nil, fmt.Errorf("couldn't parse request URL")
+	}
+	if u.User != nil {
+		return nil, fmt.Errorf("userinfo not allowed in request URL")
+	}
+	if u.Scheme == "" {
+		u.Scheme = "gemini"
+	}
+	return u, nil
+}
+
+func writeResponse(w io.Writer, resp *gemini.Response) error {
+	_, err := fmt.Fprintf(w, "%d %s\r\n", resp.Status, resp.Meta)
+	if err != nil || resp.Body == nil {
+		return err
+	}
+	_, err = io.Copy(w, resp.Body)
+	return err
+}
+
+func serveConn(conn net.Conn, handler Handler) {
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(time.Minute))
+
+	r := Request{RemoteAddr: conn.RemoteAddr()}
+	if tc, ok := conn.(*tls.Conn); ok {
+		if err := tc.Handshake(); err != nil {
+			return
+		}
+		r.Certificates = tc.ConnectionState().PeerCertificates
+	}
+
+	u, err := readRequestURL(conn)
+	if err != nil {
+		writeResponse(conn, BadRequest("Bad URL: "+err.Error()))
+		return
+	}
+	r.URL = u
+
+	resp := handler.Handle(r)
+	if resp.Body != nil {
+		defer resp.Body.Close()
+	}
+	writeResponse(conn, resp)
+}
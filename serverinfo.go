@@ -0,0 +1,88 @@
+package main
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/makeworld-the-better-one/go-gemini"
+)
+
+func (c Config) listenAddr() string {
+	return fmt.Sprintf("%s:%d", c.Addr.Host, c.Addr.Port)
+}
+
+// serverFingerprint is the SHA-256 fingerprint of the certificate the
+// capsule serves, as TOFU clients remember it.
+func (c Config) serverFingerprint() (string, error) {
+	pair, err := tls.LoadX509KeyPair(c.Cert.CertFile, c.Cert.KeyFile)
+	if err != nil {
+		return "", err
+	}
+	cert, err := x509.ParseCertificate(pair.Certificate[0])
+	if err != nil {
+		return "", err
+	}
+	return certFingerprint(cert), nil
+}
+
+func (c Config) account() string {
+	switch c.Source {
+	case "aggregate":
+		var accounts []string
+		for _, sc := range c.Sources {
+			accounts = append(accounts, sc.config(c).account())
+		}
+		return strings.Join(accounts, ", ")
+	case "mastodon":
+		return "@" + strings.TrimPrefix(c.Mastodon.Account, "@")
+	case "telegram":
+		return fmt.Sprintf("%s/%s", telegramBase, c.Telegram.Channel)
+	case "feed":
+		return c.Feed.URL
+	}
+	if c.Twitter.ScreenName != "" {
+		return "@" + c.Twitter.ScreenName
+	}
+	return fmt.Sprintf("user ID %d", c.Twitter.UserID)
+}
+
+// formatFingerprint groups the hex digits in colon separated pairs.
+func formatFingerprint(fp string) string {
+	var pairs []string
+	for i := 0; i+2 <= len(fp); i += 2 {
+		pairs = append(pairs, strings.ToUpper(fp[i:i+2]))
+	}
+	return strings.Join(pairs, ":")
+}
+
+func (c Config) printBanner(fingerprint string) {
+	fmt.Println("donaldgem " + versionString())
+	fmt.Printf("  listening on  %s\n", c.listenAddr())
+	if c.Addr.URL != "" {
+		fmt.Printf("  public URL    %s\n", c.Addr.URL)
+	}
+	fmt.Printf("  mirroring     %s\n", c.account())
+	fmt.Printf("  fingerprint   SHA256:%s\n", formatFingerprint(fingerprint))
+}
+
+func (rh *RequestHandler) formatServerInfo() string {
+	page := "# Server info\n\n"
+	page += fmt.Sprintf("Mirroring: %s\n", rh.TweetCache.liveConfig().account())
+	if rh.Config.Addr.URL != "" {
+		page += fmt.Sprintf("Address: %s\n", rh.Config.Addr.URL)
+	}
+	page += fmt.Sprintf("Version: %s\n", versionString())
+	page += "\n## Certificate fingerprint (SHA-256)\n\n"
+	page += "Compare this with what your client trusted on first use.\n\n"
+	page += fmt.Sprintf("```\n%s\n```\n", formatFingerprint(rh.fingerprint))
+	return page
+}
+
+func (rh *RequestHandler) showServerInfo() *gemini.Response {
+	body := ioutil.NopCloser(bytes.NewBufferString(rh.wrapBody(rh.formatServerInfo())))
+	return Success(rh.meta(), body)
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/dghubble/go-twitter/twitter"
+)
+
+// How long instances sharing a Redis remember which tweets were announced
+const announcedTTL = 30 * 24 * time.Hour
+
+// sharedSource lets instances sharing a Redis take turns: whoever gets
+// the fetch lock for an interval fetches and stores what it got, the
+// others read that instead of calling the API themselves.
+type sharedSource struct {
+	Source
+	redis *Redis
+	owner string
+	ttl   time.Duration
+}
+
+// instanceName tells this instance apart from others sharing a Redis.
+func instanceName() string {
+	host, _ := os.Hostname()
+	return fmt.Sprintf("%s:%d", host, os.Getpid())
+}
+
+func (tc *TweetCache) shareSource(source Source) Source {
+	// A little under the interval, so the lock is free again by the time
+	// the next refresh comes round.
+	c := tc.Config
+	if s, ok := source.(aggregateSource); ok {
+		c.Sources = configsOf(s.sources)
+	}
+	ttl := c.refreshInterval() * 9 / 10
+	return sharedSource{source, tc.redis, instanceName(), ttl}
+}
+
+// claimNew returns the new tweets no other instance sharing the Redis has
+// announced, marking them as this one's to announce: every instance
+// merges the same fetch, but hooks, Misfin and ActivityPub should only
+// hear of a tweet once. Without Redis, all of them are.
+func (tc *TweetCache) claimNew(tweets []twitter.Tweet) []twitter.Tweet {
+	if tc.redis == nil {
+		return tweets
+	}
+	var claimed []twitter.Tweet
+	for _, tweet := range tweets {
+		ok, err := tc.redis.Lock(fmt.Sprintf("announced:%d", tweet.ID), instanceName(), announcedTTL)
+		if err != nil {
+			fmt.Println("redis unavailable, announcing alone:", err)
+			ok = true
+		}
+		if ok {
+			claimed = append(claimed, tweet)
+		}
+	}
+	return claimed
+}
+
+func (s sharedSource) Fetch() ([]twitter.Tweet, error) {
+	locked, err := s.redis.Lock("fetch-lock", s.owner, s.ttl)
+	if err != nil {
+		fmt.Println("redis unavailable, fetching alone:", err)
+		return s.Source.Fetch()
+	}
+	if locked {
+		tweets, err := s.Source.Fetch()
+		if err != nil {
+			return nil, err
+		}
+		data, err := json.Marshal(tweets)
+		if err == nil {
+			err = s.redis.Set("tweets", string(data))
+		}
+		if err != nil {
+			fmt.Println("sharing fetched tweets:", err)
+		}
+		return tweets, nil
+	}
+	data, ok, err := s.redis.Get("tweets")
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, errors.New("no shared tweets yet, another instance holds the fetch lock")
+	}
+	var tweets []twitter.Tweet
+	if err := json.Unmarshal([]byte(data), &tweets); err != nil {
+		return nil, err
+	}
+	return tweets, nil
+}
+
+func quotaKeys(now time.Time) (window, day string) {
+	return fmt.Sprintf("quota:window:%d", now.Truncate(quotaWindow).Unix()),
+		"quota:day:" + now.UTC().Format(dateLayout)
+}
+
+// takeShared counts a call in the shared quota, taking it back when it
+// goes over a budget.
+func (q *APIQuota) takeShared(now time.Time, perWindow, perDay int) error {
+	windowKey, dayKey := quotaKeys(now)
+	calls, err := q.shared.Incr(windowKey, 1, quotaWindow+time.Minute)
+	if err != nil {
+		return err
+	}
+	daily, err := q.shared.Incr(dayKey, 1, 48*time.Hour)
+	if err != nil {
+		return err
+	}
+	if wait := quotaReset(now, calls-1, daily-1, perWindow, perDay); wait > 0 {
+		q.shared.Incr(windowKey, -1, quotaWindow+time.Minute)
+		q.shared.Incr(dayKey, -1, 48*time.Hour)
+		return quotaError{now.Add(wait)}
+	}
+	return nil
+}
+
+func (q *APIQuota) sharedCounts(now time.Time) (calls, daily int, err error) {
+	windowKey, dayKey := quotaKeys(now)
+	if calls, err = q.shared.Count(windowKey); err != nil {
+		return 0, 0, err
+	}
+	daily, err = q.shared.Count(dayKey)
+	return calls, daily, err
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// memoryRedis answers GET and SET, with NX, from a map: enough of a
+// Redis for instances to share fetches through.
+type memoryRedis struct {
+	mu   sync.Mutex
+	data map[string]string
+}
+
+func (m *memoryRedis) listen(t *testing.T) string {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go m.serve(conn)
+		}
+	}()
+	return ln.Addr().String()
+}
+
+func (m *memoryRedis) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	for {
+		args, err := readCommand(r)
+		if err != nil {
+			return
+		}
+		m.mu.Lock()
+		reply := "-ERR unknown command\r\n"
+		switch strings.ToUpper(args[0]) {
+		case "GET":
+			reply = "$-1\r\n"
+			if v, ok := m.data[args[1]]; ok {
+				reply = fmt.Sprintf("$%d\r\n%s\r\n", len(v), v)
+			}
+		case "SET":
+			_, set := m.data[args[1]]
+			if set && len(args) > 3 && strings.ToUpper(args[3]) == "NX" {
+				reply = "$-1\r\n"
+			} else {
+				m.data[args[1]] = args[2]
+				reply = "+OK\r\n"
+			}
+		}
+		m.mu.Unlock()
+		io.WriteString(conn, reply)
+	}
+}
+
+// readCommand reads a command sent as an array of bulk strings.
+func readCommand(r *bufio.Reader) ([]string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(line[1:]))
+	if err != nil || n < 1 {
+		return nil, fmt.Errorf("bad command %q", line)
+	}
+	args := make([]string, n)
+	for i := range args {
+		if line, err = r.ReadString('\n'); err != nil {
+			return nil, err
+		}
+		size, err := strconv.Atoi(strings.TrimSpace(line[1:]))
+		if err != nil {
+			return nil, err
+		}
+		buf := make([]byte, size+2)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		args[i] = string(buf[:size])
+	}
+	return args, nil
+}
+
+func TestSharedFetchAnnouncedOnce(t *testing.T) {
+	redis := &memoryRedis{data: map[string]string{}}
+	c := Config{}
+	c.Redis.Addr = redis.listen(t)
+	tweets := fixtureTweets(5)
+
+	announced := map[int64]int{}
+	var mu sync.Mutex
+	for i := 0; i < 2; i++ {
+		// Both instances know the three oldest tweets; the fetch brings
+		// two more.
+		h, err := NewHandler(c, Deps{Tweets: tweets[2:]})
+		if err != nil {
+			t.Fatal(err)
+		}
+		tc := h.TweetCache
+		tc.source = tc.shareSource(fixtureSource{tweets})
+		tc.events.Subscribe(EventNewTweets, func(e Event) {
+			mu.Lock()
+			defer mu.Unlock()
+			for _, tweet := range e.Tweets {
+				announced[tweet.ID] += 1
+			}
+		})
+		if err := tc.refresh(); err != nil {
+			t.Fatal(err)
+		}
+		if len(tc.archived()) != 5 {
+			t.Errorf("instance %d archived %d tweets, want 5", i, len(tc.archived()))
+		}
+	}
+	if len(announced) != 2 || announced[1005] != 1 || announced[1004] != 1 {
+		t.Errorf("announced %v, want 1004 and 1005 once each", announced)
+	}
+}
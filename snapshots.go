@@ -0,0 +1,222 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"time"
+
+	"github.com/dghubble/go-twitter/twitter"
+	"github.com/makeworld-the-better-one/go-gemini"
+)
+
+// Followers and friends lists are fetched 200 accounts per request and
+// both endpoints allow 15 requests per window.
+const (
+	snapshotPageSize = 200
+	snapshotMaxPages = 15
+)
+
+type Account struct {
+	ID         int64  `json:"id"`
+	ScreenName string `json:"screenName"`
+	Name       string `json:"name"`
+}
+
+type Snapshot struct {
+	Taken     time.Time `json:"taken"`
+	Followers []Account `json:"followers"`
+	Following []Account `json:"following"`
+}
+
+// Snapshots keeps the latest snapshot and the one before it, which is
+// what deltas are computed against.
+type Snapshots struct {
+	Current  *Snapshot `json:"current"`
+	Previous *Snapshot `json:"previous"`
+}
+
+func LoadSnapshots(path string) (*Snapshots, error) {
+	s := &Snapshots{}
+	if path == "" {
+		return s, nil
+	}
+	b, err := ioutil.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, s); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *Snapshots) Save(path string) error {
+	if path == "" {
+		return nil
+	}
+	b, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path, b)
+}
+
+func toAccounts(users []twitter.User) []Account {
+	var accounts []Account
+	for _, u := range users {
+		accounts = append(accounts, Account{u.ID, u.ScreenName, u.Name})
+	}
+	return accounts
+}
+
+func (tc *TweetCache) takeSnapshot() (*Snapshot, error) {
+	client := twitter.NewClient(tc.httpClient())
+	snapshot := &Snapshot{Taken: time.Now()}
+
+	var cursor int64 = -1
+	for page := 0; page < snapshotMaxPages && cursor != 0; page++ {
+		followers, _, err := client.Followers.List(&twitter.FollowerListParams{
+			UserID:     tc.Config.Twitter.UserID,
+			ScreenName: tc.Config.Twitter.ScreenName,
+			Cursor:     cursor,
+			Count:      snapshotPageSize,
+			SkipStatus: twitter.Bool(true),
+		})
+		if err != nil {
+			return nil, err
+		}
+		snapshot.Followers = append(snapshot.Followers, toAccounts(followers.Users)...)
+		cursor = followers.NextCursor
+	}
+
+	cursor = -1
+	for page := 0; page < snapshotMaxPages && cursor != 0; page++ {
+		friends, _, err := client.Friends.List(&twitter.FriendListParams{
+			UserID:     tc.Config.Twitter.UserID,
+			ScreenName: tc.Config.Twitter.ScreenName,
+			Cursor:     cursor,
+			Count:      snapshotPageSize,
+			SkipStatus: twitter.Bool(true),
+		})
+		if err != nil {
+			return nil, err
+		}
+		snapshot.Following = append(snapshot.Following, toAccounts(friends.Users)...)
+		cursor = friends.NextCursor
+	}
+	return snapshot, nil
+}
+
+func (tc *TweetCache) SnapshotTaker() {
+	interval := time.Duration(tc.Config.Snapshots.IntervalHours) * time.Hour
+	for {
+		if current := tc.snapshots.Current; current != nil {
+			if wait := time.Until(current.Taken.Add(interval)); wait > 0 {
+				time.Sleep(wait)
+				continue
+			}
+		}
+
+		snapshot, err := tc.takeSnapshot()
+		if err != nil {
+			fmt.Println("taking follower snapshot failed:", err)
+			time.Sleep(time.Hour)
+			continue
+		}
+		saved := tc.running(func() {
+			tc.snapshots = &Snapshots{Current: snapshot, Previous: tc.snapshots.Current}
+			if err := tc.snapshots.Save(tc.Config.Snapshots.File); err != nil {
+				fmt.Println(err)
+			}
+		})
+		if !saved {
+			return
+		}
+		tc.changed()
+	}
+}
+
+// diffAccounts returns who is in now but not in before, and the reverse.
+func diffAccounts(before, now []Account) (added, removed []Account) {
+	in := func(accounts []Account) map[int64]bool {
+		ids := map[int64]bool{}
+		for _, a := range accounts {
+			ids[a.ID] = true
+		}
+		return ids
+	}
+	wasIn, isIn := in(before), in(now)
+	for _, a := range now {
+		if !wasIn[a.ID] {
+			added = append(added, a)
+		}
+	}
+	for _, a := range before {
+		if !isIn[a.ID] {
+			removed = append(removed, a)
+		}
+	}
+	return added, removed
+}
+
+func (rh *RequestHandler) formatAccount(a Account) string {
+	label := fmt.Sprintf("%s (@%s)", a.Name, a.ScreenName)
+	switch {
+	case rh.Config.Links.Nitter != "":
+		return fmt.Sprintf("=> %s %s\n", profileURL(rh.Config.Links.Nitter, a.ScreenName), label)
+	case rh.Config.Links.Twitter != "":
+		return fmt.Sprintf("=> %s %s\n", profileURL(rh.Config.Links.Twitter, a.ScreenName), label)
+	}
+	return fmt.Sprintf("* %s\n", label)
+}
+
+func (rh *RequestHandler) formatAccounts(title string, pick func(s *Snapshot) []Account) string {
+	snapshots := rh.TweetCache.snapshots
+	page := fmt.Sprintf("# %s\n\n", title)
+	if snapshots.Current == nil {
+		return page + "No snapshot taken yet."
+	}
+
+	current := pick(snapshots.Current)
+	page += fmt.Sprintf("%d accounts as of %s\n", len(current), snapshots.Current.Taken.Format(dateLayout))
+	if snapshots.Previous != nil {
+		added, removed := diffAccounts(pick(snapshots.Previous), current)
+		page += fmt.Sprintf("+%d / -%d since %s\n", len(added), len(removed), snapshots.Previous.Taken.Format(dateLayout))
+		if len(added) > 0 {
+			page += "\n## New\n\n"
+			for _, a := range added {
+				page += rh.Config.convertEmoji(rh.formatAccount(a))
+			}
+		}
+		if len(removed) > 0 {
+			page += "\n## Gone\n\n"
+			for _, a := range removed {
+				page += rh.Config.convertEmoji(rh.formatAccount(a))
+			}
+		}
+	}
+
+	page += "\n## Everyone\n\n"
+	for _, a := range current {
+		page += rh.Config.convertEmoji(rh.formatAccount(a))
+	}
+	return page
+}
+
+func (rh *RequestHandler) showFollowers() *gemini.Response {
+	page := rh.formatAccounts("Followers", func(s *Snapshot) []Account { return s.Followers })
+	body := ioutil.NopCloser(bytes.NewBufferString(rh.wrapBody(page)))
+	return Success(rh.meta(), body)
+}
+
+func (rh *RequestHandler) showFollowing() *gemini.Response {
+	page := rh.formatAccounts("Following", func(s *Snapshot) []Account { return s.Following })
+	body := ioutil.NopCloser(bytes.NewBufferString(rh.wrapBody(page)))
+	return Success(rh.meta(), body)
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/dghubble/go-twitter/twitter"
+)
+
+// Words of context a search snippet shows around the first match.
+const (
+	snippetWordsBefore = 8
+	snippetWords       = 30
+)
+
+const (
+	highlightStart = "»"
+	highlightEnd   = "«"
+)
+
+// highlights returns the word positions in tokens covered by a match of
+// any phrase.
+func highlights(tokens []token, phrases [][]queryTerm) map[int]bool {
+	words := map[int][]string{}
+	for _, t := range tokens {
+		words[t.pos] = append(words[t.pos], t.term)
+	}
+	matches := func(term queryTerm, pos int) bool {
+		for _, w := range words[pos] {
+			if term.matches(w) {
+				return true
+			}
+		}
+		return false
+	}
+
+	marked := map[int]bool{}
+	for _, phrase := range phrases {
+		for pos := range words {
+			n := 0
+			for n < len(phrase) && matches(phrase[n], pos+n) {
+				n += 1
+			}
+			if n == len(phrase) {
+				for i := 0; i < n; i += 1 {
+					marked[pos+i] = true
+				}
+			}
+		}
+	}
+	return marked
+}
+
+// snippet cuts the part of text around the first match and marks every
+// match in it, joining the words of a phrase into one mark.
+func snippet(text string, phrases [][]queryTerm) string {
+	tokens := tokenize(text, true)
+	if len(tokens) == 0 {
+		return text
+	}
+	// Indexing gives #tags and @mentions two tokens; keep one per word.
+	var words []token
+	for _, t := range tokens {
+		if len(words) == 0 || words[len(words)-1].pos != t.pos {
+			words = append(words, t)
+		}
+	}
+	marked := highlights(tokens, phrases)
+
+	first := 0
+	for i, w := range words {
+		if marked[w.pos] {
+			first = i
+			break
+		}
+	}
+	from := first - snippetWordsBefore
+	if from < 0 {
+		from = 0
+	}
+	to := from + snippetWords
+	if to > len(words) {
+		to = len(words)
+	}
+
+	var b strings.Builder
+	offset := 0
+	if from > 0 {
+		b.WriteString("…")
+		offset = words[from].start
+	}
+	for i := from; i < to; i += 1 {
+		w := words[i]
+		b.WriteString(text[offset:w.start])
+		if marked[w.pos] && (i == from || !marked[words[i-1].pos]) {
+			b.WriteString(highlightStart)
+		}
+		b.WriteString(text[w.start:w.end])
+		if marked[w.pos] && (i == to-1 || !marked[words[i+1].pos]) {
+			b.WriteString(highlightEnd)
+		}
+		offset = w.end
+	}
+	if to < len(words) {
+		b.WriteString("…")
+	} else {
+		b.WriteString(text[offset:])
+	}
+	return strings.Join(strings.Fields(b.String()), " ")
+}
+
+// formatSnippet renders a search result like Format does a whole tweet.
+func (tc *TweetCache) formatSnippet(tweet twitter.Tweet, query string) string {
+	phrases := parseQuery(query)
+	if len(phrases) == 0 {
+		return tc.Format(tweet)
+	}
+	text := tc.sourceBadge(tweet) + tc.Config.rewriteLinks(snippet(expandLinks(tweet), phrases))
+	return tc.finishFormat(tweet, text)
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/dghubble/go-twitter/twitter"
+)
+
+// Source is where the mirrored posts come from. Every source maps its
+// posts onto twitter.Tweet so the pages don't need to care.
+type Source interface {
+	Name() string
+	Fetch() ([]twitter.Tweet, error)
+	// URL links to the post on the original site.
+	URL(tweet twitter.Tweet) string
+}
+
+func (tc *TweetCache) newSource() (Source, error) {
+	if tc.Config.Source == "aggregate" {
+		return tc.newAggregateSource(tc.Config.Sources)
+	}
+	return tc.sourceOfType(tc.Config.Source, tc.Config)
+}
+
+func (tc *TweetCache) sourceOfType(kind string, c Config) (Source, error) {
+	switch kind {
+	case "twitter":
+		return twitterSource{tc}, nil
+	case "telegram":
+		return telegramSource{c}, nil
+	case "feed":
+		return feedSource{c}, nil
+	case "mastodon":
+		return mastodonSource{c}, nil
+	}
+	return nil, fmt.Errorf("unknown source %q", kind)
+}
+
+type twitterSource struct {
+	tc *TweetCache
+}
+
+func (s twitterSource) Name() string {
+	return "Twitter"
+}
+
+func (s twitterSource) Fetch() ([]twitter.Tweet, error) {
+	tweets, resp, err := s.tc.fetchIfChanged()
+	s.tc.noteFetchResult(resp, err)
+	return tweets, err
+}
+
+func (s twitterSource) URL(tweet twitter.Tweet) string {
+	return statusURL(s.tc.Config.Links.Twitter, tweet)
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/makeworld-the-better-one/go-gemini"
+)
+
+// SourceHandlers render the pages of aggregated sources that have a theme
+// or title of their own, one handler per look.
+type SourceHandlers struct {
+	mu       sync.Mutex
+	handlers map[[2]string]*RequestHandler
+}
+
+// checkSourceThemes makes sure the themes sources ask for exist.
+func (c *Config) checkSourceThemes() error {
+	for i, sc := range c.Sources {
+		if sc.Theme == "" {
+			continue
+		}
+		if _, err := loadTheme(c.UI.ThemesDir, sc.Theme); err != nil {
+			return fmt.Errorf("sources[%d]: %v", i, err)
+		}
+	}
+	return nil
+}
+
+// sourceUI is the config to render a source's pages with: the config as
+// read, with the source's theme and title.
+func (c Config) sourceUI(sc SourceConfig) (Config, error) {
+	u := *c.asRead
+	if sc.Theme != "" {
+		u.UI.Theme = sc.Theme
+	}
+	u.UI.Experimental = ""
+	// This handler already logs and limits the requests.
+	u.Middleware.AccessLog = false
+	u.Middleware.RateLimit = 0
+	var err error
+	if c.path != "" {
+		err = u.prepare(c.path)
+	} else if err = u.complete("."); err == nil {
+		err = u.validate()
+	}
+	u.forSource, u.title = true, sc.Title
+	return u, err
+}
+
+// handler returns the handler for a source's look, nil when it has none
+// of its own.
+func (sh *SourceHandlers) handler(rh *RequestHandler, sc SourceConfig) *RequestHandler {
+	if sc.Theme == "" && sc.Title == "" {
+		return nil
+	}
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+	key := [2]string{sc.Theme, sc.Title}
+	if h, ok := sh.handlers[key]; ok {
+		return h
+	}
+	c, err := rh.Config.sourceUI(sc)
+	if err != nil {
+		fmt.Println("rendering with the source's theme:", err)
+		return nil
+	}
+	if sh.handlers == nil {
+		sh.handlers = map[[2]string]*RequestHandler{}
+	}
+	h := NewRequestHandler(rh.TweetCache, c)
+	sh.handlers[key] = h
+	return h
+}
+
+// tweetOfPath returns the ID of the tweet a /tweet/:id or /thread/:id
+// page, or one below it, is about.
+func tweetOfPath(path string) (int64, bool) {
+	parts := strings.Split(strings.TrimPrefix(path, "/"), "/")
+	if len(parts) < 2 || (parts[0] != "tweet" && parts[0] != "thread") {
+		return 0, false
+	}
+	id, err := strconv.ParseInt(parts[1], 10, 64)
+	return id, err == nil
+}
+
+// applySourceUI renders the pages of a tweet from an aggregated source
+// with the source's theme and title, when it has them.
+func (rh *RequestHandler) applySourceUI(next Handler) Handler {
+	return Func(func(r Request) *gemini.Response {
+		s, ok := rh.TweetCache.aggregate()
+		if !ok {
+			return next.Handle(r)
+		}
+		id, ok := tweetOfPath(r.URL.Path)
+		if !ok {
+			return next.Handle(r)
+		}
+		tweet, ok := rh.TweetCache.lookup(id)
+		if !ok {
+			return next.Handle(r)
+		}
+		source, _ := s.origin(tweet)
+		if h := rh.sources.handler(rh, source.config); h != nil {
+			return h.Handle(r)
+		}
+		return next.Handle(r)
+	})
+}
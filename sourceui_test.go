@@ -0,0 +1,57 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSourceUI(t *testing.T) {
+	c := Config{Source: "aggregate"}
+	c.Sources = []SourceConfig{
+		{Type: "feed", URL: "https://example.org/feed.xml", Name: "Blog", Theme: "dense", Title: "The blog"},
+		{Type: "mastodon", Account: "someone@example.social", Name: "Toots"},
+	}
+	tweets := fixtureTweets(3)
+	tweets[0].Source, tweets[1].Source, tweets[2].Source = "Blog", "Toots", "Blog"
+	h, err := NewHandler(c, Deps{Tweets: tweets})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		url     string
+		want    string
+		heading bool
+	}{
+		{"/tweet/1003", "# The blog\n", true},
+		{"/tweet/1002", "Tweet 2 about #topic1", false},
+		{"/thread/1001", "# The blog\n", true},
+		{"/timeline", "Tweet 3 about #topic0", false},
+	}
+	for _, tt := range tests {
+		status, meta, body := do(t, h, tt.url)
+		if status != 20 {
+			t.Errorf("%s: status %d %q", tt.url, status, meta)
+			continue
+		}
+		if !strings.Contains(body, tt.want) {
+			t.Errorf("%s: body misses %q:\n%s", tt.url, tt.want, body)
+		}
+		if got := strings.HasPrefix(body, "# The blog\n"); got != tt.heading {
+			t.Errorf("%s: source heading %v, want %v:\n%s", tt.url, got, tt.heading, body)
+		}
+	}
+	// The dense theme puts the tweet under a heading, and has its own
+	// link labels.
+	if _, _, body := do(t, h, "/tweet/1003"); !strings.Contains(body, "## Fixture · 2020-07-01 12:00 · via Blog") || !strings.Contains(body, " Selector\n") {
+		t.Errorf("/tweet/1003 isn't in the source's theme:\n%s", body)
+	}
+	if _, _, body := do(t, h, "/tweet/1002"); !strings.Contains(body, " Tweet selector\n") {
+		t.Errorf("/tweet/1002 isn't in the usual theme:\n%s", body)
+	}
+
+	c.Sources[1].Theme = "nope"
+	if _, err := NewHandler(c, Deps{}); err == nil || !strings.Contains(err.Error(), "sources[1]") {
+		t.Errorf("unknown source theme: %v", err)
+	}
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/dghubble/go-twitter/twitter"
+)
+
+var startedAt = time.Now()
+
+type tagCount struct {
+	Tag   string
+	Count int
+}
+
+func countHashtags(tweets []twitter.Tweet) map[string]int {
+	counts := map[string]int{}
+	for _, tweet := range tweets {
+		if tweet.Entities == nil {
+			continue
+		}
+		for _, h := range tweet.Entities.Hashtags {
+			counts[strings.ToLower(h.Text)] += 1
+		}
+	}
+	return counts
+}
+
+func topCounts(counts map[string]int, n int) []tagCount {
+	var top []tagCount
+	for tag, count := range counts {
+		top = append(top, tagCount{tag, count})
+	}
+	sort.Slice(top, func(i, j int) bool {
+		if top[i].Count == top[j].Count {
+			return top[i].Tag < top[j].Tag
+		}
+		return top[i].Count > top[j].Count
+	})
+	if len(top) > n {
+		top = top[:n]
+	}
+	return top
+}
+
+// countMonths returns the number of tweets per month and the months in
+// order.
+func countMonths(tweets []twitter.Tweet) (map[string]int, []string) {
+	counts := map[string]int{}
+	for _, tweet := range tweets {
+		t, err := tweet.CreatedAtTime()
+		if err != nil {
+			continue
+		}
+		counts[t.Format("2006-01")] += 1
+	}
+
+	var months []string
+	for month := range counts {
+		months = append(months, month)
+	}
+	sort.Strings(months)
+	return counts, months
+}
+
+func formatMonthChart(tweets []twitter.Tweet, width int) string {
+	counts, months := countMonths(tweets)
+	max := 0
+	for _, n := range counts {
+		if n > max {
+			max = n
+		}
+	}
+
+	var chart string
+	for _, month := range months {
+		bar := counts[month] * width / max
+		if bar == 0 {
+			bar = 1
+		}
+		chart += fmt.Sprintf("%s %s %d\n", month, strings.Repeat("#", bar), counts[month])
+	}
+	return chart
+}
+
+func averagePerDay(tweets []twitter.Tweet) float64 {
+	var oldest, newest time.Time
+	for _, tweet := range tweets {
+		t, err := tweet.CreatedAtTime()
+		if err != nil {
+			continue
+		}
+		if oldest.IsZero() || t.Before(oldest) {
+			oldest = t
+		}
+		if newest.IsZero() || t.After(newest) {
+			newest = t
+		}
+	}
+	days := newest.Sub(oldest).Hours() / 24
+	if days < 1 {
+		days = 1
+	}
+	return float64(len(tweets)) / days
+}
+
+func (rh *RequestHandler) formatStats() string {
+	tweets := rh.TweetCache.archived()
+
+	cacheAge := "never refreshed"
+	if !rh.TweetCache.LastRefresh.IsZero() {
+		cacheAge = time.Since(rh.TweetCache.LastRefresh).Truncate(time.Second).String()
+	}
+
+	stats := fmt.Sprintf("## Statistics\n\nTweets archived: %d\nAverage tweets per day: %.2f\nCache age: %s\nUptime: %s\n",
+		len(tweets), averagePerDay(tweets), cacheAge, time.Since(startedAt).Truncate(time.Second))
+	if rh.Config.UI.Accessible {
+		stats += "\n### Tweets per month\n\n"
+		counts, months := countMonths(tweets)
+		for _, month := range months {
+			stats += fmt.Sprintf("* %s: %s\n", month, countNoun(counts[month], "tweet"))
+		}
+	} else {
+		stats += fmt.Sprintf("\n### Tweets per month\n\n```Bar chart of tweets per month\n%s```\n", formatMonthChart(tweets, 40))
+	}
+	stats += "\n### Most used hashtags\n\n"
+
+	top := topCounts(countHashtags(tweets), 10)
+	if len(top) == 0 {
+		stats += "No hashtags yet.\n"
+	}
+	for _, tag := range top {
+		stats += fmt.Sprintf("* #%s%s\n", tag.Tag, rh.Config.formatCount(tag.Count, "tweet"))
+	}
+	return stats
+}
@@ -0,0 +1,19 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestStatsCountArchive(t *testing.T) {
+	c := Config{}
+	c.Cache.MaxTweets = 5
+	h := newFixtureHandler(t, c, 30)
+
+	_, _, body := do(t, h, "/stats")
+	for _, want := range []string{"Tweets archived: 30\n", "2020-06 ######################################## 29\n", "* #topic0 (10)\n"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("/stats misses %q:\n%s", want, body)
+		}
+	}
+}
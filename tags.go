@@ -0,0 +1,67 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/dghubble/go-twitter/twitter"
+)
+
+func countMentions(tweets []twitter.Tweet) map[string]int {
+	counts := map[string]int{}
+	for _, tweet := range tweets {
+		if tweet.Entities == nil {
+			continue
+		}
+		for _, m := range tweet.Entities.UserMentions {
+			counts[strings.ToLower(m.ScreenName)] += 1
+		}
+	}
+	return counts
+}
+
+// searchLink escapes query as input, where "+" is a plus and not a space.
+func searchLink(query string) string {
+	return "/search?" + strings.ReplaceAll(url.QueryEscape(query), "+", "%20")
+}
+
+func (rh *RequestHandler) formatTags() string {
+	tweets := rh.TweetCache.archived()
+
+	var tags strings.Builder
+	tags.WriteString("## Hashtags\n\n")
+	hashtags := topCounts(countHashtags(tweets), 30)
+	if len(hashtags) == 0 {
+		tags.WriteString("No hashtags yet.\n")
+	}
+	for _, tag := range hashtags {
+		fmt.Fprintf(&tags, "=> %s #%s%s\n", searchLink("#"+tag.Tag), tag.Tag, rh.Config.formatCount(tag.Count, "tweet"))
+	}
+
+	tags.WriteString("\n## Mentions\n\n")
+	mentions := topCounts(countMentions(tweets), 30)
+	if len(mentions) == 0 {
+		tags.WriteString("No mentions yet.\n")
+	}
+	for _, mention := range mentions {
+		fmt.Fprintf(&tags, "=> %s @%s%s\n", searchLink("@"+mention.Tag), mention.Tag, rh.Config.formatCount(mention.Count, "mention"))
+	}
+	return tags.String()
+}
+
+func (rh *RequestHandler) formatSearch(query string, dr DateRange) string {
+	var results strings.Builder
+	found := rh.search.Search(rh.TweetCache, query, dr.Contains)
+	for _, tweet := range found {
+		results.WriteString(rh.formatEntry(tweet, rh.TweetCache.formatSnippet(tweet, query)))
+	}
+	title := fmt.Sprintf("\"%s\"", query)
+	if !dr.IsZero() {
+		title += " " + dr.String()
+	}
+	if len(found) == 0 {
+		return fmt.Sprintf("\n\nNo tweets found for %s.", title)
+	}
+	return fmt.Sprintf("## Results for %s\n\n=> %s Subscribe to this search%s", title, savedSearchLink(query), results.String())
+}
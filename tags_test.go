@@ -0,0 +1,19 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTagsCountArchive(t *testing.T) {
+	c := Config{}
+	c.Cache.MaxTweets = 5
+	h := newFixtureHandler(t, c, 30)
+
+	_, _, body := do(t, h, "/tags")
+	for _, want := range []string{"=> /search?%23topic0 #topic0 (10)\n", "=> /search?%40friend1 @friend1 (15)\n"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("/tags misses %q:\n%s", want, body)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"io/ioutil"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dghubble/go-twitter/twitter"
+)
+
+const telegramBase = "https://t.me"
+
+var (
+	telegramTitle   = regexp.MustCompile(`<meta property="og:title" content="([^"]*)"`)
+	telegramMessage = regexp.MustCompile(`(?s)<div class="tgme_widget_message [^"]*"[^>]*data-post="[^"/]+/(\d+)"(.*?)<time[^>]*datetime="([^"]+)"`)
+	telegramText    = regexp.MustCompile(`(?s)<div class="tgme_widget_message_text([^"]*)"[^>]*>(.*?)</div>`)
+)
+
+// telegramSource mirrors a public channel through its t.me/s web
+// preview, which needs no bot token.
+type telegramSource struct {
+	Config
+}
+
+func (s telegramSource) Name() string {
+	return "Telegram"
+}
+
+func (s telegramSource) URL(tweet twitter.Tweet) string {
+	if tweet.IDStr != "" {
+		return fmt.Sprintf("%s/%s/%s", telegramBase, s.Telegram.Channel, tweet.IDStr)
+	}
+	return fmt.Sprintf("%s/%s/%d", telegramBase, s.Telegram.Channel, tweet.ID)
+}
+
+func (s telegramSource) Fetch() ([]twitter.Tweet, error) {
+	client := http.Client{Timeout: time.Minute, Transport: s.Config.outbound(nil)}
+	resp, err := client.Get(telegramBase + "/s/" + s.Telegram.Channel)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("telegram: channel page returned %s", resp.Status)
+	}
+	page, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	return parseTelegramChannel(string(page), s.Telegram.Channel)
+}
+
+func parseTelegramChannel(page, channel string) ([]twitter.Tweet, error) {
+	user := &twitter.User{Name: channel, ScreenName: channel}
+	if m := telegramTitle.FindStringSubmatch(page); m != nil {
+		user.Name = html.UnescapeString(m[1])
+	}
+
+	var tweets []twitter.Tweet
+	for _, m := range telegramMessage.FindAllStringSubmatch(page, -1) {
+		id, err := strconv.ParseInt(m[1], 10, 64)
+		if err != nil {
+			continue
+		}
+		created, err := time.Parse(time.RFC3339, m[3])
+		if err != nil {
+			continue
+		}
+		text := ""
+		for _, t := range telegramText.FindAllStringSubmatch(m[2], -1) {
+			// Skip the quoted message a reply shows above its own text.
+			if !strings.Contains(t[1], "reply") {
+				text = htmlToText(t[2])
+				break
+			}
+		}
+		if text == "" {
+			// Photos and other media without a caption.
+			text = "(media)"
+		}
+
+		tweet := twitter.Tweet{
+			ID:        id,
+			CreatedAt: created.Format(time.RubyDate),
+			Text:      text,
+			FullText:  text,
+			User:      user,
+			Entities:  &twitter.Entities{},
+		}
+		for _, tag := range hashtagPattern.FindAllStringSubmatch(text, -1) {
+			tweet.Entities.Hashtags = append(tweet.Entities.Hashtags, twitter.HashtagEntity{Text: tag[1]})
+		}
+		tweets = append(tweets, tweet)
+	}
+	if len(tweets) == 0 {
+		return nil, errors.New("telegram: no messages found, is the channel public?")
+	}
+
+	// The page lists messages oldest first; the cache wants newest first.
+	for i, j := 0, len(tweets)-1; i < j; i, j = i+1, j-1 {
+		tweets[i], tweets[j] = tweets[j], tweets[i]
+	}
+	return tweets, nil
+}
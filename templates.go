@@ -0,0 +1,115 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net/url"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/dghubble/go-twitter/twitter"
+)
+
+// templateFuncs are available to ui.entryTemplate. Functions taking a
+// string take it last, so they work at the end of a pipeline:
+// {{.Text | truncate 80}}.
+var templateFuncs = template.FuncMap{
+	"relativeTime": relativeTime,
+	"wrap": func(width int, text string) string {
+		return wrapText(text, width)
+	},
+	"truncate":  truncate,
+	"upper":     strings.ToUpper,
+	"pluralize": pluralize,
+	"urlencode": url.PathEscape,
+}
+
+// relativeTime says how long ago t was, down to the minute, and falls
+// back to the date after a month.
+func relativeTime(t time.Time) string {
+	d := time.Since(t)
+	switch {
+	case d < time.Minute:
+		return "just now"
+	case d < time.Hour:
+		return countNoun(int(d/time.Minute), "minute") + " ago"
+	case d < 24*time.Hour:
+		return countNoun(int(d/time.Hour), "hour") + " ago"
+	case d < 30*24*time.Hour:
+		return countNoun(int(d/(24*time.Hour)), "day") + " ago"
+	}
+	return t.Format(dateLayout)
+}
+
+// truncate cuts text to width display cells, ending it with an ellipsis
+// if anything was cut.
+func truncate(width int, text string) string {
+	if stringWidth(text) <= width {
+		return text
+	}
+	head, _ := splitAtWidth(text, width-1)
+	return head + "…"
+}
+
+// pluralize is "1 tweet" or "3 tweets"; nouns that don't just take an s
+// give their plural as well: {{pluralize .Count "reply" "replies"}}.
+func pluralize(n int, noun string, plural ...string) string {
+	if n != 1 && len(plural) > 0 {
+		return fmt.Sprintf("%d %s", n, plural[0])
+	}
+	return countNoun(n, noun)
+}
+
+// entryData is what ui.entryTemplate is executed with.
+type entryData struct {
+	Author     string
+	ScreenName string
+	Time       time.Time
+	// Heading is the headings layout's "author · time · via source"
+	Heading string
+	// Text is formatted as it would be without the template, with the
+	// media links below it
+	Text      string
+	Permalink string
+	Via       string
+}
+
+func (c *Config) compileTemplates() error {
+	c.relativeTimes = strings.Contains(c.UI.EntryTemplate, "relativeTime")
+	if c.UI.EntryTemplate == "" {
+		return nil
+	}
+	tmpl, err := template.New("entry").Funcs(templateFuncs).Parse(c.UI.EntryTemplate)
+	if err != nil {
+		return err
+	}
+	c.entryTemplate = tmpl
+	return nil
+}
+
+// templateEntry lays out a tweet with ui.entryTemplate; ok is false when
+// there is none or it fails, and the layout's own is used.
+func (rh *RequestHandler) templateEntry(tweet twitter.Tweet, text string) (entry string, ok bool) {
+	if rh.Config.entryTemplate == nil {
+		return "", false
+	}
+	data := entryData{
+		Heading:   rh.tweetHeading(tweet),
+		Text:      text,
+		Permalink: permalink(tweet),
+		Via:       rh.TweetCache.sourceName(tweet),
+	}
+	if tweet.User != nil {
+		data.Author, data.ScreenName = tweet.User.Name, tweet.User.ScreenName
+	}
+	if t, err := tweet.CreatedAtTime(); err == nil {
+		data.Time = t
+	}
+	var buf bytes.Buffer
+	if err := rh.Config.entryTemplate.Execute(&buf, data); err != nil {
+		fmt.Println("ui.entryTemplate:", err)
+		return "", false
+	}
+	return "\n\n" + strings.Trim(buf.String(), "\n"), true
+}
@@ -0,0 +1,20 @@
+package main
+
+import "testing"
+
+func TestRelativeTimesNotCached(t *testing.T) {
+	c := Config{}
+	c.UI.EntryTemplate = "{{.Text}} {{relativeTime .Time}}"
+	h := newFixtureHandler(t, c, 3)
+	do(t, h, "/")
+	if _, ok := h.pages.Get("/?", h.TweetCache.Generation()); ok {
+		t.Error("a page saying how long ago tweets were was cached")
+	}
+
+	c.UI.EntryTemplate = "{{.Text}}"
+	h = newFixtureHandler(t, c, 3)
+	do(t, h, "/")
+	if _, ok := h.pages.Get("/?", h.TweetCache.Generation()); !ok {
+		t.Error("the front page wasn't cached")
+	}
+}
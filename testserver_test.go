@@ -0,0 +1,188 @@
+package main
+
+import (
+	"bufio"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dghubble/go-twitter/twitter"
+)
+
+// TestServer is a capsule on a local port with a throwaway certificate,
+// for testing clients and regressions against the real TLS stack.
+type TestServer struct {
+	// gemini://127.0.0.1:<port>
+	URL string
+	// SHA-256 of the certificate, for clients that pin it
+	Fingerprint string
+	Handler     *RequestHandler
+
+	ln net.Listener
+}
+
+// StartTestServer serves c with deps on addr, or a random local port if
+// addr is empty, until Close.
+func StartTestServer(addr string, c Config, deps Deps) (*TestServer, error) {
+	if addr == "" {
+		addr = "127.0.0.1:0"
+	}
+	cert, err := throwawayCert()
+	if err != nil {
+		return nil, err
+	}
+	h, err := NewHandler(c, deps)
+	if err != nil {
+		return nil, err
+	}
+	h.fingerprint = certFingerprint(cert.Leaf)
+	ln, err := listenTLS(addr, cert)
+	if err != nil {
+		return nil, err
+	}
+	go Serve(ln, h)
+	return &TestServer{
+		URL:         "gemini://" + ln.Addr().String(),
+		Fingerprint: certFingerprint(cert.Leaf),
+		Handler:     h,
+		ln:          ln,
+	}, nil
+}
+
+func (ts *TestServer) Close() error {
+	return ts.ln.Close()
+}
+
+// throwawayCert makes a self-signed certificate for localhost that's good
+// for a day.
+func throwawayCert() (tls.Certificate, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 64))
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: "localhost"},
+		DNSNames:     []string{"localhost"},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1), net.IPv6loopback},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(24 * time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}, nil
+}
+
+// fixtureSource serves the same made-up tweets every time, so pages only
+// change when the code rendering them does.
+type fixtureSource struct {
+	tweets []twitter.Tweet
+}
+
+func (s fixtureSource) Name() string {
+	return "Fixture"
+}
+
+func (s fixtureSource) Fetch() ([]twitter.Tweet, error) {
+	return s.tweets, nil
+}
+
+func (s fixtureSource) URL(tweet twitter.Tweet) string {
+	return statusURL("https://twitter.com", tweet)
+}
+
+// fixtureTweets are n tweets, one every 13 hours back from 2020-07-01, with
+// hashtags, mentions, links, other languages and a thread among them.
+func fixtureTweets(n int) []twitter.Tweet {
+	user := &twitter.User{ID: 42, Name: "Fixture", ScreenName: "fixture", Description: "Made-up tweets for testing"}
+	langs := []string{"en", "en", "de", "fr"}
+	base := time.Date(2020, 7, 1, 12, 0, 0, 0, time.UTC)
+	var tweets []twitter.Tweet
+	for i := 0; i < n; i++ {
+		id := int64(1000 + n - i)
+		link := fmt.Sprintf("https://example.org/%d", i)
+		tweet := twitter.Tweet{
+			ID:        id,
+			IDStr:     fmt.Sprint(id),
+			CreatedAt: base.Add(-time.Duration(i) * 13 * time.Hour).Format(time.RubyDate),
+			Text:      fmt.Sprintf("Tweet %d about #topic%d with @friend%d https://t.co/%d", n-i, i%3, i%2, i),
+			User:      user,
+			Lang:      langs[i%len(langs)],
+			Entities: &twitter.Entities{
+				Hashtags:     []twitter.HashtagEntity{{Text: fmt.Sprintf("topic%d", i%3)}},
+				UserMentions: []twitter.MentionEntity{{ScreenName: fmt.Sprintf("friend%d", i%2)}},
+				Urls:         []twitter.URLEntity{{URL: fmt.Sprintf("https://t.co/%d", i), ExpandedURL: link, DisplayURL: link}},
+			},
+		}
+		// Every fifth tweet continues the one before it.
+		if i%5 == 0 && i+1 < n {
+			tweet.InReplyToStatusID = id - 1
+			tweet.InReplyToUserID = user.ID
+			tweet.InReplyToScreenName = user.ScreenName
+		}
+		tweets = append(tweets, tweet)
+	}
+	return tweets
+}
+
+func TestTestServer(t *testing.T) {
+	tweets := fixtureTweets(12)
+	ts, err := StartTestServer("", Config{}, Deps{Source: fixtureSource{tweets}, Tweets: tweets})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ts.Close()
+	u, err := url.Parse(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn, err := tls.Dial("tcp", u.Host, &tls.Config{InsecureSkipVerify: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if got := certFingerprint(conn.ConnectionState().PeerCertificates[0]); got != ts.Fingerprint {
+		t.Errorf("server certificate %s, want %s", got, ts.Fingerprint)
+	}
+	if _, err := conn.Write([]byte(ts.URL + "/tweet/1012\r\n")); err != nil {
+		t.Fatal(err)
+	}
+	r := bufio.NewReader(conn)
+	header, err := r.ReadString('\n')
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(header, "20 text/gemini") {
+		t.Fatalf("header %q", header)
+	}
+	body, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(body), "Tweet 12 about #topic0") {
+		t.Errorf("body misses the tweet:\n%s", body)
+	}
+}
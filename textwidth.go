@@ -0,0 +1,156 @@
+package main
+
+import (
+	"strings"
+	"unicode"
+)
+
+// wideRanges lists East Asian Wide and Fullwidth blocks that terminals draw
+// two cells wide.
+var wideRanges = [][2]rune{
+	{0x1100, 0x115F},
+	{0x2E80, 0x303E},
+	{0x3041, 0x33FF},
+	{0x3400, 0x4DBF},
+	{0x4E00, 0x9FFF},
+	{0xA000, 0xA4CF},
+	{0xA960, 0xA97F},
+	{0xAC00, 0xD7A3},
+	{0xF900, 0xFAFF},
+	{0xFE10, 0xFE19},
+	{0xFE30, 0xFE6F},
+	{0xFF00, 0xFF60},
+	{0xFFE0, 0xFFE6},
+	{0x20000, 0x2FFFD},
+	{0x30000, 0x3FFFD},
+}
+
+func runeWidth(r rune) int {
+	if r == 0 || isEmojiModifier(r) || unicode.In(r, unicode.Mn, unicode.Me, unicode.Cf) {
+		return 0
+	}
+	if isRegionalIndicator(r) || (isEmoji(r) && r >= 0x1F000) {
+		return 2
+	}
+	for _, wr := range wideRanges {
+		if r >= wr[0] && r <= wr[1] {
+			return 2
+		}
+	}
+	return 1
+}
+
+func stringWidth(s string) int {
+	width := 0
+	for _, r := range s {
+		width += runeWidth(r)
+	}
+	return width
+}
+
+// wrapText hard-wraps each line of text to at most width display cells,
+// breaking at spaces where possible.
+func wrapText(text string, width int) string {
+	if width <= 0 {
+		return text
+	}
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		lines[i] = wrapLine(line, width)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// startsLine reports whether a line starting with word would be read as
+// gemtext line syntax rather than text.
+func startsLine(word string) bool {
+	for _, prefix := range []string{"```", "=>", "#", ">"} {
+		if strings.HasPrefix(word, prefix) {
+			return true
+		}
+	}
+	return word == "*"
+}
+
+func wrapLine(line string, width int) string {
+	var out []string
+	var current []string
+	currentWidth := 0
+	for _, word := range strings.Fields(line) {
+		wordWidth := stringWidth(word)
+		if len(current) > 0 && currentWidth+1+wordWidth > width {
+			// Break before the last word that can start a line, taking
+			// what follows it along; without one, the line runs long.
+			words := append(append([]string(nil), current...), word)
+			at := len(current)
+			for at > 0 && startsLine(words[at]) {
+				at--
+			}
+			if at > 0 {
+				out = append(out, strings.Join(current[:at], " "))
+				current = current[at:]
+				currentWidth = stringWidth(strings.Join(current, " "))
+			}
+		}
+		// Words longer than a whole line get broken up, unless they are
+		// links or a piece would start with line syntax.
+		for len(current) == 0 && wordWidth > width && !strings.Contains(word, "://") {
+			head, rest := splitAtWidth(word, width)
+			if head == "" || startsLine(rest) {
+				break
+			}
+			out = append(out, head)
+			word, wordWidth = rest, stringWidth(rest)
+		}
+		if len(current) > 0 {
+			currentWidth += 1
+		}
+		current = append(current, word)
+		currentWidth += wordWidth
+	}
+	return strings.Join(append(out, strings.Join(current, " ")), "\n")
+}
+
+// splitAtWidth cuts s after as many runes as fit in width cells.
+func splitAtWidth(s string, width int) (string, string) {
+	used := 0
+	for i, r := range s {
+		w := runeWidth(r)
+		if used+w > width {
+			return s[:i], s[i:]
+		}
+		used += w
+	}
+	return s, ""
+}
+
+// padRight pads s with spaces to width display cells.
+func padRight(s string, width int) string {
+	if w := stringWidth(s); w < width {
+		return s + strings.Repeat(" ", width-w)
+	}
+	return s
+}
+
+// fillWidth repeats pattern until it spans exactly width display cells.
+func fillWidth(pattern string, width int) string {
+	pw := stringWidth(pattern)
+	if pw == 0 || width <= 0 {
+		return pattern
+	}
+	filled := strings.Repeat(pattern, width/pw)
+	head, _ := splitAtWidth(pattern, width-stringWidth(filled))
+	return padRight(filled+head, width)
+}
+
+// delimiter returns the configured delimiter, stretched to the wrap width
+// when wrapping is enabled.
+func (c *Config) delimiter() string {
+	if c.UI.Accessible {
+		return ""
+	}
+	if c.UI.Wrap <= 0 {
+		return c.UI.Delimiter
+	}
+	return fillWidth(c.UI.Delimiter, c.UI.Wrap)
+}
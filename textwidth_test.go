@@ -0,0 +1,33 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestWrapLine(t *testing.T) {
+	tests := []struct {
+		line  string
+		width int
+		want  string
+	}{
+		{"aaa bbb ccc", 7, "aaa bbb\nccc"},
+		{"abcdefghij", 4, "abcd\nefgh\nij"},
+		{"Shipping today #golang #release", 20, "Shipping\ntoday #golang #release"},
+		{"see => here and > there", 6, "see =>\nhere\nand >\nthere"},
+		{"a ``` b", 3, "a ```\nb"},
+		{"read https://example.org/a/very/long/path now", 10, "read\nhttps://example.org/a/very/long/path\nnow"},
+		{"#one #two #three", 5, "#one #two #three"},
+	}
+	for _, tt := range tests {
+		got := wrapLine(tt.line, tt.width)
+		if got != tt.want {
+			t.Errorf("wrapLine(%q, %d) = %q, want %q", tt.line, tt.width, got, tt.want)
+		}
+		for _, line := range strings.Split(got, "\n")[1:] {
+			if fields := strings.Fields(line); len(fields) > 0 && startsLine(fields[0]) {
+				t.Errorf("wrapLine(%q, %d) starts a line with %q", tt.line, tt.width, fields[0])
+			}
+		}
+	}
+}
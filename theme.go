@@ -0,0 +1,199 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Theme is a look for the capsule. In ui.themesDir, theme <name> is the
+// directory <name>/ holding any of:
+//
+//	entry.tmpl   the ui.entryTemplate
+//	logo.txt     the logo
+//	strings.yml  labels of links, by key as in defaultStrings
+//	theme.yml    defaults for the ui settings in themeDefaults
+//
+// A directory named after a built-in theme changes only what it holds.
+type Theme struct {
+	Entry    string
+	Logo     string
+	Strings  map[string]string
+	Defaults themeDefaults
+}
+
+// themeDefaults apply to the ui settings the config leaves out.
+type themeDefaults struct {
+	Layout         string `yaml:"layout"`
+	Delimiter      string `yaml:"delimiter"`
+	FrontPageCount int    `yaml:"frontPageCount"`
+	BackToTopEvery int    `yaml:"backToTopEvery"`
+	Wrap           int    `yaml:"wrap"`
+	Emoji          string `yaml:"emoji"`
+}
+
+var defaultStrings = map[string]string{
+	"home":       "Home",
+	"timeline":   "Timeline",
+	"digests":    "Daily digests",
+	"selector":   "Tweet selector",
+	"tags":       "Tags",
+	"search":     "Search",
+	"stats":      "Statistics",
+	"allTweets":  "All tweets",
+	"serverInfo": "Server info",
+	"fork":       "Fork me on GitHub",
+	"permalink":  "Permalink",
+}
+
+var builtinThemes = map[string]Theme{
+	"classic": {
+		Defaults: themeDefaults{Layout: "classic", Delimiter: "--"},
+	},
+	"minimal": {
+		Entry:    "{{.Text}}\n=> {{.Permalink}} {{.Time.Format \"2006-01-02 15:04\"}}",
+		Defaults: themeDefaults{Layout: "headings", FrontPageCount: 1, BackToTopEvery: -1},
+	},
+	"dense": {
+		Entry: "### {{.Author}} · {{.Time.Format \"2006-01-02 15:04\"}}\n{{.Text}}\n=> {{.Permalink}} Permalink",
+		Strings: map[string]string{
+			"selector":   "Selector",
+			"stats":      "Stats",
+			"allTweets":  "All",
+			"serverInfo": "Info",
+			"fork":       "Source",
+		},
+		Defaults: themeDefaults{Layout: "headings", FrontPageCount: 10, BackToTopEvery: -1},
+	},
+}
+
+func builtinThemeNames() []string {
+	var names []string
+	for name := range builtinThemes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// readThemeFile returns the file's contents, or "" and ok false if there
+// is no such file.
+func readThemeFile(dir, name string) (string, bool, error) {
+	b, err := ioutil.ReadFile(filepath.Join(dir, name))
+	if errors.Is(err, os.ErrNotExist) {
+		return "", false, nil
+	} else if err != nil {
+		return "", false, err
+	}
+	return string(b), true, nil
+}
+
+func loadTheme(themesDir, name string) (Theme, error) {
+	if name != filepath.Base(name) || name == "." || name == ".." {
+		return Theme{}, fmt.Errorf("ui.theme must be a name, got %q", name)
+	}
+	theme, builtin := builtinThemes[name]
+	dir := filepath.Join(themesDir, name)
+	if _, err := os.Stat(dir); os.IsNotExist(err) {
+		if builtin {
+			return theme, nil
+		}
+		return Theme{}, fmt.Errorf("ui.theme: no theme %q in %s, built in are %s", name, themesDir, strings.Join(builtinThemeNames(), ", "))
+	} else if err != nil {
+		return Theme{}, err
+	}
+
+	if entry, ok, err := readThemeFile(dir, "entry.tmpl"); err != nil {
+		return Theme{}, err
+	} else if ok {
+		theme.Entry = entry
+	}
+	if logo, ok, err := readThemeFile(dir, "logo.txt"); err != nil {
+		return Theme{}, err
+	} else if ok {
+		theme.Logo = logo
+	}
+	labels := map[string]string{}
+	for key, s := range theme.Strings {
+		labels[key] = s
+	}
+	if s, ok, err := readThemeFile(dir, "strings.yml"); err != nil {
+		return Theme{}, err
+	} else if ok {
+		if err := yaml.Unmarshal([]byte(s), &labels); err != nil {
+			return Theme{}, fmt.Errorf("%s: %v", filepath.Join(dir, "strings.yml"), err)
+		}
+	}
+	theme.Strings = labels
+	for key := range labels {
+		if _, ok := defaultStrings[key]; !ok {
+			return Theme{}, fmt.Errorf("%s: unknown string %q", filepath.Join(dir, "strings.yml"), key)
+		}
+	}
+	if s, ok, err := readThemeFile(dir, "theme.yml"); err != nil {
+		return Theme{}, err
+	} else if ok {
+		if err := yaml.UnmarshalStrict([]byte(s), &theme.Defaults); err != nil {
+			return Theme{}, fmt.Errorf("%s: %v", filepath.Join(dir, "theme.yml"), err)
+		}
+	}
+	return theme, nil
+}
+
+// applyTheme loads ui.theme and fills in what the config leaves to it.
+func (c *Config) applyTheme(configDir string) error {
+	if c.UI.ThemesDir == "" {
+		c.UI.ThemesDir = "themes"
+	}
+	c.UI.ThemesDir = resolvePath(configDir, c.UI.ThemesDir)
+	if c.UI.Theme == "" {
+		return nil
+	}
+	theme, err := loadTheme(c.UI.ThemesDir, c.UI.Theme)
+	if err != nil {
+		return err
+	}
+
+	d := theme.Defaults
+	if c.UI.Layout == "" {
+		c.UI.Layout = d.Layout
+	}
+	if c.UI.Delimiter == "" {
+		c.UI.Delimiter = d.Delimiter
+	}
+	if c.UI.FrontPageCount == 0 {
+		c.UI.FrontPageCount = d.FrontPageCount
+	}
+	if c.UI.BackToTopEvery == 0 {
+		c.UI.BackToTopEvery = d.BackToTopEvery
+	}
+	if c.UI.Wrap == 0 {
+		c.UI.Wrap = d.Wrap
+	}
+	if c.UI.Emoji == "" {
+		c.UI.Emoji = d.Emoji
+	}
+	if c.UI.EntryTemplate == "" {
+		c.UI.EntryTemplate = theme.Entry
+	}
+	// Only used when there's no logo file
+	if c.UI.AsciiLogo == "" {
+		c.UI.AsciiLogo = theme.Logo
+	}
+	c.strings = theme.Strings
+	return nil
+}
+
+// label is the theme's text for a link, or the default.
+func (c *Config) label(key string) string {
+	if s, ok := c.strings[key]; ok {
+		return s
+	}
+	return defaultStrings[key]
+}
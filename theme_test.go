@@ -0,0 +1,17 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestThemesShowAbsoluteTimes(t *testing.T) {
+	for _, theme := range []string{"minimal", "dense"} {
+		c := Config{}
+		c.UI.Theme = theme
+		h := newFixtureHandler(t, c, 3)
+		if _, _, body := do(t, h, "/"); !strings.Contains(body, "2020-07-01 12:00") || strings.Contains(body, " ago") {
+			t.Errorf("%s: / doesn't give the time the tweet was made:\n%s", theme, body)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/dghubble/go-twitter/twitter"
+	"github.com/makeworld-the-better-one/go-gemini"
+)
+
+// isSelfReply reports whether tweet replies to another tweet by the same
+// account, i.e. continues a thread.
+func isSelfReply(tweet twitter.Tweet) bool {
+	return tweet.InReplyToStatusID != 0 && tweet.User != nil && tweet.InReplyToUserID == tweet.User.ID
+}
+
+// dropRepliesToOthers keeps plain tweets and self-replies, so threads stay
+// complete without pulling in conversations with other accounts.
+func dropRepliesToOthers(tweets []twitter.Tweet) []twitter.Tweet {
+	var kept []twitter.Tweet
+	for _, tweet := range tweets {
+		if tweet.InReplyToStatusID == 0 || isSelfReply(tweet) {
+			kept = append(kept, tweet)
+		}
+	}
+	return kept
+}
+
+// Thread returns the cache positions of every cached tweet in the thread
+// containing id, newest first.
+func (tc *TweetCache) Thread(id int64) ([]int, error) {
+	pos, err := tc.FindByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	positions := map[int64]int{}
+	for i, tweet := range tc.Tweets {
+		positions[tweet.ID] = i
+	}
+
+	root := tc.Tweets[pos]
+	for isSelfReply(root) {
+		parent, ok := positions[root.InReplyToStatusID]
+		if !ok {
+			break
+		}
+		root = tc.Tweets[parent]
+	}
+
+	inThread := map[int64]bool{root.ID: true}
+	var thread []int
+	// Tweets are newest first, so walking backwards visits parents first.
+	for i := len(tc.Tweets) - 1; i >= 0; i -= 1 {
+		tweet := tc.Tweets[i]
+		if inThread[tweet.ID] || (isSelfReply(tweet) && inThread[tweet.InReplyToStatusID]) {
+			inThread[tweet.ID] = true
+			thread = append(thread, i)
+		}
+	}
+	sort.Ints(thread)
+	return thread, nil
+}
+
+func (rh *RequestHandler) formatThread(thread []int, order string) string {
+	var page strings.Builder
+	page.WriteString("## Thread")
+	top := ""
+	if len(thread) > 0 {
+		top = fmt.Sprintf("/thread/%d?order=%s", rh.TweetCache.Tweets[thread[0]].ID, order)
+	}
+	shown := 0
+	for n := range thread {
+		i := thread[n]
+		if order == "asc" {
+			i = thread[len(thread)-1-n]
+		}
+		tw, err := rh.TweetCache.GetOnPosition(i)
+		if err != nil {
+			continue
+		}
+		shown += 1
+		page.WriteString(rh.formatLongEntry(rh.TweetCache.Tweets[i], tw, shown, top))
+	}
+	page.WriteString(rh.formatParticipants(thread))
+	return page.String()
+}
+
+func (rh *RequestHandler) showThread(id int64, order string) *gemini.Response {
+	thread, err := rh.TweetCache.Thread(id)
+	if err != nil {
+		return NotFound("Tweet not found")
+	}
+	body := ioutil.NopCloser(bytes.NewBufferString(rh.wrapBody(rh.formatThread(thread, order))))
+	return Success(rh.meta(), body)
+}
+
+// parseOrder reads ?order=asc|desc, falling back to def.
+func parseOrder(u url.URL, def string) (string, error) {
+	order := u.Query().Get("order")
+	if order == "" {
+		return def, nil
+	}
+	if order != "asc" && order != "desc" {
+		return "", errors.New("order must be asc or desc")
+	}
+	return order, nil
+}
@@ -0,0 +1,263 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Upgrades swap the running binary for the one now at its path without
+// closing the listening sockets. On SIGHUP the server starts the binary
+// again with the same arguments, handing it the sockets; once the new
+// process is serving, the old one stops accepting and exits after the
+// connections it has open are done. SIGTERM and interrupts stop the
+// server the same way, without starting another. Changes to the listen
+// addresses still need a restart.
+
+const (
+	// Names of the sockets passed as fds 3 and on, comma separated
+	envListeners = "DONALDGEM_LISTENERS"
+	// The new process writes a byte to this fd once it is serving
+	envReadyFD = "DONALDGEM_READY_FD"
+
+	upgradeTimeout = time.Minute
+	drainTimeout   = time.Minute
+)
+
+type Upgrader struct {
+	mu        sync.Mutex
+	inherited map[string]*os.File
+	names     []string
+	listeners []*net.TCPListener
+	ready     *os.File
+	stopping  bool
+	conns     sync.WaitGroup
+
+	// Run once the listeners are closed, to stop the work that would
+	// overlap with the next process's
+	BeforeDrain func()
+	// Run when stopping for good, not for an upgrade
+	OnStop func()
+}
+
+// NewUpgrader picks up the sockets and the readiness pipe from the process
+// that started this one, if it was an upgrade.
+func NewUpgrader() *Upgrader {
+	u := &Upgrader{inherited: map[string]*os.File{}}
+	if names := os.Getenv(envListeners); names != "" {
+		for i, name := range strings.Split(names, ",") {
+			u.inherited[name] = os.NewFile(uintptr(3+i), name)
+		}
+	}
+	if fd, err := strconv.Atoi(os.Getenv(envReadyFD)); err == nil {
+		u.ready = os.NewFile(uintptr(fd), "ready")
+	}
+	// Not for processes this one starts
+	os.Unsetenv(envListeners)
+	os.Unsetenv(envReadyFD)
+	return u
+}
+
+// Listen returns the socket called name handed over by the previous
+// process, or listens on addr.
+func (u *Upgrader) Listen(name, addr string) (net.Listener, error) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	var ln net.Listener
+	var err error
+	if f, ok := u.inherited[name]; ok {
+		delete(u.inherited, name)
+		ln, err = net.FileListener(f)
+		f.Close()
+	} else {
+		ln, err = net.Listen("tcp", addr)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen: %v", err)
+	}
+	tcp, ok := ln.(*net.TCPListener)
+	if !ok {
+		ln.Close()
+		return nil, fmt.Errorf("failed to listen: %s is not a TCP socket", name)
+	}
+	u.names = append(u.names, name)
+	u.listeners = append(u.listeners, tcp)
+	return trackedListener{tcp, u}, nil
+}
+
+// Ready tells the previous process that this one is serving, so it can
+// stop. Sockets it handed over that aren't used any more are closed.
+func (u *Upgrader) Ready() {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	for name, f := range u.inherited {
+		f.Close()
+		delete(u.inherited, name)
+	}
+	if u.ready != nil {
+		u.ready.Write([]byte{1})
+		u.ready.Close()
+		u.ready = nil
+	}
+}
+
+// Stopping reports whether the listeners were closed for an upgrade,
+// rather than failing.
+func (u *Upgrader) Stopping() bool {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return u.stopping
+}
+
+// HandleSignals upgrades on every SIGHUP until one succeeds, and stops on
+// SIGTERM or an interrupt, draining the connections before exiting. A
+// failed upgrade leaves this process serving.
+func (u *Upgrader) HandleSignals() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP, syscall.SIGTERM, os.Interrupt)
+	for sig := range signals {
+		if sig != syscall.SIGHUP {
+			fmt.Println("stopping")
+			if u.OnStop != nil {
+				u.OnStop()
+			}
+			u.drain()
+			os.Exit(0)
+		}
+		fmt.Println("upgrading")
+		if err := u.upgrade(); err != nil {
+			fmt.Println(err)
+			continue
+		}
+		u.drain()
+		os.Exit(0)
+	}
+}
+
+// drain stops accepting connections and waits for the open ones to be
+// done, for up to drainTimeout.
+func (u *Upgrader) drain() {
+	u.mu.Lock()
+	u.stopping = true
+	for _, ln := range u.listeners {
+		ln.Close()
+	}
+	u.mu.Unlock()
+	if u.BeforeDrain != nil {
+		u.BeforeDrain()
+	}
+
+	drained := make(chan struct{})
+	go func() {
+		u.conns.Wait()
+		close(drained)
+	}()
+	select {
+	case <-drained:
+	case <-time.After(drainTimeout):
+		fmt.Println("closing connections still open after", drainTimeout)
+	}
+}
+
+// upgrade starts the new process and waits until it is serving.
+func (u *Upgrader) upgrade() error {
+	exe, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("upgrade: %v", err)
+	}
+	u.mu.Lock()
+	var files []*os.File
+	for _, ln := range u.listeners {
+		f, err := ln.File()
+		if err != nil {
+			u.mu.Unlock()
+			closeFiles(files)
+			return fmt.Errorf("upgrade: %v", err)
+		}
+		files = append(files, f)
+	}
+	names := strings.Join(u.names, ",")
+	u.mu.Unlock()
+	defer closeFiles(files)
+
+	ready, readyW, err := os.Pipe()
+	if err != nil {
+		return fmt.Errorf("upgrade: %v", err)
+	}
+	defer ready.Close()
+	cmd := exec.Command(exe, os.Args[1:]...)
+	cmd.Stdout, cmd.Stderr = os.Stdout, os.Stderr
+	cmd.ExtraFiles = append(files, readyW)
+	cmd.Env = append(os.Environ(),
+		envListeners+"="+names,
+		fmt.Sprintf("%s=%d", envReadyFD, 3+len(files)))
+	err = cmd.Start()
+	readyW.Close()
+	if err != nil {
+		return fmt.Errorf("upgrade: %v", err)
+	}
+
+	exited := make(chan error, 1)
+	go func() {
+		exited <- cmd.Wait()
+	}()
+	// Exiting closes the pipe without a byte written.
+	served := make(chan bool, 1)
+	go func() {
+		n, _ := ready.Read(make([]byte, 1))
+		served <- n == 1
+	}()
+	select {
+	case ok := <-served:
+		if !ok {
+			return fmt.Errorf("upgrade: new process exited: %v", <-exited)
+		}
+		return nil
+	case err := <-exited:
+		return fmt.Errorf("upgrade: new process exited: %v", err)
+	case <-time.After(upgradeTimeout):
+		cmd.Process.Kill()
+		return errors.New("upgrade: new process wasn't serving after " + upgradeTimeout.String())
+	}
+}
+
+func closeFiles(files []*os.File) {
+	for _, f := range files {
+		f.Close()
+	}
+}
+
+// trackedListener counts the connections it accepted that are still open.
+type trackedListener struct {
+	net.Listener
+	u *Upgrader
+}
+
+func (l trackedListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	l.u.conns.Add(1)
+	return &trackedConn{Conn: conn, u: l.u}, nil
+}
+
+type trackedConn struct {
+	net.Conn
+	u    *Upgrader
+	once sync.Once
+}
+
+func (c *trackedConn) Close() error {
+	c.once.Do(c.u.conns.Done)
+	return c.Conn.Close()
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"time"
+)
+
+// v2Tweet is a tweet from the v2 API with its author expanded.
+type v2Tweet struct {
+	ID         string
+	Text       string
+	AuthorID   string
+	CreatedAt  time.Time
+	Name       string
+	ScreenName string
+}
+
+func sameV2Tweets(a, b []v2Tweet) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// v2TweetFields asks for what decodeV2Tweets needs.
+func v2TweetFields(params url.Values) url.Values {
+	params.Set("tweet.fields", "author_id,created_at")
+	params.Set("expansions", "author_id")
+	params.Set("user.fields", "name,username")
+	return params
+}
+
+func decodeV2Tweets(r io.Reader) ([]v2Tweet, error) {
+	var result struct {
+		Data []struct {
+			ID        string    `json:"id"`
+			Text      string    `json:"text"`
+			AuthorID  string    `json:"author_id"`
+			CreatedAt time.Time `json:"created_at"`
+		} `json:"data"`
+		Includes struct {
+			Users []struct {
+				ID       string `json:"id"`
+				Name     string `json:"name"`
+				Username string `json:"username"`
+			} `json:"users"`
+		} `json:"includes"`
+	}
+	if err := json.NewDecoder(r).Decode(&result); err != nil {
+		return nil, err
+	}
+
+	users := map[string]v2Tweet{}
+	for _, u := range result.Includes.Users {
+		users[u.ID] = v2Tweet{Name: u.Name, ScreenName: u.Username}
+	}
+	var tweets []v2Tweet
+	for _, t := range result.Data {
+		author := users[t.AuthorID]
+		tweets = append(tweets, v2Tweet{
+			ID:         t.ID,
+			Text:       t.Text,
+			AuthorID:   t.AuthorID,
+			CreatedAt:  t.CreatedAt,
+			Name:       author.Name,
+			ScreenName: author.ScreenName,
+		})
+	}
+	return tweets, nil
+}
+
+func (rh *RequestHandler) formatV2Tweet(t v2Tweet) string {
+	byline := t.Name + " (@" + t.ScreenName + ")"
+	if rh.Config.UI.Layout == "headings" {
+		heading := rh.Config.convertEmoji(byline) + " · " + t.CreatedAt.Format(dateLayout)
+		page := fmt.Sprintf("\n## %s\n\n%s\n", heading, rh.Config.isolateBidi(wrapText(rh.Config.convertEmoji(t.Text), rh.Config.UI.Wrap)))
+		return page + rh.formatV2Links(t)
+	}
+	text := rh.Config.convertEmoji(t.Text + "\n\n" + byline)
+	page := fmt.Sprintf("\n%s\n%s\n", rh.Config.isolateBidi(wrapText(text, rh.Config.UI.Wrap)), t.CreatedAt.Format(dateLayout))
+	return page + rh.formatV2Links(t) + "\n" + rh.Config.delimiter() + "\n"
+}
+
+func (rh *RequestHandler) formatV2Links(t v2Tweet) string {
+	var page string
+	if rh.Config.Links.Twitter != "" {
+		page += fmt.Sprintf("=> %s/status/%s View on Twitter\n", profileURL(rh.Config.Links.Twitter, t.ScreenName), t.ID)
+	}
+	if rh.Config.Links.Nitter != "" {
+		page += fmt.Sprintf("=> %s/status/%s View on Nitter\n", profileURL(rh.Config.Links.Nitter, t.ScreenName), t.ID)
+	}
+	return page
+}
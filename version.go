@@ -0,0 +1,41 @@
+package main
+
+import (
+	"fmt"
+	"runtime"
+	"runtime/debug"
+)
+
+// Set at build time, e.g.
+//
+//	go build -ldflags "-X main.version=1.2.0 -X main.commit=$(git rev-parse --short HEAD) -X main.buildDate=$(date -u +%F)"
+var (
+	version   = ""
+	commit    = ""
+	buildDate = ""
+)
+
+// versionString falls back to the module version recorded by the Go
+// toolchain when the binary was built without ldflags.
+func versionString() string {
+	v := version
+	if v == "" {
+		if info, ok := debug.ReadBuildInfo(); ok && info.Main.Version != "" {
+			v = info.Main.Version
+		} else {
+			v = "dev"
+		}
+	}
+	if commit != "" {
+		v += " (" + commit + ")"
+	}
+	if buildDate != "" {
+		v += " built " + buildDate
+	}
+	return v
+}
+
+func runVersion(args []string) error {
+	fmt.Printf("donaldgem %s %s %s/%s\n", versionString(), runtime.Version(), runtime.GOOS, runtime.GOARCH)
+	return nil
+}
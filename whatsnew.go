@@ -0,0 +1,68 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/makeworld-the-better-one/go-gemini"
+)
+
+// newSince returns the positions of the tweets that came after since,
+// newest first. Positions only count while since is still cached; past
+// that, IDs are compared.
+func (tc *TweetCache) newSince(since int64) []int {
+	end, err := tc.FindByID(since)
+	if err != nil {
+		end = len(tc.Tweets)
+	}
+	var positions []int
+	for i := 0; i < end; i++ {
+		if err == nil || tc.Tweets[i].ID > since {
+			positions = append(positions, i)
+		}
+	}
+	return positions
+}
+
+// formatNew lists what's new since a tweet, ending with the link to come
+// back to next time. Without since, nothing has been read yet and the
+// page only hands out the link.
+func (rh *RequestHandler) formatNew(since int64, order string) string {
+	var page strings.Builder
+	page.WriteString("# New since your last visit")
+	if len(rh.TweetCache.Tweets) == 0 {
+		page.WriteString("\n\nNo tweets cached yet.")
+		return page.String()
+	}
+	newest := rh.TweetCache.Tweets[0].ID
+	if since == 0 {
+		fmt.Fprintf(&page, "\n\nBookmark the link below. Following it later lists only the tweets that came since.\n\n=> /new?since=%d Start from here", newest)
+		return page.String()
+	}
+	positions := rh.TweetCache.newSince(since)
+	if len(positions) == 0 {
+		page.WriteString("\n\nNothing new.")
+	} else {
+		fmt.Fprintf(&page, "\n\n%s.", countNoun(len(positions), "new tweet"))
+	}
+	for n := range positions {
+		i := positions[n]
+		if order == "asc" {
+			i = positions[len(positions)-1-n]
+		}
+		tw, err := rh.TweetCache.GetOnPosition(i)
+		if err != nil {
+			continue
+		}
+		page.WriteString(rh.formatEntry(rh.TweetCache.Tweets[i], tw))
+	}
+	fmt.Fprintf(&page, "\n\n=> /new?since=%d Mark as read, and bookmark this link for next time", newest)
+	return page.String()
+}
+
+func (rh *RequestHandler) showNew(since int64, order string) *gemini.Response {
+	body := ioutil.NopCloser(bytes.NewBufferString(rh.wrapBody(rh.formatNew(since, order))))
+	return Success(rh.meta(), body)
+}